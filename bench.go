@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Subdirectories per level of the synthetic trees
+const benchFanout = 4
+
+// Times the events of a kind, from the first to the last one
+type eventWindow struct {
+	count      int
+	bytes      int64
+	first, end time.Time
+}
+
+func (w *eventWindow) add(e statik.Event) {
+	now := time.Now()
+	if w.count == 0 {
+		w.first = now
+	}
+	w.count++
+	w.bytes += e.Bytes
+	w.end = now
+}
+
+func (w *eventWindow) duration() time.Duration { return w.end.Sub(w.first) }
+
+// Runs the bench subcommand: generates a synthetic tree and reports how fast
+// it is walked, copied and rendered with the given settings
+func runBench(args []string) {
+	set := flag.NewFlagSet("bench", flag.ExitOnError)
+	files := set.Int("files", 1000, "Number of files in the synthetic tree")
+	depth := set.Int("depth", 3, "Maximum nesting of the directories in the synthetic tree")
+	size := set.String("size", "64KiB", "Size of each file in the synthetic tree")
+	keep := set.Bool("keep", false, "Keep the synthetic tree and its output instead of removing them")
+	cfg := statik.DefaultConfig()
+	set.IntVar(&cfg.Jobs, "jobs", cfg.Jobs, "Number of files to inspect and copy at once")
+	set.StringVar(&cfg.CopyMethod, "copy-method", cfg.CopyMethod, "How files are carried over into the output: copy, link, reflink or auto")
+	set.StringVar(&cfg.Checksum, "checksum", "", "Compute file checksums with md5, sha1, sha256 or sha512")
+	set.BoolVar(&cfg.LowMemory, "low-memory", cfg.LowMemory, "Work on one file at a time and stream pages to disk, trading speed for a smaller memory footprint")
+	targetList := set.String("targets", strings.Join(cfg.Targets, ","), "Comma separated outputs to generate")
+	_debug := set.Bool("d", false, "Print the logs of the builds")
+	set.Parse(args)
+
+	// The logs of the builds would drown the figures
+	if !*_debug {
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	}
+	fileSize, err := humanize.ParseBytes(*size)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid -size value")
+	}
+	if *files < 1 || *depth < 0 {
+		log.Fatal().Msg("-files must be positive and -depth not negative")
+	}
+	if cfg.Targets, err = statik.ParseTargets(*targetList); err != nil {
+		log.Fatal().Err(err).Msg("Invalid -targets value")
+	}
+
+	root, err := os.MkdirTemp("", "statik-bench-")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not create the benchmark directory")
+	}
+	if *keep {
+		fmt.Println("Keeping the benchmark tree in", root)
+	} else {
+		defer os.RemoveAll(root)
+	}
+	cfg.Source, cfg.Destination = filepath.Join(root, "src"), filepath.Join(root, "dst")
+
+	start := time.Now()
+	dirs, err := syntheticTree(cfg.Source, *files, *depth, int64(fileSize))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not generate the synthetic tree")
+	}
+	total := int64(*files) * int64(fileSize)
+	fmt.Printf("Generated %d files (%s) in %d directories in %s\n",
+		*files, humanize.IBytes(uint64(total)), dirs, time.Since(start).Round(time.Millisecond))
+
+	ctx := context.Background()
+	start = time.Now()
+	if _, _, err = statik.Walk(ctx, cfg, func(*statik.Directory) error { return nil }); err != nil {
+		log.Fatal().Err(err).Msg("Could not walk the synthetic tree")
+	}
+	walked := time.Since(start)
+
+	var copied, rendered eventWindow
+	cfg.Events = func(e statik.Event) {
+		switch e.Kind {
+		case statik.FileCopied:
+			copied.add(e)
+		case statik.PageRendered:
+			rendered.add(e)
+		}
+	}
+	start = time.Now()
+	if _, err = statik.Generate(ctx, cfg); err != nil {
+		log.Fatal().Err(err).Msg("Could not build the synthetic tree")
+	}
+	built := time.Since(start)
+
+	fmt.Printf("Walk:   %10s  %s\n", walked.Round(time.Millisecond), rate(float64(*files), walked, "files"))
+	fmt.Printf("Copy:   %10s  %s, %s\n", copied.duration().Round(time.Millisecond),
+		rate(float64(copied.count), copied.duration(), "files"), byteRate(copied.bytes, copied.duration()))
+	fmt.Printf("Render: %10s  %s\n", rendered.duration().Round(time.Millisecond), rate(float64(rendered.count), rendered.duration(), "pages"))
+	fmt.Printf("Build:  %10s  %s\n", built.Round(time.Millisecond), rate(float64(*files), built, "files"))
+}
+
+func rate(n float64, d time.Duration, unit string) string {
+	if d <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f %s/s", n/d.Seconds(), unit)
+}
+
+func byteRate(n int64, d time.Duration) string {
+	if d <= 0 {
+		return "n/a"
+	}
+	return humanize.IBytes(uint64(float64(n)/d.Seconds())) + "/s"
+}
+
+// Writes n files of the given size into a tree of directories up to depth
+// levels deep, the same on every run. Returns the number of directories
+func syntheticTree(root string, n, depth int, size int64) (int, error) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, size)
+	dirs := map[string]bool{}
+	for i := 0; i < n; i++ {
+		dir := root
+		for level := rng.Intn(depth + 1); level > 0; level-- {
+			dir = filepath.Join(dir, fmt.Sprintf("dir%d", rng.Intn(benchFanout)))
+		}
+		if !dirs[dir] {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return 0, err
+			}
+			for d := dir; !dirs[d]; d = filepath.Dir(d) {
+				dirs[d] = true
+				if d == root {
+					break
+				}
+			}
+		}
+		rng.Read(data)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.bin", i)), data, 0o644); err != nil {
+			return 0, err
+		}
+	}
+	return len(dirs), nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Derives the URL the output is deployed to from the environment of the CI
+// service running the build, reporting the service it has been taken from
+func ciBaseURL() (u *url.URL, service string, err error) {
+	switch {
+	case os.Getenv("NETLIFY") == "true" && os.Getenv("DEPLOY_URL") != "":
+		u, err = url.Parse(os.Getenv("DEPLOY_URL"))
+		return u, "Netlify", err
+	case os.Getenv("VERCEL") == "1" && os.Getenv("VERCEL_URL") != "":
+		// Vercel only provides the host of the deployment
+		u, err = url.Parse("https://" + os.Getenv("VERCEL_URL"))
+		return u, "Vercel", err
+	case os.Getenv("GITHUB_ACTIONS") == "true" && os.Getenv("GITHUB_REPOSITORY") != "":
+		// Pages are published under the owner's github.io domain, in a folder
+		// named after the repository unless it is the owner's own site
+		owner, repo, _ := strings.Cut(os.Getenv("GITHUB_REPOSITORY"), "/")
+		host := strings.ToLower(owner) + ".github.io"
+		u = &url.URL{Scheme: "https", Host: host, Path: "/"}
+		if strings.ToLower(repo) != host {
+			u.Path += repo
+		}
+		return u, "GitHub Actions", nil
+	}
+	return nil, "", nil
+}
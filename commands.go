@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog/log"
+)
+
+// A subcommand of the command line, with flags of its own
+type command struct {
+	name string
+	// The positional arguments, for the usage message
+	args  string
+	about string
+	// Registers the flags of the command, returning the function running it
+	// with the positional arguments once they have been parsed
+	flags func() (run func(args []string))
+}
+
+var commands = []command{
+	{"build", "[src] dst", "Generate the listing of src into dst, once or as a daemon", buildCommand},
+	{"watch", "[src] dst", "Generate the listing and keep it up to date as the source changes", watchCommand},
+	{"serve", "[src] dst, or [src] with -tmp", "Generate the listing and serve it over HTTP", serveCommand},
+	{"clean", "[src] dst", "Remove a generated listing", cleanCommand},
+	{"verify", "[src] dst", "Check that a generated listing is complete and up to date with the source", verifyCommand},
+	{"rollback", "dst", "Publish the generation preceding the current one, of a listing built with -keep", rollbackCommand},
+}
+
+// The invocation predating subcommands, which builds and accepts the flags
+// selecting the other modes
+var legacyCommand = command{"", "[src] dst", "Generate the listing of src into dst", legacyFlags}
+
+// Names of the flags of all commands, which configuration files may set
+var settings = map[string]bool{}
+
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// Makes the flags of a command the ones parsed from the command line
+func (c command) register() (run func(args []string)) {
+	flag.CommandLine = flag.NewFlagSet(c.name, flag.ExitOnError)
+	flag.CommandLine.Usage = func() {
+		out := flag.CommandLine.Output()
+		name := os.Args[0]
+		if c.name != "" {
+			name += " " + c.name
+		}
+		fmt.Fprintf(out, "Usage: %s [flags] %s\n\n%s\n\n", name, c.args, c.about)
+		if c.name == "" {
+			printCommands(out)
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out, "Flags:")
+		flag.PrintDefaults()
+	}
+	return c.flags()
+}
+
+// Registers the flags of every command on their own, to learn their names.
+// Flags are only set to their defaults, so this has to happen before parsing
+func collectSettings() {
+	for _, c := range append(commands, legacyCommand) {
+		c.register()
+		flag.CommandLine.VisitAll(func(f *flag.Flag) { settings[f.Name] = true })
+	}
+}
+
+func printCommands(out io.Writer) {
+	fmt.Fprintln(out, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-10s%s\n", c.name, c.about)
+	}
+	fmt.Fprintf(out, "  %-10s%s\n", "bench", "Measure how fast a synthetic tree is generated")
+	fmt.Fprintf(out, "\nRun %s <command> -h for the flags of each command\n", os.Args[0])
+}
+
+// Chains the functions resolving the flags of a command on top of the
+// configuration file, stopping at the first invalid setting
+func resolveSettings(load func() (configFile, error), resolvers ...func() error) (configure func(args []string) (configFile, error)) {
+	return func(args []string) (file configFile, err error) {
+		if file, err = load(); err != nil {
+			return
+		}
+		if err = setPaths(file, args); err != nil {
+			return
+		}
+		for _, resolve := range resolvers {
+			if err = resolve(); err != nil {
+				return
+			}
+		}
+		return
+	}
+}
+
+// Resolves the settings of a command, exiting when they are invalid
+func mustConfigure(configure func(args []string) (configFile, error), args []string) configFile {
+	file, err := configure(args)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid settings")
+	}
+	return file
+}
+
+// Registers the flags of builds, returning the function resolving them on
+// top of the configuration file, which daemons run again when reloading
+func buildSettings() (configure func(args []string) (configFile, error)) {
+	return resolveSettings(commonFlags(), configFlags(), buildFlags(), priorityFlags())
+}
+
+func legacyFlags() func(args []string) {
+	configureBuild = buildSettings()
+	healthFlag()
+	flag.StringVar(&serveAddr, "serve", "", "Serve the output over HTTP on this address (e.g. :8080) after generating it")
+	flag.BoolVar(&buildAndServe, "build-and-serve", false, "Build into a temporary directory, serve it and keep it up to date with the source, for containers")
+	flag.BoolVar(&watchMode, "watch", false, "Keep running and regenerate the affected parts of the output as the source changes")
+	return func(args []string) { start(mustConfigure(configureBuild, args)) }
+}
+
+func buildCommand() func(args []string) {
+	configureBuild = buildSettings()
+	healthFlag()
+	return func(args []string) { start(mustConfigure(configureBuild, args)) }
+}
+
+func watchCommand() func(args []string) {
+	configure := resolveSettings(commonFlags(), configFlags(), priorityFlags())
+	healthFlag()
+	flag.StringVar(&serveAddr, "serve", "", "Also serve the output over HTTP on this address, reloading the open pages after each update")
+	return func(args []string) {
+		watchMode = true
+		start(mustConfigure(configure, args))
+	}
+}
+
+func serveCommand() func(args []string) {
+	configure := resolveSettings(commonFlags(), configFlags(), priorityFlags())
+	healthFlag()
+	flag.BoolVar(&showProgress, "progress", false, "Show the progress of the build on a status line")
+	flag.StringVar(&serveAddr, "addr", defaultServeAddr, "Address to serve the output on")
+	flag.BoolVar(&watchMode, "watch", false, "Keep the output up to date with the source, reloading the open pages after each update")
+	flag.BoolVar(&buildAndServe, "tmp", false, "Build into a temporary directory, in memory when possible, instead of a destination, for containers. Implies -watch")
+	return func(args []string) { start(mustConfigure(configure, args)) }
+}
+
+func cleanCommand() func(args []string) {
+	configure := resolveSettings(commonFlags())
+	return func(args []string) {
+		mustConfigure(configure, args)
+		if err := statik.Clean(config.Destination); err != nil {
+			log.Fatal().Err(err).Msg("Could not remove the listing")
+		}
+		log.Info().Str("path", config.Destination).Msg("Removed the listing")
+	}
+}
+
+func verifyCommand() func(args []string) {
+	configure := resolveSettings(commonFlags(), configFlags(), priorityFlags())
+	return func(args []string) {
+		mustConfigure(configure, args)
+		lowerPriority()
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		found, err := statik.Verify(ctx, config)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not verify the listing")
+		}
+		for _, d := range found {
+			fmt.Printf("%s: %s\n", d.Path, d.Reason)
+		}
+		if len(found) != 0 {
+			log.Fatal().Int("discrepancies", len(found)).Msg("The listing does not match the source")
+		}
+		log.Info().Msg("The listing matches the source")
+	}
+}
+
+func rollbackCommand() func(args []string) {
+	configure := resolveSettings(commonFlags())
+	to := flag.String("to", "", "The generation to publish instead of the one preceding the current one")
+	return func(args []string) {
+		mustConfigure(configure, args)
+		generation, err := statik.Rollback(config.Destination, *to)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Could not roll back the listing")
+		}
+		log.Info().Str("path", config.Destination).Str("generation", generation).Msg("Published generation")
+	}
+}
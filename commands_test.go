@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+// The test binary runs the command line instead of the tests when asked to,
+// so that commands can exit as they do when installed
+func TestMain(m *testing.M) {
+	if os.Getenv("STATIK_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Runs the command line in dir, returning its combined output
+func runStatik(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "STATIK_TEST_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	if err := statiktest.WriteFixture(filepath.Join(dir, "src"), statiktest.Fixture()); err != nil {
+		t.Fatal(err)
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(filepath.Join(dir, p))
+		return err == nil
+	}
+	steps := []struct {
+		args    []string
+		wantErr bool
+		check   func() bool
+	}{
+		{[]string{"build", "src", "out"}, false, func() bool { return exists("out/index.html") }},
+		{[]string{"verify", "src", "out"}, false, nil},
+		{[]string{"clean", "src", "out"}, false, func() bool { return !exists("out") }},
+		{[]string{"verify", "src", "out"}, true, nil},
+		// The invocation predating subcommands builds
+		{[]string{"src", "out"}, false, func() bool { return exists("out/docs/index.html") }},
+		{[]string{"clean", "-watch", "src", "out"}, true, func() bool { return exists("out") }},
+		{[]string{"rollback", "out"}, true, nil},
+		{[]string{"build", "-keep", "2", "src", "gen"}, false, func() bool { return exists("gen/current/index.html") }},
+		{[]string{"build", "-keep", "2", "src", "gen"}, false, nil},
+		{[]string{"rollback", "gen"}, false, nil},
+		{[]string{"rollback", "gen"}, true, nil},
+	}
+	for _, step := range steps {
+		out, err := runStatik(t, dir, step.args...)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("statik %q: error = %v, want error %v\n%s", step.args, err, step.wantErr, out)
+		}
+		if step.check != nil && !step.check() {
+			t.Fatalf("statik %q did not have the expected effect\n%s", step.args, out)
+		}
+	}
+}
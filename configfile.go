@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lucat1/statik/pkg/statik"
+)
+
+// Names of the configuration files looked up in the working directory
+var configFileNames = []string{"statik.yaml", "statik.yml", "statik.toml"}
+
+// Friendlier names for the single letter flags, and the positional arguments
+var configKeys = map[string]string{
+	"base-url":      "b",
+	"include":       "i",
+	"exclude":       "e",
+	"recursive":     "r",
+	"convert-links": "l",
+	"template":      "page",
+	"stylesheet":    "style",
+	"debug":         "d",
+	"src":           "source",
+	"dst":           "destination",
+	"dest":          "destination",
+}
+
+// Settings holding paths, which are relative to the configuration file
+var configPaths = map[string]bool{"source": true, "destination": true, "page": true, "style": true}
+
+var configPath string
+
+// The settings read from a configuration file, each holding one or more
+// values, keyed by flag name
+type configFile struct {
+	path   string
+	values map[string][]string
+}
+
+// Finds the configuration file to use, either the one given with -config or
+// the first of configFileNames found in the working directory
+func findConfigFile() (string, error) {
+	if configPath != "" {
+		return configPath, nil
+	}
+	for _, name := range configFileNames {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Reads a configuration file in either the YAML or the TOML flavour, as told
+// by its extension. Only flat files of scalars and lists are understood
+func loadConfigFile(path string) (cfg configFile, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return cfg, fmt.Errorf("could not open configuration file %s:\n%w", path, err)
+	}
+	defer file.Close()
+
+	cfg = configFile{path: path, values: map[string][]string{}}
+	toml := filepath.Ext(path) == ".toml"
+	scanner := bufio.NewScanner(file)
+	// The key a YAML block list belongs to
+	var listKey string
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || (!toml && text == "---") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(text, "- "); ok && !toml {
+			if listKey == "" {
+				return cfg, fmt.Errorf("%s:%d: list item without a key", path, line)
+			}
+			var value string
+			if value, err = parseScalar(item); err != nil {
+				return cfg, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			cfg.values[listKey] = append(cfg.values[listKey], value)
+			continue
+		}
+
+		sep := ":"
+		if toml {
+			sep = "="
+		}
+		key, raw, ok := strings.Cut(text, sep)
+		if !ok {
+			return cfg, fmt.Errorf("%s:%d: expected a key%s value pair", path, line, sep)
+		}
+		if key, err = configKey(strings.TrimSpace(key)); err != nil {
+			return cfg, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		listKey = ""
+		raw = strings.TrimSpace(raw)
+		if raw == "" && !toml {
+			listKey = key
+			continue
+		}
+		var values []string
+		if values, err = parseValues(raw); err != nil {
+			return cfg, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		cfg.values[key] = values
+	}
+	if err = scanner.Err(); err != nil {
+		return cfg, fmt.Errorf("could not read configuration file %s:\n%w", path, err)
+	}
+	return cfg, nil
+}
+
+// Maps a key of the configuration file to the flag it sets
+func configKey(key string) (string, error) {
+	key = strings.ReplaceAll(strings.Trim(key, `"'`), "_", "-")
+	if name, ok := configKeys[key]; ok {
+		key = name
+	}
+	if key != "source" && key != "destination" && !settings[key] {
+		return "", fmt.Errorf("unknown setting %q", key)
+	}
+	return key, nil
+}
+
+// Parses either a single scalar or an inline list as in [a, "b"]
+func parseValues(raw string) (values []string, err error) {
+	if !strings.HasPrefix(raw, "[") {
+		var value string
+		value, err = parseScalar(raw)
+		return []string{value}, err
+	}
+	end := strings.LastIndex(raw, "]")
+	if end < 0 {
+		return nil, errors.New("unterminated list")
+	}
+	for _, item := range strings.Split(raw[1:end], ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		var value string
+		if value, err = parseScalar(item); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Parses a quoted or bare scalar, dropping any trailing comment
+func parseScalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := strings.LastIndex(raw, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strconv.Unquote(raw[:end+1])
+	case strings.HasPrefix(raw, "'"):
+		end := strings.LastIndex(raw, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strings.ReplaceAll(raw[1:end], "''", "'"), nil
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+// Sets the flags which have not been given on the command line from the
+// configuration file. Lists are set one item at a time for repeatable flags,
+// and joined with commas for all others
+func (cfg configFile) apply() error {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for name, values := range cfg.values {
+		f := flag.Lookup(name)
+		if f == nil || given[name] {
+			continue
+		}
+		if configPaths[name] {
+			values = cfg.resolve(values)
+		}
+		// Values of the flag package implement Getter, the repeatable ones
+		// defined by statik do not
+		if _, ok := f.Value.(flag.Getter); ok {
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, value := range values {
+			if err := f.Value.Set(value); err != nil {
+				return fmt.Errorf("invalid value %q for %s in %s:\n%w", value, name, cfg.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Fills in the positional arguments missing from the command line, which are
+// either the destination alone or both the source and the destination
+func (cfg configFile) args(args []string, sourceOnly bool) []string {
+	src, dst := cfg.values["source"], cfg.values["destination"]
+	switch {
+	case sourceOnly && len(args) == 0 && len(src) != 0:
+		return cfg.resolve(src[:1])
+	case sourceOnly:
+		return args
+	case len(args) == 0 && len(dst) != 0 && len(src) != 0:
+		return append(cfg.resolve(src[:1]), cfg.resolve(dst[:1])...)
+	case len(args) == 0 && len(dst) != 0:
+		return cfg.resolve(dst[:1])
+	case len(args) == 1 && len(src) != 0:
+		return append(cfg.resolve(src[:1]), args...)
+	}
+	return args
+}
+
+// Makes relative paths relative to the directory of the configuration file
+func (cfg configFile) resolve(paths []string) (resolved []string) {
+	for _, p := range paths {
+		if p != "" && !filepath.IsAbs(p) && !statik.IsRemoteDestination(p) {
+			p = filepath.Join(filepath.Dir(cfg.path), p)
+		}
+		resolved = append(resolved, p)
+	}
+	return
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	daemonMode bool
+	// An optional schedule on which the daemon rebuilds on its own
+	rebuildSchedule schedule
+	// Resolves the settings of the build command run from its arguments
+	configureBuild func(args []string) (configFile, error)
+)
+
+// Sends a state notification to systemd, following the sd_notify protocol.
+// It is a no-op when the process has not been started by systemd
+func sdNotify(state string) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return
+	}
+	// Abstract namespace sockets are advertised with a leading @
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not connect to the systemd notification socket")
+		return
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		log.Warn().Err(err).Msg("Could not notify systemd")
+	}
+}
+
+// Returns a channel firing at the next scheduled rebuild, or nil when no
+// schedule has been configured
+func nextRebuild() <-chan time.Time {
+	if rebuildSchedule == nil {
+		return nil
+	}
+	next := rebuildSchedule.Next(time.Now())
+	if next.IsZero() {
+		log.Warn().Msg("The rebuild schedule never fires again")
+		return nil
+	}
+	log.Debug().Time("at", next).Msg("Scheduled the next rebuild")
+	return time.After(time.Until(next))
+}
+
+// The settings of the daemon which a reload replaces
+type daemonSettings struct {
+	config             statik.Config
+	schedule           schedule
+	notifyTargets      []*url.URL
+	notifyOn           string
+	smtpAddr, mailFrom string
+	showProgress       bool
+}
+
+func currentSettings() daemonSettings {
+	return daemonSettings{config, rebuildSchedule, notifyTargets, notifyOn, smtpAddr, mailFrom, showProgress}
+}
+
+func (s daemonSettings) restore() {
+	config, rebuildSchedule, notifyTargets, notifyOn = s.config, s.schedule, s.notifyTargets, s.notifyOn
+	smtpAddr, mailFrom, showProgress = s.smtpAddr, s.mailFrom, s.showProgress
+}
+
+// Reads the configuration file again and resolves the command line on top of
+// it, as on startup. The current settings are kept when the new ones are
+// invalid, which are reported instead of exiting the process
+func reloadConfig() error {
+	saved := currentSettings()
+	config = statik.DefaultConfig()
+	// The arguments have already been parsed once, so no usage is needed
+	flag.CommandLine = flag.NewFlagSet(invoked.name, flag.ContinueOnError)
+	invoked.flags()
+	err := flag.CommandLine.Parse(invokedArgs)
+	var file configFile
+	if err == nil {
+		file, err = configureBuild(flag.Args())
+	}
+	if err != nil {
+		saved.restore()
+		return err
+	}
+	logParameters(file)
+	return nil
+}
+
+// Runs statik as a long-lived service: the site is built once on startup,
+// on the configured schedule and each time SIGHUP is received, after
+// re-reading the configuration file and the listing assets. Builds run in the
+// background, so that signals cancel the one in flight: SIGHUP to start over
+// with the new configuration and SIGTERM to shut down
+func runDaemon() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
+
+	var (
+		timer <-chan time.Time
+		// Closed once the build in flight has finished, nil between builds
+		done   chan struct{}
+		cancel context.CancelFunc
+		ready  bool
+	)
+	build := func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done, timer = make(chan struct{}), nil
+		go func(done chan struct{}) {
+			defer close(done)
+			runBuild(ctx)
+		}(done)
+	}
+	build()
+	for {
+		select {
+		case <-done:
+			cancel()
+			done, timer = nil, nextRebuild()
+			if !ready {
+				ready = true
+				sdNotify("READY=1")
+				log.Info().Msg("Running as a daemon, send SIGHUP to reload and rebuild")
+			}
+		case <-timer:
+			log.Info().Msg("Running scheduled rebuild")
+			build()
+		case sig := <-signals:
+			// Full builds are staged, so the output is left as it was
+			interrupted := done != nil
+			if interrupted {
+				log.Info().Str("signal", sig.String()).Msg("Cancelling the build in progress")
+				cancel()
+				<-done
+				done = nil
+			}
+			if sig != syscall.SIGHUP {
+				sdNotify("STOPPING=1")
+				log.Info().Str("signal", sig.String()).Msg("Shutting down")
+				return
+			}
+			sdNotify("RELOADING=1")
+			// The listing assets are read again by each build
+			log.Info().Msg("Reloading and rebuilding")
+			err := reloadConfig()
+			if err != nil {
+				log.Error().Err(err).Msg("Invalid configuration, keeping the previous one")
+			}
+			ready = true
+			sdNotify("READY=1")
+			if err == nil || interrupted {
+				build()
+			}
+		}
+	}
+}
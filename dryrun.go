@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	dryRunText = "text"
+	dryRunJSON = "json"
+)
+
+var (
+	dryRun       bool
+	dryRunFormat string
+)
+
+// Reports what a build would change in the destination on stdout, without
+// touching it
+func runDryRun() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	changes, err := statik.DryRun(ctx, config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not compute the changes of the build")
+	}
+	if dryRunFormat == dryRunJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(&changes); err != nil {
+			log.Fatal().Err(err).Msg("Could not print the changes of the build")
+		}
+		return
+	}
+	for _, p := range changes.Removed {
+		fmt.Println("remove", p)
+	}
+	for _, p := range changes.Copied {
+		fmt.Println("copy", p)
+	}
+	for _, p := range changes.Regenerated {
+		fmt.Println("render", p)
+	}
+	log.Info().Int("copied", len(changes.Copied)).Int("regenerated", len(changes.Regenerated)).Int("removed", len(changes.Removed)).Msg("Dry run complete, nothing has been written")
+}
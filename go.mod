@@ -3,16 +3,21 @@ module github.com/lucat1/statik
 go 1.20
 
 require (
+	github.com/alecthomas/chroma/v2 v2.8.0
 	github.com/dustin/go-humanize v1.0.1
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/gabriel-vasile/mimetype v1.4.2
 	github.com/rs/zerolog v1.29.1
 	github.com/tdewolff/minify/v2 v2.12.7
+	github.com/yuin/goldmark v1.5.6
+	golang.org/x/image v0.12.0
+	golang.org/x/net v0.12.0
+	golang.org/x/sys v0.10.0
 )
 
 require (
+	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/tdewolff/parse/v2 v2.6.6 // indirect
-	golang.org/x/net v0.12.0 // indirect
-	golang.org/x/sys v0.10.0 // indirect
 )
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog/log"
+)
+
+// Tracks the state of the builds performed by a long-lived statik process, so
+// that supervisors can probe it over HTTP
+type healthState struct {
+	mu          sync.RWMutex
+	building    bool
+	lastBuild   time.Time
+	lastSuccess time.Time
+	lastError   string
+	// The id of the last successful build, which is the one being served
+	lastBuildID string
+	// Counters and figures of the last build, exposed as metrics
+	builds, failures int
+	lastDuration     time.Duration
+	lastFiles        int
+	lastBytes        int64
+}
+
+var (
+	healthAddr string
+	health     healthState
+)
+
+func (h *healthState) started() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.building = true
+}
+
+func (h *healthState) finished(r *statik.Report) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.building = false
+	h.lastBuild = r.Started.Add(r.Duration)
+	h.lastError = r.Error
+	h.lastDuration = r.Duration
+	h.builds++
+	if r.Failed() {
+		h.failures++
+	} else {
+		h.lastSuccess = h.lastBuild
+		h.lastBuildID = r.Build
+		h.lastFiles = r.Files
+		h.lastBytes = r.Bytes
+	}
+}
+
+type healthPayload struct {
+	Building    bool   `json:"building"`
+	LastBuild   string `json:"last_build,omitempty"`
+	LastSuccess string `json:"last_success,omitempty"`
+	Build       string `json:"build,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (h *healthState) payload() healthPayload {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return healthPayload{
+		Building:    h.building,
+		LastBuild:   formatTime(h.lastBuild),
+		LastSuccess: formatTime(h.lastSuccess),
+		Build:       h.lastBuildID,
+		Error:       h.lastError,
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, p healthPayload) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&p)
+}
+
+// The process is healthy as long as the last build did not fail
+func (h *healthState) healthz(w http.ResponseWriter, _ *http.Request) {
+	p := h.payload()
+	status := http.StatusOK
+	if p.Error != "" {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, p)
+}
+
+// The output is ready to be served once a build has completed successfully
+func (h *healthState) readyz(w http.ResponseWriter, _ *http.Request) {
+	p := h.payload()
+	status := http.StatusOK
+	if p.LastSuccess == "" {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, p)
+}
+
+func registerHealth(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", health.healthz)
+	mux.HandleFunc("/readyz", health.readyz)
+	mux.HandleFunc("/metrics", health.metrics)
+}
+
+// Exposes the build figures in the Prometheus text format
+func (h *healthState) metrics(w http.ResponseWriter, _ *http.Request) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var lastSuccess int64
+	if !h.lastSuccess.IsZero() {
+		lastSuccess = h.lastSuccess.Unix()
+	}
+	building := 0
+	if h.building {
+		building = 1
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Header().Set("Cache-Control", "no-store")
+	for _, m := range []struct {
+		name, kind, help string
+		value            any
+	}{
+		{"statik_builds_total", "counter", "Builds performed since startup", h.builds},
+		{"statik_build_failures_total", "counter", "Builds failed since startup", h.failures},
+		{"statik_building", "gauge", "Whether a build is in progress", building},
+		{"statik_last_build_duration_seconds", "gauge", "Duration of the last build", h.lastDuration.Seconds()},
+		{"statik_last_success_timestamp_seconds", "gauge", "Time of the last successful build", lastSuccess},
+		{"statik_files", "gauge", "Files listed by the last successful build", h.lastFiles},
+		{"statik_bytes", "gauge", "Bytes listed by the last successful build", h.lastBytes},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+}
+
+// Exposes the health endpoints on their own listener in the background
+func serveHealth(addr string) {
+	mux := http.NewServeMux()
+	registerHealth(mux)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Error().Err(err).Str("addr", addr).Msg("Health endpoint stopped")
+		}
+	}()
+	log.Info().Str("addr", addr).Msg("Serving health endpoints")
+}
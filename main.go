@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"syscall"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const lowMemoryGCPercent = 25
+
+var (
+	// The configuration all builds of this process run with
+	config statik.Config
+
+	watchMode bool
+	// The command run and its arguments, parsed again when reloading
+	invoked     command
+	invokedArgs []string
+)
+
+func main() {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	config = statik.DefaultConfig()
+	collectSettings()
+
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "bench" {
+		runBench(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "help" {
+		printCommands(os.Stdout)
+		return
+	}
+	// The invocation predating subcommands is an alias for build
+	cmd := legacyCommand
+	if len(args) > 0 {
+		if c, ok := lookupCommand(args[0]); ok {
+			cmd, args = c, args[1:]
+		}
+	}
+	invoked, invokedArgs = cmd, args
+	run := cmd.register()
+	flag.CommandLine.Parse(args)
+	run(flag.Args())
+}
+
+// Registers the flags shared by all commands, returning the function reading
+// the configuration file and setting up logging once they have been parsed
+func commonFlags() (load func() (configFile, error)) {
+	_debug := flag.Bool("d", false, "Print debug logs")
+	flag.StringVar(&configPath, "config", "", "Read settings from this file instead of a statik.yaml or statik.toml in the working directory, flags taking precedence")
+	return func() (file configFile, err error) {
+		var path string
+		if path, err = findConfigFile(); err != nil {
+			return file, fmt.Errorf("could not look up the configuration file:\n%w", err)
+		} else if path != "" {
+			if file, err = loadConfigFile(path); err != nil {
+				return file, fmt.Errorf("invalid configuration file %s:\n%w", path, err)
+			}
+			if err = file.apply(); err != nil {
+				return file, fmt.Errorf("invalid configuration file %s:\n%w", path, err)
+			}
+		}
+
+		if *_debug {
+			zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		} else {
+			zerolog.SetGlobalLevel(zerolog.InfoLevel)
+		}
+		return file, nil
+	}
+}
+
+// Sets the source and the destination from the positional arguments, which
+// are either the destination alone or both the source and the destination.
+// When building into a temporary directory only the source can be given
+func setPaths(file configFile, args []string) (err error) {
+	args = file.args(args, buildAndServe)
+	if buildAndServe {
+		// The output lives in memory, so only the source can be given
+		if len(args) > 1 {
+			flag.CommandLine.Usage()
+			os.Exit(1)
+		}
+		if len(args) == 1 {
+			config.Source = args[0]
+		}
+		if config.Destination, err = scratchDir(); err != nil {
+			return fmt.Errorf("could not create the build directory:\n%w", err)
+		}
+		watchMode = true
+		if serveAddr == "" {
+			serveAddr = defaultServeAddr
+		}
+	} else if len(args) == 1 {
+		config.Destination = args[0]
+	} else if len(args) == 2 {
+		config.Source = args[0]
+		config.Destination = args[1]
+	} else {
+		if len(args) > 2 {
+			fmt.Fprintln(os.Stderr, "Invalid number of arguments, max 2 accepted")
+		}
+		flag.CommandLine.Usage()
+		os.Exit(1)
+	}
+	return nil
+}
+
+// Registers the flags shaping the generated listing, returning the function
+// resolving them into config once they have been parsed
+func configFlags() (resolve func() error) {
+	includeRegExStr := flag.String("i", config.Include.String(), "A filter of the names to include into the listing: a regex, glob:pattern or exact:name")
+	excludeRegExStr := flag.String("e", config.Exclude.String(), "A filter of the names to exclude from the listing: a regex, glob:pattern or exact:name")
+	var filterOpts statik.FilterOptions
+	flag.BoolVar(&filterOpts.IgnoreCase, "filter-icase", false, "Match the -i and -e filters regardless of case")
+	flag.BoolVar(&filterOpts.Anchored, "filter-anchored", false, "Match the -i and -e regexes against whole names only")
+	ignoreList := flag.String("ignore-files", strings.Join(config.IgnoreFiles, ","), "Comma separated .gitignore style files to honor in each directory, later ones overriding earlier ones")
+	flag.BoolVar(&config.Recursive, "r", config.Recursive, "Recursively scan the file tree")
+	flag.BoolVar(&config.IncludeEmpty, "empty", config.IncludeEmpty, "Whether to list empty directories")
+	flag.BoolVar(&config.Sort, "sort", config.Sort, "Sort files A-z and by type")
+	rawURL := flag.String("b", config.BaseURL.String(), "The base URL")
+	flag.BoolVar(&config.Relative, "relative", config.Relative, "Link the listings to each other with relative URLs, so that the output works from any host or over file://")
+	detectURL := flag.Bool("ci-base-url", false, "Unless -b is given, take the base URL from the environment of Netlify, Vercel or GitHub Actions")
+	flag.BoolVar(&config.ConvertLinks, "l", config.ConvertLinks, "Convert .link files to anchor tags")
+	flag.BoolVar(&config.Readme, "readme", config.Readme, "Render a HEADER.md or README.md above the listing of its directory")
+	flag.BoolVar(&config.Thumbnails, "thumbs", config.Thumbnails, "Generate thumbnails of images and show them as a gallery")
+	flag.BoolVar(&config.Previews, "previews", config.Previews, "Generate a syntax highlighted preview page next to each text file")
+	flag.StringVar(&config.Bundle, "archive", config.Bundle, "Bundle the files of each directory into an archive named after it which can be downloaded at once: zip")
+	flag.StringVar(&config.LinkRel, "link-rel", config.LinkRel, "The rel attribute of links to other hosts, empty to leave it out")
+	flag.StringVar(&config.PageTemplate, "page", "", "Use a custom listing page template")
+	flag.StringVar(&config.View, "view", config.View, "The view of directories without a .statik-view marker: list, gallery or a custom one")
+	flag.StringVar(&config.Density, "density", config.Density, "The default density of listing rows, compact or detailed, which visitors can switch")
+	flag.BoolVar(&config.NoJS, "no-js", config.NoJS, "Leave all scripts out of the generated pages")
+	flag.Var(&config.ViewTemplates, "view-template", "Use a custom template for a view, as name=path (repeatable)")
+	flag.StringVar(&config.Stylesheet, "style", "", "Use a custom stylesheet file")
+	targetList := flag.String("targets", strings.Join(config.Targets, ","), "Comma separated outputs to generate, among "+strings.Join(statik.Targets(), ", "))
+	flag.IntVar(&config.FeedEntries, "feed-entries", config.FeedEntries, "Number of the most recently modified files listed in the feed")
+	flag.StringVar(&config.InventoryFormat, "inventory-format", config.InventoryFormat, "The format of the file inventory, csv or tsv")
+	flag.StringVar(&config.SBOMFormat, "sbom-format", config.SBOMFormat, "The format of the software bill of materials, spdx or cyclonedx")
+	flag.IntVar(&config.MIMELimit, "mime-limit", 0, "Bytes read from each file to detect its type, 3072 when zero")
+	mimeExtList := flag.String("mime-by-ext", "", "Comma separated extensions of the files whose type is told by their name, without reading them")
+	enricherList := flag.String("enrich", strings.Join(config.Enrichers, ","), "Comma separated file metadata enrichers to run in order, among "+strings.Join(statik.Enrichers(), ", "))
+	buildHTML := flag.Bool("html", true, "Set false not to build html files")
+	buildJSON := flag.Bool("json", true, "Set false not to build JSON metadata")
+	flag.BoolVar(&config.Resume, "resume", config.Resume, "Resume an interrupted build instead of starting over")
+	flag.BoolVar(&config.Sync, "sync", config.Sync, "Update the existing output in place, only writing what changed, instead of wiping it")
+	flag.BoolVar(&config.AppendOnly, "append-only", config.AppendOnly, "Never delete nor overwrite files in the output, only adding new ones and regenerating the listings")
+	flag.BoolVar(&config.Objects, "objects", config.Objects, "Copy each file once under objects/, named by its checksum, and link the listings to it (requires -checksum)")
+	flag.BoolVar(&config.NoCopy, "no-copy", config.NoCopy, "Only write the listings and metadata, linking files to where the base URL already serves the source from")
+	flag.IntVar(&config.Jobs, "jobs", config.Jobs, "Number of files to inspect and copy at once")
+	flag.Float64Var(&config.IOLimit, "io-limit", config.IOLimit, "Read at most this many MB/s from the source when hashing and copying, leaving bandwidth to other services")
+	flag.BoolVar(&config.LowMemory, "low-memory", config.LowMemory, "Work on one file at a time and stream pages to disk, trading speed for a smaller memory footprint")
+	chmodFiles := flag.String("chmod-files", "", "Octal permissions for all output files (e.g. 644)")
+	chmodDirs := flag.String("chmod-dirs", "", "Octal permissions for all output directories (e.g. 755)")
+	chownSpec := flag.String("chown", "", "Ownership for all outputs as uid:gid (names are accepted too)")
+	preserveList := flag.String("preserve", "", "Comma separated attributes of source files and directories to carry over to the output: times, mode, owner")
+	flag.BoolVar(&config.PreserveXattrs, "xattrs", config.PreserveXattrs, "Preserve the SELinux context and POSIX ACL of copied files")
+	flag.StringVar(&config.SELinuxContext, "selinux-context", "", "SELinux context to label all outputs with")
+	flag.BoolVar(&config.OneFileSystem, "one-file-system", config.OneFileSystem, "Don't descend into directories on other filesystems")
+	flag.BoolVar(&config.Hardlinks, "hardlinks", config.Hardlinks, "Recreate hard links between source files in the output")
+	followSymlinks := flag.Bool("follow-symlinks", false, "Walk into symlinked directories, skipping the links which loop back into a parent")
+	preserveSymlinks := flag.Bool("preserve-symlinks", false, "Recreate symlinks pointing within the source as symlinks in the output")
+	flag.StringVar(&config.CopyMethod, "copy-method", config.CopyMethod, "How files are carried over into the output: copy, link, reflink or auto, copying across filesystems")
+	flag.StringVar(&config.Link, "link", config.Link, "Link copies to their sources on the same filesystem instead of copying them: hard, reflink or auto, conflicting with other -copy-method values than copy")
+	flag.BoolVar(&config.Trash, "trash", config.Trash, "Move orphaned outputs to a trash folder instead of deleting them when resuming")
+	flag.IntVar(&config.Keep, "keep", 0, "Write each build into a new timestamped generation, published through a current symlink, keeping this many of them to roll back to")
+	flag.DurationVar(&config.TrashRetention, "trash-retention", config.TrashRetention, "How long to keep trashed outputs for")
+	flag.StringVar(&config.Remote, "remote", "", "List an rclone remote (e.g. s3:bucket/prefix) or an existing HTTP listing instead of a local source directory")
+	rawRemoteURL := flag.String("remote-url", "", "The public URL objects of the remote are served from (defaults to the remote itself for HTTP listings, and to the recorded URLs with -from-json)")
+	flag.StringVar(&config.FromJSON, "from-json", "", "Regenerate the listings from the statik.json of a previous build, or the output holding it, instead of a source directory")
+	aggregateList := flag.String("aggregate", "", "Comma separated statik.json files, or outputs holding them, of builds published under one domain to write a root index of into dst, instead of listing a source")
+	flag.StringVar(&config.Rclone, "rclone", config.Rclone, "Path to the rclone binary")
+	flag.StringVar(&config.Deploy, "deploy", "", "Push the output to a remote host with rsync after each build (e.g. user@host:/var/www/files)")
+	flag.StringVar(&config.DeployCommand, "deploy-command", "", "Shell command deploying the output instead of rsync, given the output directory and the -deploy target as $1 and $2")
+	flag.BoolVar(&config.Semver, "semver", config.Semver, "Sort versioned names by version and mark the latest release")
+	flag.BoolVar(&config.LatestStub, "latest", config.LatestStub, "Generate a latest/ redirect to the newest versioned directory")
+	flag.Var(&config.Mounts, "mount", "List a directory at a path of the tree, as /at=dir, or merge it into the root (repeatable, replaces the source)")
+	flag.Var(&config.Prioritize, "prioritize", "Walk, copy and render subtrees matching a glob before the others (repeatable)")
+	flag.Var(&config.Slow, "slow", "Inspect and copy at most jobs files at once (default 1) in subtrees matching a glob, as glob[=jobs] (repeatable)")
+	flag.Var(&config.HideExtensions, "hide-ext", "Hide the extension of files matching a glob in their displayed name, e.g. *.pdf (repeatable)")
+	flag.Var(&config.Restrict, "restrict", "Mark directories matching a glob as restricted, as glob[=label] (repeatable)")
+	flag.Var(&config.Unlisted, "unlisted", "Publish directories matching a glob under a random token, unlinked from the rest of the site (repeatable)")
+	flag.StringVar(&config.UnlistedManifest, "unlisted-manifest", "", "Where to keep the tokens of unlisted directories (defaults to the destination path with a .unlisted.json suffix)")
+	serverList := flag.String("server-config", "", "Comma separated web servers (nginx, apache, caddy) to generate a configuration snippet for")
+	flag.Var(&config.Aliases, "alias", "Alias the newest file matching a glob, as glob=alias[:symlink|copy|redirect] (repeatable)")
+	flag.StringVar(&config.Checksum, "checksum", "", "Compute file checksums with md5, sha1, sha256 or sha512")
+	flag.DurationVar(&config.Timeout, "timeout", 0, "Abort builds taking longer than this (e.g. 30m)")
+	flag.DurationVar(&config.TimeBudget, "time-budget", 0, "Stop walking new subtrees after this long (e.g. 10m), leaving the rest to the next run")
+	flag.DurationVar(&config.CopyTimeout, "copy-timeout", 0, "Abort builds when copying a single file takes longer than this")
+	flag.BoolVar(&config.DropExpired, "drop-expired", config.DropExpired, "Stop copying files whose sidecar expiry date has passed, besides delisting them")
+	expiryNotice := flag.String("expiry-notice", "", "Report files expiring within this long (e.g. 72h, 14d), a week by default")
+	archiveAge := flag.String("archive-after", "", "Retire files older than this age (e.g. 720h, 90d, 1y) from listings into an archive page, without copying them")
+	return func() (err error) {
+		// Collect garbage more eagerly, keeping the heap close to the live set
+		if config.LowMemory {
+			debug.SetGCPercent(lowMemoryGCPercent)
+		}
+		if config.FileMode, err = statik.ParseMode(*chmodFiles); err != nil {
+			return fmt.Errorf("invalid -chmod-files value:\n%w", err)
+		}
+		if config.DirMode, err = statik.ParseMode(*chmodDirs); err != nil {
+			return fmt.Errorf("invalid -chmod-dirs value:\n%w", err)
+		}
+		if config.UID, config.GID, err = statik.ParseOwner(*chownSpec); err != nil {
+			return fmt.Errorf("invalid -chown value:\n%w", err)
+		}
+		if config.Preserve, err = statik.ParsePreserve(*preserveList); err != nil {
+			return fmt.Errorf("invalid -preserve value:\n%w", err)
+		}
+		if statik.IsHTTPSource(config.Remote) && *rawRemoteURL == "" {
+			*rawRemoteURL = config.Remote
+		}
+		if config.Remote != "" && *rawRemoteURL == "" {
+			return errors.New("the -remote-url flag is required when listing a remote")
+		}
+		if *rawRemoteURL != "" {
+			if config.RemoteURL, err = url.Parse(*rawRemoteURL); err != nil {
+				return fmt.Errorf("could not parse remote URL:\n%w", err)
+			}
+		}
+		switch {
+		case *followSymlinks && *preserveSymlinks:
+			return errors.New("-follow-symlinks and -preserve-symlinks cannot be combined")
+		case *followSymlinks:
+			config.Symlinks = statik.FollowSymlinks
+		case *preserveSymlinks:
+			config.Symlinks = statik.PreserveSymlinks
+		}
+		if *aggregateList != "" {
+			config.Aggregate = strings.Split(*aggregateList, ",")
+		}
+		if config.Targets, err = statik.ParseTargets(*targetList); err != nil {
+			return fmt.Errorf("invalid -targets value:\n%w", err)
+		}
+		if config.Enrichers, err = statik.ParseEnrichers(*enricherList); err != nil {
+			return fmt.Errorf("invalid -enrich value:\n%w", err)
+		}
+		config.Targets = withoutTarget(config.Targets, "html", !*buildHTML)
+		config.Targets = withoutTarget(config.Targets, "json", !*buildJSON)
+		if config.ArchiveAfter, err = statik.ParseAge(*archiveAge); err != nil {
+			return fmt.Errorf("invalid -archive-after value:\n%w", err)
+		}
+		if config.ExpiryNotice, err = statik.ParseAge(*expiryNotice); err != nil {
+			return fmt.Errorf("invalid -expiry-notice value:\n%w", err)
+		}
+		if config.ServerConfigs, err = statik.ParseServerConfigs(*serverList); err != nil {
+			return fmt.Errorf("invalid -server-config value:\n%w", err)
+		}
+		config.IgnoreFiles = nil
+		for _, name := range strings.Split(*ignoreList, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				config.IgnoreFiles = append(config.IgnoreFiles, name)
+			}
+		}
+		for _, ext := range strings.Split(*mimeExtList, ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				config.MIMEByExtension = append(config.MIMEByExtension, ext)
+			}
+		}
+		if config.Include, err = statik.ParseFilter(*includeRegExStr, filterOpts); err != nil {
+			return fmt.Errorf("invalid filter for include matching:\n%w", err)
+		}
+		if config.Exclude, err = statik.ParseFilter(*excludeRegExStr, filterOpts); err != nil {
+			return fmt.Errorf("invalid filter for exclude matching:\n%w", err)
+		}
+
+		if config.BaseURL, err = url.Parse(*rawURL); err != nil {
+			return fmt.Errorf("could not parse base URL:\n%w", err)
+		}
+		// Unless told otherwise, links point to the preview server or to where
+		// the CI service deploys the output
+		explicit := *rawURL != statik.DefaultConfig().BaseURL.String()
+		flag.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "b" })
+		if serveAddr != "" {
+			config.RegisterTypes = true
+			if !explicit {
+				if config.BaseURL, err = previewURL(serveAddr); err != nil {
+					return fmt.Errorf("invalid serve address:\n%w", err)
+				}
+			}
+		} else if *detectURL && !explicit {
+			u, service, err := ciBaseURL()
+			if err != nil {
+				return fmt.Errorf("could not parse the base URL of the %s deployment:\n%w", service, err)
+			} else if u == nil {
+				log.Warn().Msg("No supported CI service detected, keeping the default base URL")
+			} else {
+				log.Info().Str("service", service).Str("url", u.String()).Msg("Using the base URL of the deployment")
+				config.BaseURL = u
+			}
+		}
+		return config.Validate()
+	}
+}
+
+// Registers the flags of builds run once or as a daemon, returning the
+// function resolving them once they have been parsed
+func buildFlags() (resolve func() error) {
+	flag.BoolVar(&showProgress, "progress", false, "Show the progress of builds on a status line")
+	notifyList := flag.String("notify", "", "Comma separated webhook, ntfy:// or mailto: targets to notify when a build finishes")
+	flag.StringVar(&notifyOn, "notify-on", notifyAlways, "When to send notifications: always or failure")
+	flag.StringVar(&smtpAddr, "smtp", "localhost:25", "SMTP server used for mailto: notifications")
+	flag.StringVar(&mailFrom, "mail-from", "statik@localhost", "Sender address of mail notifications")
+	flag.BoolVar(&daemonMode, "daemon", false, "Keep running, reloading the configuration and rebuilding on SIGHUP and notifying systemd of the service state")
+	every := flag.String("every", "", "Rebuild schedule in daemon mode, as a duration (15m) or a cron expression")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print the files which would be copied, the listings rendered and the outputs removed, without writing anything")
+	flag.StringVar(&dryRunFormat, "dry-run-format", dryRunText, "Format of the -dry-run report: text or json")
+	return func() (err error) {
+		if notifyTargets, err = parseNotifyTargets(*notifyList); err != nil {
+			return fmt.Errorf("invalid -notify value:\n%w", err)
+		}
+		if notifyOn != notifyAlways && notifyOn != notifyFailure {
+			return fmt.Errorf("invalid -notify-on value %q, expected always or failure", notifyOn)
+		}
+		if rebuildSchedule, err = parseSchedule(*every); err != nil {
+			return fmt.Errorf("invalid -every value:\n%w", err)
+		}
+		if dryRunFormat != dryRunText && dryRunFormat != dryRunJSON {
+			return fmt.Errorf("invalid -dry-run-format value %q, expected text or json", dryRunFormat)
+		}
+		return nil
+	}
+}
+
+// Registers the flags lowering the priority of the process
+func priorityFlags() (resolve func() error) {
+	flag.IntVar(&niceness, "nice", 0, "Lower the CPU priority of the process to this niceness, from 1 to 19")
+	flag.StringVar(&ioClass, "ionice", "", "Lower the I/O priority of the process to the best-effort or idle class")
+	flag.BoolVar(&background, "background", false, "Run with the lowest CPU and I/O priority, for scheduled rebuilds on shared hosts")
+	return func() error {
+		if err := parsePriority(); err != nil {
+			return fmt.Errorf("invalid priority:\n%w", err)
+		}
+		return nil
+	}
+}
+
+// Registers the flag of the health endpoint of long running commands
+func healthFlag() {
+	flag.StringVar(&healthAddr, "health", "", "Address to expose /healthz, /readyz and /metrics on while running, besides the preview server")
+}
+
+// Prints the settings the process runs with
+func logParameters(file configFile) {
+	log.Print("Running with parameters:")
+	if file.path != "" {
+		log.Print("\tConfig file:\t", file.path)
+	}
+	log.Print("\tInclude:\t", config.Include.String())
+	log.Print("\tExclude:\t", config.Exclude.String())
+	log.Print("\tRecursive:\t", config.Recursive)
+	log.Print("\tEmpty:\t\t", config.IncludeEmpty)
+	log.Print("\tConvert links:\t", config.ConvertLinks)
+	log.Print("\tResume:\t\t", config.Resume)
+	log.Print("\tSync:\t\t", config.Sync)
+	log.Print("\tLow memory:\t", config.LowMemory)
+	if config.FromJSON != "" {
+		log.Print("\tFrom JSON:\t", config.FromJSON)
+	} else if len(config.Aggregate) != 0 {
+		log.Print("\tAggregate:\t", strings.Join(config.Aggregate, ", "))
+	} else if len(config.Mounts) != 0 {
+		log.Print("\tMounts:\t\t", config.Mounts.String())
+	} else {
+		log.Print("\tSource:\t\t", config.Source)
+	}
+	log.Print("\tDstination:\t", config.Destination)
+	log.Print("\tBase URL:\t", config.BaseURL.String())
+	log.Print("\tTargets:\t", strings.Join(config.Targets, ", "))
+	log.Print("\tEnrichers:\t", strings.Join(config.Enrichers, ", "))
+}
+
+// Runs the mode selected by the flags, once they have been resolved
+func start(file configFile) {
+	if watchMode && daemonMode {
+		log.Fatal().Msg("-watch cannot be combined with -daemon")
+	}
+	if watchMode {
+		if err := config.ValidateWatch(); err != nil {
+			log.Fatal().Err(err).Msg("Invalid configuration")
+		}
+	}
+	if statik.IsRemoteDestination(config.Destination) && serveAddr != "" {
+		log.Fatal().Msg("Remote destinations cannot be served")
+	}
+	if dryRun && (watchMode || daemonMode || serveAddr != "" || buildAndServe) {
+		log.Fatal().Msg("-dry-run cannot be combined with -watch, -daemon, -serve or -build-and-serve")
+	}
+	logParameters(file)
+	if len(config.Targets) == 0 {
+		return
+	}
+	lowerPriority()
+	if dryRun {
+		runDryRun()
+		return
+	}
+	if buildAndServe {
+		defer os.RemoveAll(config.Destination)
+	}
+
+	if healthAddr != "" {
+		serveHealth(healthAddr)
+	}
+	if serveAddr != "" {
+		servePreview(serveAddr)
+	}
+	switch {
+	case watchMode:
+		runWatch()
+	case daemonMode:
+		runDaemon()
+	case serveAddr != "":
+		runServe()
+	default:
+		// Interrupting a one-off build stops it between two files
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+		if report := runBuild(ctx); report.Failed() {
+			os.Exit(1)
+		}
+	}
+}
+
+// Performs a build, keeping track of its outcome and notifying about it
+func runBuild(ctx context.Context) (report statik.Report) {
+	health.started()
+	cfg, p := config, &progress{}
+	if showProgress {
+		cfg.Events = p.event
+	}
+	report, err := statik.Generate(ctx, cfg)
+	if showProgress {
+		p.done()
+	}
+	health.finished(&report)
+	notify(&report)
+	if statik.Interrupted(err) {
+		log.Error().Err(err).Str("build", report.Build).Msg("Build interrupted, run again with -resume to complete it")
+	} else if err != nil {
+		log.Error().Err(err).Str("build", report.Build).Msg("Build failed")
+	} else {
+		log.Info().Str("build", report.Build).Int("files", report.Files).Str("size", humanize.Bytes(uint64(report.Bytes))).Msg("Generated listing")
+	}
+	if len(report.Unreadable) > 0 {
+		log.Warn().Strs("dirs", report.Unreadable).Msg("Some directories could not be read and are listed without their contents")
+	}
+	if len(report.Collisions) > 0 {
+		log.Warn().Strs("paths", report.Collisions).Msg("Some source entries collide with generated outputs")
+	}
+	if len(report.Expired) > 0 {
+		log.Info().Strs("files", report.Expired).Msg("Delisted expired files")
+	}
+	for _, f := range report.Expiring {
+		log.Warn().Str("path", f.Path).Time("expires", f.Expires).Msg("File expiring soon")
+	}
+	if len(report.Pending) > 0 {
+		log.Info().Strs("dirs", report.Pending).Msg("Time budget exhausted, run again to complete the listing")
+	}
+	return
+}
+
+// Drops a target from the list when the legacy flag disabling it is set
+func withoutTarget(targets []string, name string, drop bool) (kept []string) {
+	for _, t := range targets {
+		if !drop || t != name {
+			kept = append(kept, t)
+		}
+	}
+	return
+}
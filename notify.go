@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	notifyAlways  = "always"
+	notifyFailure = "failure"
+
+	notifyTimeout = 10 * time.Second
+)
+
+var (
+	// Targets to be notified once a build completes. Supported schemes are
+	// http(s) webhooks receiving the JSON report, ntfy://host/topic and
+	// mailto:address, the latter delivered through smtpAddr
+	notifyTargets []*url.URL
+	notifyOn      string
+	smtpAddr      string
+	mailFrom      string
+
+	notifyClient = &http.Client{Timeout: notifyTimeout}
+)
+
+// Parses a comma separated list of notification targets
+func parseNotifyTargets(raw string) (targets []*url.URL, err error) {
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		var u *url.URL
+		if u, err = url.Parse(s); err != nil {
+			return nil, fmt.Errorf("invalid notification target %q:\n%s", s, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "ntfy", "mailto":
+		default:
+			return nil, fmt.Errorf("unsupported notification target %q", s)
+		}
+		targets = append(targets, u)
+	}
+	return
+}
+
+// A one-line, human readable summary of the report
+func summary(r *statik.Report) string {
+	if r.Failed() {
+		return fmt.Sprintf("statik build of %s failed: %s", r.Source, r.Error)
+	}
+	return fmt.Sprintf("statik build of %s succeeded: %d files, %s in %s",
+		r.Source, r.Files, humanize.Bytes(uint64(r.Bytes)), r.Duration.Round(time.Millisecond))
+}
+
+// Delivers the report to all configured targets. Notification failures are
+// only logged, as they should never mask the outcome of the build itself
+func notify(r *statik.Report) {
+	if notifyOn == notifyFailure && !r.Failed() {
+		return
+	}
+	for _, target := range notifyTargets {
+		var err error
+		switch target.Scheme {
+		case "http", "https":
+			err = notifyWebhook(target, r)
+		case "ntfy":
+			err = notifyNtfy(target, r)
+		case "mailto":
+			err = notifyMail(target, r)
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("target", target.Redacted()).Msg("Could not deliver build notification")
+		}
+	}
+}
+
+func post(u string, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %s", res.Status)
+	}
+	return nil
+}
+
+func notifyWebhook(target *url.URL, r *statik.Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return post(target.String(), "application/json", data, nil)
+}
+
+// Publishes the summary to a ntfy topic, over https
+func notifyNtfy(target *url.URL, r *statik.Report) error {
+	u := *target
+	u.Scheme = "https"
+	header := http.Header{}
+	header.Set("Title", "statik build "+r.Status)
+	if r.Failed() {
+		header.Set("Priority", "high")
+		header.Set("Tags", "warning")
+	}
+	return post(u.String(), "text/plain", []byte(summary(r)), header)
+}
+
+func notifyMail(target *url.URL, r *statik.Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n\r\n%s\r\n",
+		mailFrom, target.Opaque, summary(r), summary(r), data)
+	return smtp.SendMail(smtpAddr, nil, mailFrom, []string{target.Opaque}, []byte(msg))
+}
@@ -0,0 +1,98 @@
+package statik
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// The statik.json files of the independent builds listed by the aggregated
+// root index, each published under the same domain
+var aggregatedBuilds []string
+
+// The fields of the root statik.json of a build shown in an aggregated index
+type aggregatedBuild struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Time       string `json:"time"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// Reads the root listing of each aggregated build as a directory linking to
+// where the build is published
+func readAggregatedBuild(p string) (dir Directory, err error) {
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		return dir, fmt.Errorf("could not read metadata %s:\n%w", p, err)
+	}
+	var build aggregatedBuild
+	if err = json.Unmarshal(raw, &build); err != nil {
+		return dir, fmt.Errorf("could not parse metadata %s:\n%w", p, err)
+	}
+	if !entryName(build.Name) {
+		return dir, fmt.Errorf("invalid build name %q in the metadata %s", build.Name, p)
+	}
+	dir = Directory{
+		Name:       build.Name,
+		SrcPath:    p,
+		DstPath:    path.Join(dstDir, build.Name),
+		Path:       build.Name,
+		Size:       humanize.Bytes(uint64(build.TotalBytes)),
+		Bytes:      build.TotalBytes,
+		TotalBytes: build.TotalBytes,
+		ModTime:    parseTime(build.Time),
+		Mode:       os.ModeDir | os.ModePerm,
+		GenTime:    time.Now(),
+	}
+	if dir.URL, err = url.Parse(build.URL); err != nil || build.URL == "" {
+		dir.URL = withBaseURL(build.Name)
+	}
+	return dir, nil
+}
+
+// Lists the aggregated builds in a root index. The builds themselves are
+// never visited, as their listings are theirs to generate
+func walkAggregate(visit func(*Directory) error) (dir Directory, fz []FuzzyFile, err error) {
+	name := path.Base(dstDir)
+	if len(baseURL.Path) > 1 {
+		name = path.Base(strings.TrimSuffix(baseURL.Path, "/"))
+	}
+	dir = Directory{
+		Name:    name,
+		SrcPath: dstDir,
+		DstPath: dstDir,
+		URL:     withBaseURL("."),
+		Path:    ".",
+		Size:    humanize.Bytes(0),
+		Mode:    os.ModeDir | os.ModePerm,
+		GenTime: time.Now(),
+		View:    defaultView,
+	}
+	names := map[string]string{}
+	for _, p := range aggregatedBuilds {
+		var build Directory
+		if build, err = readAggregatedBuild(p); err != nil {
+			return
+		}
+		if other, ok := names[build.Name]; ok {
+			return dir, nil, fmt.Errorf("the builds of %s and %s are both named %s", other, p, build.Name)
+		}
+		names[build.Name] = p
+		dir.TotalBytes += build.TotalBytes
+		if build.ModTime.After(dir.ModTime) {
+			dir.ModTime = build.ModTime
+		}
+		dir.Directories = append(dir.Directories, build)
+	}
+	if enableSort {
+		sortByName(dir.Directories)
+	}
+	err = visit(&dir)
+	return
+}
@@ -0,0 +1,141 @@
+package statik
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	aliasSymlink  = "symlink"
+	aliasCopy     = "copy"
+	aliasRedirect = "redirect"
+)
+
+// A rule generating an alias in each directory pointing to the newest file
+// matching a glob, e.g. myapp-*.tar.gz=myapp-latest.tar.gz:symlink
+type aliasRule struct {
+	glob, alias, mode string
+}
+
+// AliasRules is a flag.Value collecting -alias rules
+type AliasRules []aliasRule
+
+var latestRules AliasRules
+
+func (r *AliasRules) String() string {
+	var rules []string
+	for _, rule := range *r {
+		rules = append(rules, rule.glob+"="+rule.alias+":"+rule.mode)
+	}
+	return strings.Join(rules, ",")
+}
+
+func (r *AliasRules) Set(s string) error {
+	glob, target, ok := strings.Cut(s, "=")
+	if !ok || glob == "" || target == "" {
+		return fmt.Errorf("expected a rule in the glob=alias[:mode] form, got %q", s)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid glob %q:\n%w", glob, err)
+	}
+	alias, mode, _ := strings.Cut(target, ":")
+	switch mode {
+	case "":
+		mode = aliasSymlink
+	case aliasSymlink, aliasCopy, aliasRedirect:
+	default:
+		return fmt.Errorf("unknown alias mode %q, expected symlink, copy or redirect", mode)
+	}
+	if strings.Contains(alias, "/") {
+		return fmt.Errorf("alias %q must be a plain file name", alias)
+	}
+	*r = append(*r, aliasRule{glob, alias, mode})
+	return nil
+}
+
+// Reports whether a is newer than b, by version when both carry one in the
+// same group or by modification time otherwise
+func newer(a, b *File) bool {
+	va, vb := parseVersion(a.FuzzyFile.Name), parseVersion(b.FuzzyFile.Name)
+	if va != nil && vb != nil && va.group() == vb.group() {
+		return va.compare(vb) > 0
+	}
+	return a.ModTime.After(b.ModTime)
+}
+
+// Creates the aliases configured by the latest rules in a directory and lists
+// them alongside the other files
+func writeAliases(dir *Directory) (err error) {
+	existing := map[string]bool{}
+	for _, f := range dir.Files {
+		existing[f.FuzzyFile.Name] = true
+	}
+	for _, d := range dir.Directories {
+		existing[d.Name] = true
+	}
+
+	for _, rule := range latestRules {
+		if existing[rule.alias] {
+			continue
+		}
+		var target *File
+		for i := range dir.Files {
+			f := &dir.Files[i]
+			if ok, _ := path.Match(rule.glob, f.FuzzyFile.Name); ok && f.MIME != linkMIME && (target == nil || newer(f, target)) {
+				target = f
+			}
+		}
+		if target == nil {
+			continue
+		}
+
+		alias := *target
+		alias.FuzzyFile.Name = rule.alias
+		alias.FuzzyFile.Path = path.Join(dir.Path, rule.alias)
+		alias.DstPath = path.Join(dir.DstPath, rule.alias)
+		alias.URL = withBaseURL(publishedPath(alias.FuzzyFile.Path))
+		alias.Latest = false
+		alias.inode = nil
+		// Objects of remote sources, and sources which are not copied, are not
+		// available in the output to be linked
+		mode := rule.mode
+		if remoteSource != "" || noCopy {
+			mode = aliasRedirect
+		}
+		switch mode {
+		case aliasSymlink:
+			// Targets stored as objects only exist under the objects directory
+			link := target.FuzzyFile.Name
+			if isObject(target.FuzzyFile) {
+				link = path.Join(objectsDirName, path.Base(target.DstPath))
+				if dir.Path != "." {
+					link = strings.Repeat("../", strings.Count(dir.Path, "/")+1) + link
+				}
+			}
+			os.Remove(alias.DstPath)
+			if err = os.Symlink(link, alias.DstPath); err != nil {
+				return fmt.Errorf("could not create alias %s:\n%w", alias.DstPath, err)
+			}
+		case aliasCopy:
+			if err = copyFile(alias.FuzzyFile); err != nil {
+				return err
+			}
+		case aliasRedirect:
+			if err = writeRedirect(alias.DstPath, target.URL.String(), dir.Mode); err != nil {
+				return err
+			}
+		}
+		log.Printf("Aliased %s to %s", alias.DstPath, target.FuzzyFile.Name)
+		existing[rule.alias] = true
+		dir.Files = append(dir.Files, alias)
+	}
+	if enableSort {
+		sortByName(dir.Files)
+	}
+	arrangeEntries(dir)
+	return nil
+}
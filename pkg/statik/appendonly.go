@@ -0,0 +1,53 @@
+package statik
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Never delete nor overwrite the copies already in the destination, only
+	// adding new ones and regenerating the listings
+	appendOnly bool
+	// Guards problems, which the jobs copying files report rewrites to
+	rewritesMu sync.Mutex
+)
+
+// Reports whether the copy of a file is already in an append-only
+// destination, in which case it is never written again. Copies which differ
+// in size from their source are reported as problems and left untouched
+func writtenOnce(file File) (bool, error) {
+	if !appendOnly {
+		return false, nil
+	}
+	f := file.FuzzyFile
+	info, err := os.Lstat(verbatimPath(f.DstPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("could not stat %s:\n%w", f.DstPath, err)
+	}
+	if f.symlink == "" && info.Mode().IsRegular() && info.Size() != file.Bytes {
+		err = fmt.Errorf("%w: %s", ErrWriteOnce, f.Path)
+		log.Warn().Err(err).Msg("The source of an archived file has changed")
+		warn(err, f.Path)
+		rewritesMu.Lock()
+		problems = append(problems, err)
+		rewritesMu.Unlock()
+	}
+	// Archived copies are still part of the build, not left over
+	manifest.mu.Lock()
+	manifest.seen[f.Path] = true
+	manifest.mu.Unlock()
+	return true, nil
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(verbatimPath(p))
+	return err == nil
+}
@@ -0,0 +1,89 @@
+package statik
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const archiveFileName = "archive.html"
+
+// Files last modified longer than this ago are retired from listings
+var archiveAfter time.Duration
+
+// ParseAge parses an age, either as a Go duration or as a number of days (d), weeks (w)
+// or years (y)
+func ParseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour, "y": 365 * 24 * time.Hour}
+	if unit, ok := units[s[len(s)-1:]]; ok {
+		n, err := strconv.Atoi(strings.TrimSpace(s[:len(s)-1]))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// Removes the fuzzy entries for the given paths
+func dropFuzzy(fz []FuzzyFile, paths map[string]bool) []FuzzyFile {
+	kept := fz[:0]
+	for _, f := range fz {
+		if !paths[f.Path] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// Reports whether a file is older than the archive age
+func dueForArchive(f *File) bool {
+	return archiveAfter != 0 && !f.ModTime.After(time.Now().Add(-archiveAfter))
+}
+
+// Moves the files older than the archive age out of the listing and into the
+// tombstones of the directory, which keep their metadata but are not copied.
+// Returns the paths of the retired files
+func retireFiles(dir *Directory) (retired map[string]bool) {
+	if archiveAfter == 0 {
+		return nil
+	}
+	files := dir.Files[:0]
+	for _, f := range dir.Files {
+		if !dueForArchive(&f) {
+			files = append(files, f)
+			continue
+		}
+		if retired == nil {
+			retired = map[string]bool{}
+		}
+		retired[f.FuzzyFile.Path] = true
+		dir.TotalBytes -= f.Bytes
+		dir.Archived = append(dir.Archived, f)
+	}
+	dir.Files = files
+	return
+}
+
+// Renders the list of tombstones of a directory into its archive page
+func writeArchiveHTML(dir *Directory) error {
+	if len(dir.Archived) == 0 {
+		return nil
+	}
+	payload := newPayload(dir)
+	payload.Archive = true
+	payload.ArchiveURL = nil
+	payload.Root.Directories = []Directory{{Name: "..", Path: dir.Path, URL: dir.URL}}
+	payload.Root.Files = dir.Archived
+	payload.Licenses = payload.Root.hasLicenses()
+	return writePage(path.Join(dir.DstPath, archiveFileName), payload)
+}
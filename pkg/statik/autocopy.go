@@ -0,0 +1,68 @@
+package statik
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Reflink when the destination filesystem supports it, hard link when the
+// outputs need not differ from their sources and copy otherwise
+const CopyAuto = "auto"
+
+// The method CopyAuto settled on for the current build, probed with the first
+// file carried over
+var (
+	autoMethod     string
+	autoMethodOnce sync.Once
+)
+
+// The method files are carried over with, resolving CopyAuto on the first call
+// of a build by trying to clone and then link f next to its output
+func carryMethod(f FuzzyFile) string {
+	if copyMethod != CopyAuto {
+		return copyMethod
+	}
+	autoMethodOnce.Do(func() {
+		autoMethod = probeCopyMethod(f)
+		log.Info().Str("method", autoMethod).Msg("Picked how to carry files over")
+	})
+	return autoMethod
+}
+
+// Whether outputs can share the inode of their source, having no permissions,
+// owner or labels of their own
+func linkable() bool {
+	return fileMode == 0 && chownUID == -1 && chownGID == -1 && !preserveXattrs && selinuxContext == ""
+}
+
+func probeCopyMethod(f FuzzyFile) string {
+	if !osSource {
+		return CopyContents
+	}
+	src, err := os.Open(f.SrcPath)
+	if err != nil {
+		return CopyContents
+	}
+	defer src.Close()
+	probe, err := os.CreateTemp(filepath.Dir(f.DstPath), ".statik-probe-*")
+	if err != nil {
+		return CopyContents
+	}
+	defer os.Remove(probe.Name())
+	defer probe.Close()
+	if cloneFile(probe, src) == nil {
+		return CopyReflink
+	}
+	if !linkable() {
+		return CopyContents
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	if os.Link(f.SrcPath, probe.Name()) == nil {
+		return CopyLink
+	}
+	return CopyContents
+}
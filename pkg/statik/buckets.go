@@ -0,0 +1,55 @@
+package statik
+
+import (
+	"path"
+	"strings"
+)
+
+// The buckets rows are classified in by size, exposed to themes as the
+// size-<bucket> class of each row
+const (
+	EmptySize  = "empty"
+	TinySize   = "tiny"
+	SmallSize  = "small"
+	MediumSize = "medium"
+	LargeSize  = "large"
+	HugeSize   = "huge"
+)
+
+// The upper bounds of the size buckets, in bytes
+var sizeBuckets = []struct {
+	below  int64
+	bucket string
+}{
+	{1, EmptySize},
+	{10_000, TinySize},
+	{1_000_000, SmallSize},
+	{100_000_000, MediumSize},
+	{1_000_000_000, LargeSize},
+}
+
+func sizeBucket(bytes int64) string {
+	for _, b := range sizeBuckets {
+		if bytes < b.below {
+			return b.bucket
+		}
+	}
+	return HugeSize
+}
+
+// The size bucket of the file, e.g. small or huge
+func (f File) SizeBucket() string { return sizeBucket(f.Bytes) }
+
+// The size bucket of everything the directory holds
+func (d Directory) SizeBucket() string { return sizeBucket(d.TotalBytes) }
+
+// The lowercase extension of the file without the leading dot, empty for
+// names without one such as dotfiles
+func (f File) Extension() string {
+	name := f.FuzzyFile.Name
+	ext := path.Ext(name)
+	if ext == name {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(ext, "."))
+}
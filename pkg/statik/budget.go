@@ -0,0 +1,42 @@
+package statik
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// When the time budget of the current build runs out, zero for no budget.
+	// Subtrees are then no longer walked, leaving them to the next run
+	budgetDeadline time.Time
+	// The subtrees left for a later run once the budget ran out, by path
+	pendingDirs []string
+	// Whether a subtree has been written in this run, as each run goes
+	// through at least one no matter its budget
+	budgetProgress bool
+)
+
+// Reports whether the time budget of the build has run out
+func budgetSpent() bool {
+	return budgetProgress && !budgetDeadline.IsZero() && time.Now().After(budgetDeadline)
+}
+
+// Leaves a subtree to a later run, as the budget of this one has run out
+func postpone(rel string) {
+	if len(pendingDirs) == 0 {
+		log.Info().Str("path", rel).Msg("Time budget exhausted, leaving the remaining subtrees to the next run")
+	}
+	pendingDirs = append(pendingDirs, rel)
+}
+
+// Sorts the walk order so that the subtrees whose listings were checked the
+// longest ago, or never, come first. Others keep their order
+func stalestFirst(order []int, rel func(i int) string) {
+	checked := map[int]time.Time{}
+	for _, i := range order {
+		checked[i] = manifest.Checked(rel(i))
+	}
+	sort.SliceStable(order, func(i, j int) bool { return checked[order[i]].Before(checked[order[j]]) })
+}
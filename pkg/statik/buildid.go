@@ -0,0 +1,51 @@
+package statik
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Crockford's base32 alphabet, as used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// The ULID identifying the current build, embedded in its outputs so that
+// served pages can be told apart and matched with the build logs
+var buildID string
+
+// Generates a ULID: 48 bits of milliseconds since the epoch followed by 80
+// random bits, encoded as 26 characters which sort by time
+func newBuildID(t time.Time) string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(t.UnixMilli())<<16)
+	// The reader never fails on supported platforms, and a partially random
+	// id is still good enough to correlate builds
+	_, _ = rand.Read(id[6:])
+
+	var out [26]byte
+	// The 128 bits are encoded 5 at a time, from the least significant end
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// The build id as a UUID, which ULIDs map to bit for bit
+func buildUUID() string {
+	var hi, lo uint64
+	for _, c := range buildID {
+		v := uint64(strings.IndexRune(crockford, c))
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | v
+	}
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], hi)
+	binary.BigEndian.PutUint64(id[8:], lo)
+	h := hex.EncodeToString(id[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,114 @@
+package statik
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The formats the files of a directory can be bundled in
+const ZipBundle = "zip"
+
+// The format of the archive bundling the files of each directory, none when
+// empty
+var bundleFormat string
+
+// The name of the archive bundling the files of a directory
+func bundleName(dir *Directory) string {
+	return dir.Name + "." + bundleFormat
+}
+
+func bundled(dir *Directory) bool {
+	return bundleFormat != "" && remoteSource == "" && len(dir.Files) != 0
+}
+
+// Points a directory to the archive of its files, if it gets one
+func linkBundle(dir *Directory) {
+	if bundled(dir) {
+		dir.Bundle = withBaseURL(publishedPath(path.Join(dir.Path, bundleName(dir))))
+	}
+}
+
+// Writes the archive of the files of a directory, keeping the one written by
+// a previous run when the listing is unchanged. Directories without files
+// have theirs removed
+func writeBundle(dir *Directory) (err error) {
+	dst := path.Join(dir.DstPath, bundleName(dir))
+	if !bundled(dir) {
+		if err = os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not remove archive %s:\n%w", dst, err)
+		}
+		return nil
+	}
+	if _, err = os.Stat(dst); err == nil && dir.unchanged {
+		return nil
+	}
+
+	// The archive only replaces the previous one once complete
+	tmp := dst + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile)
+	if err != nil {
+		return fmt.Errorf("could not create archive %s:\n%w", tmp, err)
+	}
+	defer os.Remove(tmp)
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, flate.BestSpeed)
+	})
+	for i := range dir.Files {
+		f := &dir.Files[i]
+		if f.MIME == linkMIME {
+			continue
+		}
+		if err = buildCtx.Err(); err != nil {
+			return
+		}
+		if err = addToZip(zw, f); err != nil {
+			return
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return fmt.Errorf("could not write archive %s:\n%w", tmp, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("could not write archive %s:\n%w", tmp, err)
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return fmt.Errorf("could not move archive %s into place:\n%w", dst, err)
+	}
+	log.Printf("Generated %s", dst)
+	return finalizeFile(dst)
+}
+
+// Appends a file to an archive. Text is compressed, other files most likely
+// are already and are stored as they are
+func addToZip(zw *zip.Writer, f *File) (err error) {
+	header := &zip.FileHeader{Name: f.FuzzyFile.Name, Modified: f.ModTime, Method: zip.Store}
+	header.SetMode(f.FuzzyFile.Mode)
+	if isTextMIME(f.MIME) {
+		header.Method = zip.Deflate
+	}
+	var (
+		w   io.Writer
+		src fs.File
+	)
+	if w, err = zw.CreateHeader(header); err != nil {
+		return fmt.Errorf("could not add %s to the archive:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	if src, err = srcFS.Open(f.FuzzyFile.Path); err != nil {
+		return fmt.Errorf("could not open %s for reading:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	defer src.Close()
+	if _, err = io.Copy(w, throttled(buildCtx, src)); err != nil {
+		return fmt.Errorf("could not add %s to the archive:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package statik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Amount of data copied between two checks for cancellation
+	copyChunkSize = 8 << 20
+	// Size of the buffers used to copy from sources other than local files
+	copyBufferSize = 256 << 10
+)
+
+// Deadline of the copy of a single file, zero meaning no limit
+var copyTimeout time.Duration
+
+// Buffers reused across copies which cannot be handed to the kernel
+var copyBuffers = sync.Pool{New: func() any { return new([copyBufferSize]byte) }}
+
+// Derives a cancellable context, enforcing the given timeout unless it is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Interrupted reports whether an error returned by a build is due to its
+// context being cancelled or its deadline being exceeded
+func Interrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Copies n bytes from src into dst, or all of it when n is negative, checking
+// for cancellation and keeping to the I/O limit between chunks. Chunks are limited readers, which still
+// let *os.File take the copy_file_range fast path, while anything else goes
+// through a pooled buffer
+func copyChunks(ctx context.Context, dst io.Writer, src io.Reader, n int64) error {
+	if _, ok := src.(*os.File); !ok {
+		buf := copyBuffers.Get().(*[copyBufferSize]byte)
+		defer copyBuffers.Put(buf)
+		// Hide ReadFrom, which would otherwise allocate a buffer of its own
+		dst = struct{ io.Writer }{dst}
+		return copyBuffered(ctx, dst, src, n, buf[:])
+	}
+	return copyBuffered(ctx, dst, src, n, nil)
+}
+
+func copyBuffered(ctx context.Context, dst io.Writer, src io.Reader, n int64, buf []byte) error {
+	for n != 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk := int64(copyChunkSize)
+		if ioLimit > 0 {
+			chunk = throttleChunkSize
+		}
+		if n > 0 && n < chunk {
+			chunk = n
+		}
+		if err := throttleIO(ctx, chunk); err != nil {
+			return err
+		}
+		written, err := io.CopyBuffer(dst, io.LimitReader(src, chunk), buf)
+		if n > 0 {
+			n -= written
+		}
+		if err != nil {
+			return err
+		}
+		// A short chunk means the source has been drained
+		if written < chunk {
+			if n < 0 {
+				return nil
+			}
+			return io.ErrUnexpectedEOF
+		}
+	}
+	return nil
+}
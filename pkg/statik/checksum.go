@@ -0,0 +1,94 @@
+package statik
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Number of hex digits of a checksum shown in listings
+const shortChecksumLength = 12
+
+var (
+	checksumAlgorithm string
+
+	checksumAlgorithms = map[string]func() hash.Hash{
+		"md5":    md5.New,
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+)
+
+func validChecksumAlgorithm(name string) bool {
+	_, ok := checksumAlgorithms[name]
+	return name == "" || ok
+}
+
+// Hashes the contents of a file, returning the digest prefixed with the name
+// of the algorithm, as in sha256:e3b0c442...
+func checksumFile(f FuzzyFile) (string, error) {
+	file, err := srcFS.Open(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s for hashing:\n%w", f.SrcPath, err)
+	}
+	defer file.Close()
+
+	h := checksumAlgorithms[checksumAlgorithm]()
+	if _, err = io.Copy(h, throttled(buildCtx, file)); err != nil {
+		return "", fmt.Errorf("could not hash %s:\n%w", f.SrcPath, err)
+	}
+	return checksumAlgorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// The first few digits of the checksum, without the algorithm prefix
+func (f File) ShortChecksum() string {
+	_, digest, _ := strings.Cut(f.Checksum, ":")
+	if len(digest) > shortChecksumLength {
+		return digest[:shortChecksumLength]
+	}
+	return digest
+}
+
+// Lists the checksums of the files of each directory in the format read by
+// sha256sum -c and the like, e.g. SHA256SUMS
+type checksumsTarget struct{}
+
+func (checksumsTarget) Name() string { return "checksums" }
+func (checksumsTarget) Start() error { return nil }
+
+func checksumListName() string { return strings.ToUpper(checksumAlgorithm) + "SUMS" }
+
+func (checksumsTarget) Outputs(dir *Directory) []string {
+	if checksumAlgorithm == "" || remoteSource != "" || len(dir.Files) == 0 {
+		return nil
+	}
+	return []string{checksumListName()}
+}
+
+func (t checksumsTarget) Directory(dir *Directory) error {
+	if dir.unchanged || len(t.Outputs(dir)) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	for _, f := range dir.Files {
+		if _, digest, ok := strings.Cut(f.Checksum, ":"); ok {
+			fmt.Fprintf(&b, "%s  %s\n", digest, f.FuzzyFile.Name)
+		}
+	}
+	p := path.Join(dir.DstPath, checksumListName())
+	if err := os.WriteFile(p, []byte(b.String()), regularFile); err != nil {
+		return fmt.Errorf("could not write checksum list %s:\n%w", p, err)
+	}
+	return finalizeFile(p)
+}
+
+func (checksumsTarget) Finish(*Directory, []FuzzyFile) error { return nil }
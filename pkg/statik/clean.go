@@ -0,0 +1,41 @@
+package statik
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Clean removes a generated listing, along with everything else in its
+// directory and the bookkeeping kept next to it. Directories with neither a build manifest nor published
+// generations are left alone, as they have not been generated by statik,
+// returning ErrNotOutput
+func Clean(dst string) (err error) {
+	generating.Lock()
+	defer generating.Unlock()
+	if IsRemoteDestination(dst) {
+		return fmt.Errorf("%w: %s is a remote destination", ErrNotOutput, dst)
+	}
+	if dst, err = filepath.Abs(dst); err != nil {
+		return fmt.Errorf("could not resolve output directory %s:\n%w", dst, err)
+	}
+	manifest := manifestPath(dst)
+	if _, err = os.Stat(manifest); errors.Is(err, fs.ErrNotExist) {
+		if _, err = CurrentGeneration(dst); err != nil {
+			return fmt.Errorf("%w: %s has no build manifest", ErrNotOutput, dst)
+		}
+	} else if err != nil {
+		return fmt.Errorf("could not stat build manifest in %s:\n%w", dst, err)
+	}
+	if err = os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("cannot clear output directory: %s\n%w", dst, err)
+	}
+	for _, p := range []string{manifest, siblingDir(dst, trashSuffix)} {
+		if err = os.RemoveAll(p); err != nil {
+			return fmt.Errorf("cannot remove %s:\n%w", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package statik
+
+import (
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The ways files are carried over into the destination
+const (
+	// Write a full copy of the contents
+	CopyContents = "copy"
+	// Hard link the output to the source, sharing its permissions and owner
+	CopyLink = "link"
+	// Share the data blocks with the source until either is modified, on
+	// filesystems supporting it such as Btrfs and XFS
+	CopyReflink = "reflink"
+)
+
+// How files are carried over into the destination, falling back to copying
+// them whenever the source and destination are on different filesystems
+var copyMethod = CopyContents
+
+func validCopyMethod(method string) bool {
+	switch method {
+	case "", CopyContents, CopyLink, CopyReflink, CopyAuto:
+		return true
+	}
+	return false
+}
+
+// Hard links a source file into the destination, reporting whether it could.
+// Links only work within a single filesystem, anything else is copied
+func linkSource(f FuzzyFile) bool {
+	if carryMethod(f) != CopyLink || !osSource {
+		return false
+	}
+	if err := os.Link(f.SrcPath, f.DstPath); err != nil {
+		log.Debug().Err(err).Str("path", f.Path).Msg("Could not link, copying instead")
+		return false
+	}
+	return true
+}
+
+// Clones the contents of a source file into its output, reporting whether it
+// could. Anything which cannot be cloned is copied
+func reflinkSource(dst, src *os.File) bool {
+	if carryMethod(FuzzyFile{}) != CopyReflink {
+		return false
+	}
+	if err := cloneFile(dst, src); err != nil {
+		log.Debug().Err(err).Str("path", src.Name()).Msg("Could not clone, copying instead")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package statik
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Shares the data blocks of src with dst through the FICLONE ioctl
+func cloneFile(dst, src *os.File) error {
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+}
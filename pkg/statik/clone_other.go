@@ -0,0 +1,13 @@
+//go:build !linux
+
+package statik
+
+import (
+	"errors"
+	"os"
+)
+
+// Cloning files is not supported on this platform
+func cloneFile(dst, src *os.File) error {
+	return errors.New("reflinks are not supported on this platform")
+}
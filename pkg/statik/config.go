@@ -0,0 +1,680 @@
+package statik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+)
+
+// Config holds all the options of a build. The zero value is not usable on
+// its own, start from DefaultConfig instead
+type Config struct {
+	// The directory to list and the one outputs are written into. The
+	// destination can also be an s3://, sftp:// or webdav(s):// URL, which
+	// the output is uploaded to with rclone
+	Source      string
+	Destination string
+	// The filesystem to read the source from instead of the Source directory,
+	// e.g. an embed.FS or a zip.Reader. Source is then only used to name the
+	// listed files in logs and errors
+	FS fs.FS
+	// Directories listed as one tree instead of Source, each published at
+	// its mount point. Directories mounted at the same point are merged,
+	// the first one winning on clashing names
+	Mounts Mounts
+	// The URL the destination is served at, which all links are built from
+	BaseURL *url.URL
+	// Link the listings, pages and metadata to each other relatively, e.g.
+	// ./sub/ and ../, so that the output works from any host or file://.
+	// Sitemaps, feeds and the like keep absolute URLs
+	Relative bool
+
+	// Only names matching Include and not matching Exclude are listed
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+	// Names of the .gitignore style files honored in each directory, which
+	// apply to its whole subtree. Files later in the list override the ones
+	// before them, and are never listed themselves
+	IgnoreFiles []string
+
+	Recursive    bool
+	IncludeEmpty bool
+	Sort         bool
+	// Convert .link files to anchor tags
+	ConvertLinks bool
+	// The rel attribute of anchors to links pointing to other hosts
+	LinkRel string
+	// Render a HEADER.md or README.md atop the listing of its directory
+	Readme bool
+	// Generate WebP thumbnails of JPEG, PNG and GIF images, which listings
+	// show as a gallery
+	Thumbnails bool
+	// Generate a page with the highlighted source next to each text file,
+	// named after it with an .html suffix
+	Previews bool
+	// Bundle the files of each directory into an archive named after it, so
+	// that they can be downloaded at once. Only zip is supported, none when
+	// empty
+	Bundle string
+
+	// Names of the targets to generate, in order: html, json, checksums,
+	// sitemap, feed, search, inventory, ndjson, sbom, problems, tree,
+	// freshness or any registered with RegisterTarget
+	Targets []string
+	// Number of the most recently modified files listed in the feed target,
+	// 20 when zero
+	FeedEntries int
+	// The format of the inventory target, csv or tsv, csv when empty
+	InventoryFormat string
+	// The format of the sbom target, spdx or cyclonedx, spdx when empty
+	SBOMFormat string
+	// Names of the enrichers filling in the metadata of each file, in order:
+	// mime, checksum, exif, git, sidecar, description, license or any
+	// registered with RegisterEnricher. The checksum one is added when
+	// Checksum is set
+	Enrichers []string
+	// Bytes read from the head of each file by the mime enricher to detect
+	// its type, 3072 when zero. This is the limit of the mimetype package,
+	// shared with the rest of the process
+	MIMELimit int
+	// Extensions of the files whose type the mime enricher tells by their
+	// name alone, without reading them, e.g. .iso
+	MIMEByExtension []string
+	// Paths of a custom listing page template and stylesheet, the embedded
+	// ones are used when empty
+	PageTemplate string
+	Stylesheet   string
+	// The view of directories without a .statik-view marker file naming
+	// theirs: list, gallery or one of ViewTemplates
+	View string
+	// Templates of custom views, or replacing the built-in ones, by name
+	ViewTemplates ViewTemplates
+	// The density listings are shown with by default, compact or detailed.
+	// Visitors can switch it, their choice being remembered by the browser
+	Density string
+	// Leave all scripts out of the pages, for strict no-script policies.
+	// Sorting, switching the density and the lightbox are then unavailable
+	NoJS bool
+
+	// Resume an interrupted build instead of starting over
+	Resume bool
+	// Update the existing output in place instead of wiping it, copying only
+	// the files and rewriting only the listings which changed. Files are told
+	// apart by size and modification time, or by their checksum when one is
+	// computed
+	Sync bool
+	// Never delete nor overwrite the files already copied into Destination,
+	// only adding new ones and regenerating the listings, for write-once
+	// archives. Copies whose source has changed are reported as problems
+	AppendOnly bool
+	// Only write the listings and metadata, linking the files to where
+	// BaseURL already serves the source from instead of copying them
+	NoCopy bool
+	// Copy each file once under objects/ at the root of Destination, named by
+	// its checksum, and link the listings to it. Requires Checksum
+	Objects bool
+	// Trade speed for a smaller memory footprint: files are inspected, copied
+	// and rendered one at a time, pages and the search index are streamed to
+	// disk and subdirectories drop their listings once rendered. Outputs
+	// spanning the whole tree, such as the sitemap, are still built in memory
+	LowMemory bool
+	// Number of files inspected and copied at once, one when zero or with
+	// LowMemory
+	Jobs int
+	// Megabytes per second read from the source for hashing and copying,
+	// across all jobs, zero meaning no limit
+	IOLimit float64
+
+	// Permissions of all outputs, zero keeps the defaults
+	FileMode fs.FileMode
+	DirMode  fs.FileMode
+	// Ownership of all outputs, -1 leaves the respective id untouched
+	UID, GID       int
+	PreserveXattrs bool
+	SELinuxContext string
+	// Attributes of source files and directories carried over to their
+	// copies: PreserveTimes, PreserveMode and PreserveOwner
+	Preserve []string
+
+	OneFileSystem bool
+	Hardlinks     bool
+	// How files are carried over into the destination: copy, link, reflink
+	// or auto. Files on another filesystem are always copied
+	CopyMethod string
+	// Link copies to their sources instead: hard, reflink or auto, standing
+	// for the matching CopyMethod, which must be left to copy
+	Link string
+
+	// Move orphaned outputs to a trash folder next to the destination when
+	// resuming
+	Trash          bool
+	TrashRetention time.Duration
+	// How symbolic links are handled: FollowSymlinks or PreserveSymlinks.
+	// By default links to files are copied as their targets and links to
+	// directories are left out
+	Symlinks string
+
+	// Write each build into a new timestamped generation inside Destination,
+	// published by pointing its current symlink at it, and keep this many of
+	// them around to roll back to. Zero writes into Destination itself
+	Keep int
+
+	// An rclone remote or an HTTP listing to list instead of Source, with the
+	// public URL its objects are reachable at
+	Remote    string
+	RemoteURL *url.URL
+	// A statik.json of a previous build, or the output directory holding it,
+	// to regenerate the listings from instead of Source. The files keep the
+	// URLs recorded in the metadata unless RemoteURL moves them
+	FromJSON string
+	// The statik.json files, or the output directories holding them, of
+	// independent builds published under the same domain. Instead of
+	// listing Source, a root index linking to each of them is written into
+	// Destination, in place next to whatever else it holds
+	Aggregate []string
+	// The rclone binary, for remote sources and destinations
+	Rclone string
+	// Push the output to this rsync destination, e.g. user@host:/var/www,
+	// after each successful build. DeployCommand, run through sh with the
+	// output directory and the target as its arguments, replaces rsync
+	Deploy        string
+	DeployCommand string
+
+	Semver     bool
+	LatestStub bool
+	Aliases    AliasRules
+	Restrict   RestrictRules
+	// Subtrees walked, copied and rendered before their siblings, so that
+	// the most visited parts of the site come back first during a rebuild
+	Prioritize Globs
+	// Files whose extension is hidden from their displayed name, matched by
+	// name or, for globs with a slash, by path. URLs keep the extension
+	HideExtensions Globs
+	// Directories published under a random token instead of their name and
+	// left out of their parent's listing, the search index and the sitemap,
+	// for sharing without authentication
+	Unlisted Globs
+	// Where the tokens of unlisted directories are kept across builds,
+	// defaulting to a file next to the destination. Anyone reading it can
+	// reach every unlisted directory, so it must not be published
+	UnlistedManifest string
+	// Subtrees on slow mounts, inspecting and copying fewer files at once
+	// than Jobs
+	Slow SlowRules
+	// Checksum algorithm, empty to skip checksums
+	Checksum string
+	// Files older than this are retired into an archive page, zero disables it
+	ArchiveAfter time.Duration
+	// Files whose sidecar expiry date has passed are delisted, and with
+	// DropExpired not copied either. The ones expiring within ExpiryNotice,
+	// a week when zero, are listed in the report
+	DropExpired  bool
+	ExpiryNotice time.Duration
+	// Web servers to generate a configuration snippet for
+	ServerConfigs []string
+	// Register the detected content types with the mime package, for serving
+	// the output from the same process
+	RegisterTypes bool
+
+	// Deadlines of a whole build and of the copy of a single file, zero
+	// meaning no limit
+	Timeout     time.Duration
+	CopyTimeout time.Duration
+	// Stop walking new subtrees once a build has taken this long, writing the
+	// output in place and leaving the rest to the next run, which walks the
+	// subtrees checked the longest ago first. Zero meaning no budget
+	TimeBudget time.Duration
+
+	// Called with the progress of builds, one event at a time
+	Events func(Event)
+
+	// Keep the generated tree around for incremental updates
+	keepTree bool
+}
+
+// DefaultConfig returns the configuration the command line starts from
+func DefaultConfig() Config {
+	return Config{
+		Source:          defaultSrc,
+		Destination:     defaultDst,
+		BaseURL:         &url.URL{Scheme: "http", Host: "localhost"},
+		Include:         regexp.MustCompile(".*"),
+		Exclude:         regexp.MustCompile(`\.git(hub)?`),
+		IgnoreFiles:     []string{".gitignore", ".statikignore"},
+		Recursive:       true,
+		Sort:            true,
+		Readme:          true,
+		View:            ListView,
+		Density:         CompactDensity,
+		LinkRel:         "noopener nofollow",
+		Targets:         []string{"html", "json"},
+		FeedEntries:     defaultFeedEntries,
+		InventoryFormat: CSVInventory,
+		SBOMFormat:      SPDXBOM,
+		Enrichers:       []string{"mime", "description"},
+		Jobs:            1,
+		CopyMethod:      CopyContents,
+		UID:             -1,
+		GID:             -1,
+		TrashRetention:  defaultTrashWindow,
+		Rclone:          "rclone",
+		Semver:          true,
+	}
+}
+
+// The method files are carried over with, as chosen by CopyMethod or Link
+func (c *Config) copyMethod() string {
+	switch c.Link {
+	case "hard":
+		return CopyLink
+	case "":
+		if c.CopyMethod == "" {
+			return CopyContents
+		}
+		return c.CopyMethod
+	}
+	return c.Link
+}
+
+// Validate checks the options which can be verified without touching the
+// filesystem
+func (c *Config) Validate() error {
+	if c.BaseURL == nil {
+		return fmt.Errorf("%w: a base URL is required", ErrInvalidConfig)
+	}
+	if c.Include == nil || c.Exclude == nil {
+		return fmt.Errorf("%w: both the include and exclude patterns are required", ErrInvalidConfig)
+	}
+	if c.Remote != "" && c.RemoteURL == nil {
+		return fmt.Errorf("%w: a remote URL is required when listing a remote", ErrInvalidConfig)
+	}
+	if len(c.Mounts) != 0 && (c.FS != nil || c.Remote != "" || c.FromJSON != "" || len(c.Aggregate) != 0) {
+		return fmt.Errorf("%w: mounted directories replace the source, which cannot be given otherwise", ErrInvalidConfig)
+	}
+	if len(c.Mounts) != 0 && (c.Symlinks == PreserveSymlinks || IsRemoteDestination(c.Destination)) {
+		return fmt.Errorf("%w: mounted directories cannot preserve symlinks nor be uploaded to a remote destination", ErrInvalidConfig)
+	}
+	if c.FromJSON != "" && c.Remote != "" {
+		return fmt.Errorf("%w: listings cannot be regenerated from metadata and a remote at once", ErrInvalidConfig)
+	}
+	if len(c.Aggregate) != 0 && (c.Remote != "" || c.FromJSON != "" || c.Keep > 0 || c.Sync || c.AppendOnly) {
+		return fmt.Errorf("%w: aggregated indexes are written in place from the builds' metadata alone", ErrInvalidConfig)
+	}
+	if !validChecksumAlgorithm(c.Checksum) {
+		return fmt.Errorf("%w: unsupported checksum algorithm %q", ErrInvalidConfig, c.Checksum)
+	}
+	if _, err := lookupTargets(c.Targets); err != nil {
+		return err
+	}
+	if !validCopyMethod(c.CopyMethod) {
+		return fmt.Errorf("%w: unsupported copy method %q", ErrInvalidConfig, c.CopyMethod)
+	}
+	switch c.Link {
+	case "":
+	case "hard", CopyReflink, CopyAuto:
+		if c.CopyMethod != "" && c.CopyMethod != CopyContents {
+			return fmt.Errorf("%w: linking with %s conflicts with the %s copy method", ErrInvalidConfig, c.Link, c.CopyMethod)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported link method %q, expected hard, reflink or auto", ErrInvalidConfig, c.Link)
+	}
+	if _, err := ParsePreserve(strings.Join(c.Preserve, ",")); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+	}
+	for _, attr := range c.Preserve {
+		if attr == PreserveMode && c.FileMode != 0 || attr == PreserveOwner && (c.UID != -1 || c.GID != -1) {
+			return fmt.Errorf("%w: %s cannot be both preserved and overridden", ErrInvalidConfig, attr)
+		}
+	}
+	// Linked outputs share their inode with the source, which must not be
+	// touched
+	if c.copyMethod() == CopyLink && (c.FileMode != 0 || c.UID != -1 || c.GID != -1 || c.PreserveXattrs || c.SELinuxContext != "") {
+		return fmt.Errorf("%w: linked files cannot have their permissions, owner or labels changed", ErrInvalidConfig)
+	}
+	if _, err := lookupEnrichers(c.Enrichers); err != nil {
+		return err
+	}
+	if c.View != "" && c.View != ListView && c.View != GalleryView && c.ViewTemplates[c.View] == "" {
+		return fmt.Errorf("%w: unknown view %q", ErrInvalidConfig, c.View)
+	}
+	if c.Density != "" && c.Density != CompactDensity && c.Density != DetailedDensity {
+		return fmt.Errorf("%w: unknown density %q, expected compact or detailed", ErrInvalidConfig, c.Density)
+	}
+	if c.Bundle != "" && c.Bundle != ZipBundle {
+		return fmt.Errorf("%w: unknown archive format %q, expected zip", ErrInvalidConfig, c.Bundle)
+	}
+	if c.InventoryFormat != "" && c.InventoryFormat != CSVInventory && c.InventoryFormat != TSVInventory {
+		return fmt.Errorf("%w: unknown inventory format %q, expected csv or tsv", ErrInvalidConfig, c.InventoryFormat)
+	}
+	if c.SBOMFormat != "" && c.SBOMFormat != SPDXBOM && c.SBOMFormat != CycloneDXBOM {
+		return fmt.Errorf("%w: unknown SBOM format %q, expected spdx or cyclonedx", ErrInvalidConfig, c.SBOMFormat)
+	}
+	if c.Symlinks != "" && c.Symlinks != FollowSymlinks && c.Symlinks != PreserveSymlinks {
+		return fmt.Errorf("%w: unknown symlink mode %q, expected follow or preserve", ErrInvalidConfig, c.Symlinks)
+	}
+	if c.Keep < 0 {
+		return fmt.Errorf("%w: the number of generations kept cannot be negative", ErrInvalidConfig)
+	}
+	if IsRemoteDestination(c.Destination) && (c.Resume || c.Sync || c.Keep > 0) {
+		return fmt.Errorf("%w: remote destinations are uploaded to as a whole, without resuming, syncing or keeping generations", ErrInvalidConfig)
+	}
+	if c.Deploy != "" && IsRemoteDestination(c.Destination) {
+		return fmt.Errorf("%w: remote destinations cannot be deployed", ErrInvalidConfig)
+	}
+	if c.DeployCommand != "" && c.Deploy == "" {
+		return fmt.Errorf("%w: a deploy command requires a deploy target", ErrInvalidConfig)
+	}
+	if c.NoCopy && c.AppendOnly {
+		return fmt.Errorf("%w: listing-only builds copy no files to append", ErrInvalidConfig)
+	}
+	if c.Objects && c.Checksum == "" {
+		return fmt.Errorf("%w: objects are named by the checksum of their contents, which requires an algorithm", ErrInvalidConfig)
+	}
+	if c.Objects && (c.NoCopy || c.Remote != "" || c.FromJSON != "" || len(c.Aggregate) != 0 || IsRemoteDestination(c.Destination)) {
+		return fmt.Errorf("%w: objects are copied into a local destination", ErrInvalidConfig)
+	}
+	if c.Objects && (len(c.Restrict) != 0 || len(c.Unlisted) != 0 || c.Symlinks == PreserveSymlinks) {
+		return fmt.Errorf("%w: objects are shared by all directories, which cannot be restricted, unlisted or preserve symlinks", ErrInvalidConfig)
+	}
+	if c.NoCopy && len(c.Unlisted) != 0 {
+		return fmt.Errorf("%w: directories served as they are cannot be unlisted", ErrInvalidConfig)
+	}
+	if c.AppendOnly && (c.Sync || c.Keep > 0 || IsRemoteDestination(c.Destination)) {
+		return fmt.Errorf("%w: append-only destinations are local and written in place, without syncing or keeping generations", ErrInvalidConfig)
+	}
+	if len(c.Unlisted) != 0 && c.UnlistedManifest == "" && IsRemoteDestination(c.Destination) {
+		return fmt.Errorf("%w: unlisted directories of a remote destination need a manifest path", ErrInvalidConfig)
+	}
+	if c.UnlistedManifest != "" && !IsRemoteDestination(c.Destination) {
+		manifest, _ := filepath.Abs(c.UnlistedManifest)
+		dst, _ := filepath.Abs(c.Destination)
+		if strings.HasPrefix(manifest, dst+string(os.PathSeparator)) {
+			return fmt.Errorf("%w: the unlisted manifest cannot be published within the destination", ErrInvalidConfig)
+		}
+	}
+	if c.ExpiryNotice < 0 {
+		return fmt.Errorf("%w: the expiry notice cannot be negative", ErrInvalidConfig)
+	}
+	if c.TimeBudget < 0 {
+		return fmt.Errorf("%w: the time budget cannot be negative", ErrInvalidConfig)
+	}
+	if c.TimeBudget > 0 && (c.Remote != "" || c.FromJSON != "" || len(c.Aggregate) != 0 || c.Keep > 0 || IsRemoteDestination(c.Destination)) {
+		return fmt.Errorf("%w: time-sliced builds walk a source directory into a local destination, written in place", ErrInvalidConfig)
+	}
+	if c.Keep > 0 && (c.Resume || c.Sync) {
+		return fmt.Errorf("%w: generations are always written from scratch, without resuming or syncing", ErrInvalidConfig)
+	}
+	if c.IOLimit < 0 {
+		return fmt.Errorf("%w: the I/O limit cannot be negative", ErrInvalidConfig)
+	}
+	if c.MIMELimit < 0 {
+		return fmt.Errorf("%w: the MIME sniffing limit cannot be negative", ErrInvalidConfig)
+	}
+	for _, name := range c.Targets {
+		if name == "checksums" && c.Checksum == "" {
+			return fmt.Errorf("%w: the checksums target requires a checksum algorithm", ErrInvalidConfig)
+		}
+		if name == "search" && !hasTarget(c.Targets, "json") {
+			return fmt.Errorf("%w: the search target requires the json target, which writes the search index", ErrInvalidConfig)
+		}
+		if name == "search" && c.NoJS {
+			return fmt.Errorf("%w: the search target cannot work without scripts", ErrInvalidConfig)
+		}
+	}
+	return nil
+}
+
+func hasTarget(targets []string, name string) bool {
+	for _, t := range targets {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// Builds are serialized, as the generator keeps its state at package level
+	generating sync.Mutex
+	setup      sync.Once
+)
+
+// Registers the custom link MIME type and the minifiers, once per process
+func initialize() {
+	// Ugly hack to generate our custom mime, there currently is no way around this
+	v := true
+	mimetype.Lookup("text/plain").Extend(func(_ []byte, size uint32) bool { return v }, "text/statik-link", ".link")
+	linkMIME = mimetype.Detect([]byte("some plain text"))
+	v = false
+
+	minifier = minify.New()
+	minifier.AddFunc("text/css", css.Minify)
+	minifier.AddFunc("text/html", html.Minify)
+	minifier.AddFunc("application/javascript", js.Minify)
+}
+
+// Makes the configuration the current one, checking the source and
+// destination directories and loading the listing assets
+func apply(ctx context.Context, c Config) (err error) {
+	setup.Do(initialize)
+	buildID = newBuildID(time.Now())
+	if err = c.Validate(); err != nil {
+		return
+	}
+	if workDir, err = os.Getwd(); err != nil {
+		return fmt.Errorf("could not get working directory:\n%w", err)
+	}
+	buildCtx = ctx
+	srcDir = getAbsPath(c.Source)
+	dstDir = getAbsPath(c.Destination)
+	srcFS, osSource = c.FS, c.FS == nil
+	if osSource {
+		srcFS = os.DirFS(srcDir)
+	}
+	mounts = nil
+	for _, m := range c.Mounts {
+		mounts = append(mounts, mount{m.At, getAbsPath(m.Dir)})
+		srcFS = mountFS{}
+	}
+	trashDir = siblingDir(dstDir, trashSuffix)
+	baseURL, relativeLinks = c.BaseURL, c.Relative
+	includeRegEx, excludeRegEx = c.Include, c.Exclude
+	ignoreFileNames, ignoreCache = c.IgnoreFiles, nil
+	robotsCache = nil
+	settingsCache = nil
+	recordedOutputs = nil
+	isRecursive, includeEmpty, enableSort, convertLink = c.Recursive, c.IncludeEmpty, c.Sort, c.ConvertLinks
+	showReadme, linkRel = c.Readme, c.LinkRel
+	if enabledTargets, err = lookupTargets(c.Targets); err != nil {
+		return
+	}
+	if enabledEnrichers, err = lookupEnrichers(withChecksum(c.Enrichers, c.Checksum)); err != nil {
+		return
+	}
+	// Remote files are never read, so there is nothing to enrich them from,
+	// ignore files to honor, images to make thumbnails of nor text to preview
+	generateThumbnails, generatePreviews = c.Thumbnails, c.Previews
+	if c.Remote != "" || c.FromJSON != "" || len(c.Aggregate) != 0 {
+		enabledEnrichers, ignoreFileNames, generateThumbnails, generatePreviews = nil, nil, false, false
+	}
+	pageTemplatePath, styleTemplatePath = c.PageTemplate, c.Stylesheet
+	if defaultView, viewTemplatePaths = c.View, c.ViewTemplates; defaultView == "" {
+		defaultView = ListView
+	}
+	noScripts = c.NoJS
+	if density = c.Density; density == "" {
+		density = CompactDensity
+	}
+	// Aggregated indexes share the destination with the builds they list
+	resumeBuild, syncOutput, lowMemory = c.Resume || c.Sync || c.AppendOnly || len(c.Aggregate) != 0 || c.TimeBudget > 0, c.Sync, c.LowMemory
+	if budgetDeadline = (time.Time{}); c.TimeBudget > 0 {
+		budgetDeadline = time.Now().Add(c.TimeBudget)
+	}
+	appendOnly, noCopy, objectsLayout = c.AppendOnly, c.NoCopy, c.Objects
+	claimedObjects = map[string]bool{}
+	fileMode, dirMode, chownUID, chownGID = c.FileMode, c.DirMode, c.UID, c.GID
+	preserveXattrs, selinuxContext = c.PreserveXattrs, c.SELinuxContext
+	preservedAttrs = map[string]bool{}
+	for _, attr := range c.Preserve {
+		preservedAttrs[attr] = true
+	}
+	oneFileSystem, preserveHardlinks = c.OneFileSystem, c.Hardlinks
+	symlinkMode, walkingDirs = c.Symlinks, nil
+	useTrash, trashRetention = c.Trash, c.TrashRetention
+	outputRoot, keepGenerations = dstDir, c.Keep
+	remoteSource, remoteURL, rcloneBinary = c.Remote, c.RemoteURL, c.Rclone
+	if metadataSource = ""; c.FromJSON != "" {
+		if metadataSource, err = metadataFile(c.FromJSON); err != nil {
+			return
+		}
+		remoteSource = path.Dir(metadataSource)
+	}
+	aggregatedBuilds = nil
+	for _, p := range c.Aggregate {
+		if p, err = metadataFile(p); err != nil {
+			return
+		}
+		aggregatedBuilds = append(aggregatedBuilds, p)
+		// Nothing is copied, as with remote sources
+		remoteSource = dstDir
+	}
+	remoteDestination = ""
+	deployTarget, deployCommand = c.Deploy, c.DeployCommand
+	if IsRemoteDestination(c.Destination) {
+		if remoteDestination, err = rcloneDestination(c.Destination); err != nil {
+			return
+		}
+	}
+	semverAware, latestStub = c.Semver, c.LatestStub
+	bundleFormat = c.Bundle
+	latestRules, restrictedDirs = c.Aliases, c.Restrict
+	prioritizedDirs, slowDirs = c.Prioritize, c.Slow
+	hiddenExtensions = c.HideExtensions
+	unlistedDirs, unlistedManifest = c.Unlisted, getAbsPath(c.UnlistedManifest)
+	if c.UnlistedManifest == "" {
+		unlistedManifest = getAbsPath(c.Destination) + unlistedManifestSuffix
+	}
+	if err = readUnlistedManifest(); err != nil {
+		return
+	}
+	checksumAlgorithm, archiveAfter = c.Checksum, c.ArchiveAfter
+	if dropExpired, expiryNotice = c.DropExpired, c.ExpiryNotice; expiryNotice == 0 {
+		expiryNotice = defaultExpiryNotice
+	}
+	serverConfigs, registerMIMETypes = c.ServerConfigs, c.RegisterTypes
+	copyTimeout, onEvent = c.CopyTimeout, c.Events
+	copyMethod = c.copyMethod()
+	autoMethod, autoMethodOnce = "", sync.Once{}
+	ioLimit, ioNext = int64(c.IOLimit*1e6), time.Time{}
+	if jobs = c.Jobs; jobs < 1 || lowMemory {
+		jobs = 1
+	}
+	if feedEntries = c.FeedEntries; feedEntries < 1 {
+		feedEntries = defaultFeedEntries
+	}
+	if inventoryFormat = c.InventoryFormat; inventoryFormat == "" {
+		inventoryFormat = CSVInventory
+	}
+	if sbomFormat = c.SBOMFormat; sbomFormat == "" {
+		sbomFormat = SPDXBOM
+	}
+	if c.MIMELimit == 0 {
+		c.MIMELimit = defaultMIMELimit
+	}
+	mimetype.SetLimit(uint32(c.MIMELimit))
+	mimeByExtension = map[string]bool{}
+	for _, ext := range c.MIMEByExtension {
+		mimeByExtension["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	keepTree = c.keepTree
+
+	if remoteSource == "" {
+		if err = sanitizeDirectories(); err != nil {
+			return fmt.Errorf("error while checking src and dst paths:\n%w", err)
+		}
+	}
+	if oneFileSystem {
+		var (
+			info fs.FileInfo
+			ok   bool
+		)
+		if info, err = fs.Stat(srcFS, "."); err != nil {
+			return fmt.Errorf("could not stat the source directory:\n%w", err)
+		}
+		if srcDevice, _, ok = fileID(info); !ok {
+			return errors.New("filesystem boundaries cannot be detected on this platform")
+		}
+	}
+	return loadAssets()
+}
+
+func newReport(c Config) Report {
+	report := Report{Source: c.Source, Destination: c.Destination, Started: time.Now()}
+	if c.Remote != "" {
+		report.Source = c.Remote
+	} else if c.FromJSON != "" {
+		report.Source = c.FromJSON
+	} else if len(c.Aggregate) != 0 {
+		report.Source = strings.Join(c.Aggregate, ",")
+	} else if len(c.Mounts) != 0 {
+		report.Source = c.Mounts.String()
+	}
+	return report
+}
+
+// Generate walks the source and writes all the outputs into the destination,
+// stopping early when the context is cancelled or the build times out. Files
+// copied until then are recorded in the build manifest, so that a cancelled
+// build can be completed by running it again with Resume. The returned report
+// summarizes the outcome, which is failed whenever an error is returned.
+// Failures which do not stop the build, like rewritten archived files, are
+// aggregated with the one that did, if any, into an Errors value, while
+// collisions are only reported. Concurrent calls run one after the other
+func Generate(ctx context.Context, cfg Config) (report Report, err error) {
+	generating.Lock()
+	defer generating.Unlock()
+	ctx, cancel := withTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	report = newReport(cfg)
+	problems = nil
+	err = apply(ctx, cfg)
+	report.Build = buildID
+	if err == nil {
+		emit(Event{Kind: BuildStarted})
+		err = build(&report)
+	}
+	if err == nil && deployTarget != "" {
+		err = deploy()
+	}
+	err = joinErrors(append([]error{err}, problems...)...)
+	report.finish(err)
+	return
+}
+
+// Walk walks the source of the given configuration, calling visit on each
+// directory once its whole subtree has been walked, without writing any
+// output on its own. WriteJSON and WriteHTML can be called from visit to
+// produce the listings of each directory.
+func Walk(ctx context.Context, cfg Config, visit func(*Directory) error) (dir Directory, fz []FuzzyFile, err error) {
+	generating.Lock()
+	defer generating.Unlock()
+	if err = apply(ctx, cfg); err != nil {
+		return
+	}
+	return walkSource(visit)
+}
@@ -0,0 +1,40 @@
+package statik
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Where the output is pushed to once built, e.g. user@host:/var/www/files
+	deployTarget string
+	// A shell command deploying the output instead of rsync
+	deployCommand string
+)
+
+// Pushes the published output to the deploy target, transferring only what
+// changed since the last deploy and deleting what is gone from the output
+func deploy() error {
+	src := finalOutputDir()
+	var cmd *exec.Cmd
+	if deployCommand == "" {
+		cmd = exec.CommandContext(buildCtx, "rsync", "-a", "--delete", src+"/", deployTarget)
+	} else {
+		// The output and the target are handed over both as arguments and
+		// in the environment
+		cmd = exec.CommandContext(buildCtx, "sh", "-c", deployCommand, "statik-deploy", src, deployTarget)
+		cmd.Env = append(os.Environ(), "STATIK_OUTPUT="+src, "STATIK_DEPLOY="+deployTarget)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not deploy %s to %s:\n%w\n%s", src, deployTarget, err, strings.TrimSpace(stderr.String()))
+	}
+	log.Info().Str("target", deployTarget).Msg("Deployed the output")
+	return nil
+}
@@ -0,0 +1,12 @@
+// Package statik generates static listings of a directory tree: HTML pages,
+// JSON metadata and the other outputs enabled in a Config.
+//
+// The generator keeps the state of a build at package level, so Generate,
+// Walk, DryRun, Verify, Clean and Rollback are serialized: a call waits for
+// the one in progress, if any, to return. Watch relies on the state of its
+// initial build between updates, and must not be run alongside other calls.
+//
+// Each build also sets process-wide options of its dependencies, such as the
+// number of bytes the mimetype package reads to detect file types, which
+// Config.MIMELimit controls.
+package statik
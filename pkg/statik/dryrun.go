@@ -0,0 +1,143 @@
+package statik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// A ChangeReport lists what a build would change in the destination
+type ChangeReport struct {
+	// Source files which would be copied, as they are missing from the
+	// destination or have changed since their last copy
+	Copied []string `json:"copied"`
+	// Directories whose listings would be rendered again
+	Regenerated []string `json:"regenerated"`
+	// Destination entries which would be removed, directories along with all
+	// of their contents. Without resuming or syncing, the whole destination is
+	// cleared before being written again
+	Removed []string `json:"removed"`
+}
+
+// DryRun walks the source of the given configuration and reports what a
+// build would copy, render and remove, without writing anything
+func DryRun(ctx context.Context, cfg Config) (report ChangeReport, err error) {
+	generating.Lock()
+	defer generating.Unlock()
+	if err = apply(ctx, cfg); err != nil {
+		return
+	}
+	// New generations are written from scratch, as if the destination was
+	// empty, and only older generations are removed
+	if remoteDestination != "" {
+		return report, fmt.Errorf("%w: remote destinations cannot be compared with the source", ErrInvalidConfig)
+	}
+	dstDir = finalOutputDir()
+	countedInodes = map[inodeKey]bool{}
+	linkedInodes = map[inodeKey]string{}
+	restricted = map[string]*Directory{}
+	problems = nil
+	unreadable, collisions = nil, nil
+
+	// The manifest of the previous build is only consulted, never written
+	manifest = &Manifest{entries: map[string]ManifestEntry{}, seen: map[string]bool{}}
+	if resumeBuild {
+		p := manifestPath(dstDir)
+		if file, err := os.Open(p); err == nil {
+			err = readManifest(file, manifest.entries)
+			file.Close()
+			if err != nil {
+				return report, fmt.Errorf("could not parse build manifest %s:\n%w", p, err)
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return report, fmt.Errorf("could not open build manifest %s:\n%w", p, err)
+		}
+	} else if keepGenerations > 0 {
+		if report.Removed, err = expiredGenerations(1); err != nil {
+			return
+		}
+	} else if report.Removed, err = destinationEntries(); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+	changed := func(f File) {
+		if f.MIME == linkMIME || remoteSource != "" || noCopy {
+			return
+		}
+		if !manifest.Done(f) && !(appendOnly && exists(f.DstPath)) {
+			mu.Lock()
+			report.Copied = append(report.Copied, f.FuzzyFile.Path)
+			mu.Unlock()
+		}
+	}
+	_, _, err = walkSource(func(dir *Directory) error {
+		for i := range dir.Files {
+			f := &dir.Files[i]
+			changed(*f)
+			if f.Torrent != nil {
+				changed(File{FuzzyFile: *f.Torrent})
+			}
+			// Thumbnails are assumed to be generated, as they are in the
+			// listings of a successful build
+			if thumbnailable(f) {
+				f.Thumbnail = withBaseURL(thumbnailPath(f.FuzzyFile.Path))
+			}
+		}
+		if generatePreviews {
+			linkPreviews(dir)
+		}
+		linkBundle(dir)
+		if !manifest.Listed(dir, listingDigest(dir)) {
+			mu.Lock()
+			report.Regenerated = append(report.Regenerated, dir.Path)
+			mu.Unlock()
+		}
+
+		// What the build writes again is not left over
+		manifest.mu.Lock()
+		manifest.seen[dir.Path] = true
+		for _, f := range dir.Files {
+			manifest.seen[f.FuzzyFile.Path] = true
+			if f.Torrent != nil {
+				manifest.seen[f.Torrent.Path] = true
+			}
+		}
+		manifest.mu.Unlock()
+		return buildCtx.Err()
+	})
+	if err != nil {
+		return
+	}
+	if resumeBuild && !appendOnly {
+		for _, entry := range manifest.Orphans() {
+			report.Removed = append(report.Removed, entry.Path)
+		}
+	}
+	// Nothing to change is reported as empty lists rather than nulls
+	for _, l := range []*[]string{&report.Copied, &report.Regenerated, &report.Removed} {
+		if *l == nil {
+			*l = []string{}
+		}
+		sort.Strings(*l)
+	}
+	return report, nil
+}
+
+// The entries at the top of the destination, which clearing it removes
+func destinationEntries() (names []string, err error) {
+	entries, err := os.ReadDir(dstDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read output directory %s:\n%w", dstDir, err)
+	}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return
+}
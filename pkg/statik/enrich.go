@@ -0,0 +1,303 @@
+package statik
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Sidecar files holding extra metadata for the file they are named after,
+// e.g. release.tar.gz.meta.json, or its description, as in
+// release.tar.gz.meta.yml
+const (
+	sidecarSuffix     = ".meta.json"
+	descriptionSuffix = ".meta.yml"
+)
+
+// An Enricher fills in the metadata of each file as the source is walked.
+// Enrichers are registered by name and enabled through Config.Enrichers,
+// running in the order they are listed in.
+type Enricher interface {
+	Name() string
+	// Fills in the metadata of a file, read through the source filesystem.
+	// Calls may come from several goroutines at once
+	Enrich(src fs.FS, f *File) error
+}
+
+// Enrichers reading companion files of an entry hide them from the listing
+type hider interface {
+	Hides(name string) bool
+}
+
+var (
+	enrichersMu sync.Mutex
+	enrichers   = map[string]Enricher{}
+	// The enrichers of the current build, in order
+	enabledEnrichers []Enricher
+
+	// The MIME type of files no enricher has detected the type of
+	genericMIME = mimetype.Lookup("application/octet-stream")
+
+	// Lowercase extensions, with their dot, of the files whose type is told
+	// by their name alone instead of by sniffing their contents
+	mimeByExtension map[string]bool
+)
+
+// Bytes read by mimetype from the head of each file, unless configured
+// otherwise
+const defaultMIMELimit = 3072
+
+func init() {
+	for _, e := range []Enricher{mimeEnricher{}, checksumEnricher{}, exifEnricher{}, gitEnricher{}, sidecarEnricher{}, descriptionEnricher{}, licenseEnricher{}} {
+		RegisterEnricher(e)
+	}
+}
+
+// RegisterEnricher makes an enricher available to be enabled by its name,
+// replacing any enricher previously registered with the same name
+func RegisterEnricher(e Enricher) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	enrichers[e.Name()] = e
+}
+
+// Enrichers returns the names of all registered enrichers, sorted
+func Enrichers() (names []string) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	for name := range enrichers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// ParseEnrichers parses a comma separated list of enricher names
+func ParseEnrichers(list string) (names []string, err error) {
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, err = lookupEnrichers([]string{name}); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return
+}
+
+func lookupEnrichers(names []string) (es []Enricher, err error) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	for _, name := range names {
+		e, ok := enrichers[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown enricher %q", ErrInvalidConfig, name)
+		}
+		es = append(es, e)
+	}
+	return
+}
+
+// Appends the checksum enricher when an algorithm is set and it is not
+// already listed
+func withChecksum(names []string, algorithm string) []string {
+	if algorithm == "" {
+		return names
+	}
+	for _, name := range names {
+		if name == "checksum" {
+			return names
+		}
+	}
+	return append(names[:len(names):len(names)], "checksum")
+}
+
+// Runs all enrichers over a file, stopping at the first one which fails and
+// returning its name
+func enrich(f *File) (failed string, err error) {
+	for _, e := range enabledEnrichers {
+		if err = e.Enrich(srcFS, f); err != nil {
+			return e.Name(), fmt.Errorf("error while running the %s enricher on %s:\n%w", e.Name(), f.SrcPath, err)
+		}
+	}
+	return "", nil
+}
+
+// Reports whether an entry is a companion file hidden by an enricher
+func hiddenByEnrichers(name string) bool {
+	for _, e := range enabledEnrichers {
+		if h, ok := e.(hider); ok && h.Hides(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets a metadata entry of a file, creating the map as needed
+func (f *File) SetMeta(key, value string) {
+	if f.Meta == nil {
+		f.Meta = map[string]string{}
+	}
+	f.Meta[key] = value
+}
+
+// The metadata of a file as a single line, sorted by key
+func (f File) MetaSummary() string {
+	var keys []string
+	for k := range f.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, k+": "+f.Meta[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Detects the content type of files from their first bytes
+type mimeEnricher struct{}
+
+func (mimeEnricher) Name() string { return "mime" }
+
+func (mimeEnricher) Enrich(src fs.FS, f *File) error {
+	if f.MIME == linkMIME {
+		return nil
+	}
+	ext := strings.ToLower(path.Ext(f.FuzzyFile.Name))
+	if mimeByExtension[ext] {
+		f.MIME = extensionMIME(ext)
+		return nil
+	}
+	file, err := src.Open(f.FuzzyFile.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f.MIME, err = mimetype.DetectReader(file)
+	return err
+}
+
+// The type registered for an extension, as known by mimetype
+func extensionMIME(ext string) *mimetype.MIME {
+	media, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil {
+		return genericMIME
+	}
+	if m := mimetype.Lookup(media); m != nil {
+		return m
+	}
+	return genericMIME
+}
+
+// Hashes the contents of files with the configured algorithm. Files which
+// are going to be retired into the archive are left alone, as they are not
+// copied anyway
+type checksumEnricher struct{}
+
+func (checksumEnricher) Name() string { return "checksum" }
+
+func (checksumEnricher) Enrich(_ fs.FS, f *File) (err error) {
+	if checksumAlgorithm == "" || f.MIME == linkMIME || dueForArchive(f) {
+		return nil
+	}
+	f.Checksum, err = checksumFile(f.FuzzyFile)
+	return
+}
+
+// Records the last commit touching each file, for sources which are git
+// working trees
+type gitEnricher struct{}
+
+func (gitEnricher) Name() string { return "git" }
+
+func (gitEnricher) Enrich(_ fs.FS, f *File) error {
+	if !osSource || f.MIME == linkMIME {
+		return nil
+	}
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(buildCtx, "git", "log", "-1", "--format=%h %cI", "--", f.FuzzyFile.Path)
+	cmd.Dir = srcDir
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		if errors.Is(buildCtx.Err(), context.Canceled) {
+			return buildCtx.Err()
+		}
+		// Files outside of a repository simply have no history
+		return nil
+	}
+	commit, date, ok := strings.Cut(strings.TrimSpace(stdout.String()), " ")
+	if !ok {
+		return nil
+	}
+	f.SetMeta("commit", commit)
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		f.SetMeta("committed", t.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Merges the string values of a release.tar.gz.meta.json sidecar into the
+// metadata of release.tar.gz, hiding the sidecar itself
+type sidecarEnricher struct{}
+
+func (sidecarEnricher) Name() string { return "sidecar" }
+
+func (sidecarEnricher) Hides(name string) bool { return strings.HasSuffix(name, sidecarSuffix) }
+
+func (sidecarEnricher) Enrich(src fs.FS, f *File) error {
+	raw, err := fs.ReadFile(src, f.FuzzyFile.Path+sidecarSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var meta map[string]any
+	if err = json.Unmarshal(raw, &meta); err != nil {
+		return fmt.Errorf("could not parse sidecar %s:\n%w", f.FuzzyFile.Path+sidecarSuffix, err)
+	}
+	for k, v := range meta {
+		f.SetMeta(k, fmt.Sprint(v))
+	}
+	return nil
+}
+
+// Reads the description and expiry date of release.tar.gz from a
+// release.tar.gz.meta.yml sidecar, written like a settings file, hiding the
+// sidecar itself
+type descriptionEnricher struct{}
+
+func (descriptionEnricher) Name() string { return "description" }
+
+func (descriptionEnricher) Hides(name string) bool { return strings.HasSuffix(name, descriptionSuffix) }
+
+func (descriptionEnricher) Enrich(src fs.FS, f *File) error {
+	p := f.FuzzyFile.Path + descriptionSuffix
+	raw, err := fs.ReadFile(src, p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s dirSettings
+	if err = parseSettings(raw, &s); err != nil {
+		return fmt.Errorf("could not parse sidecar %s:\n%w", p, err)
+	}
+	f.Description, f.Expires = s.description, s.expires
+	return nil
+}
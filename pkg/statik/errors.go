@@ -0,0 +1,71 @@
+package statik
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+)
+
+// Errors returned by the library, to be matched with errors.Is. A cancelled
+// build returns the error of its context, i.e. context.Canceled or
+// context.DeadlineExceeded
+var (
+	// The configuration is incomplete or invalid
+	ErrInvalidConfig = errors.New("invalid configuration")
+	// The source is not a directory
+	ErrNotDirectory = errors.New("not a directory")
+	// The source or destination cannot be accessed
+	ErrPermission = fs.ErrPermission
+	// The destination would be written inside of the source
+	ErrOverlap = errors.New("the output directory cannot be a parent of the input directory")
+	// A source entry has the same name as an output generated next to it,
+	// which overwrites it
+	ErrCollision = errors.New("source entry collides with a generated output")
+	// A name Windows reserves for devices, such as CON or nul.txt, which can
+	// only be written with a verbatim path
+	ErrReservedName = errors.New("name reserved on Windows")
+	// The destination does not hold a generated listing
+	ErrNotOutput = errors.New("not a generated listing")
+	// The destination holds no such generation to roll back to
+	ErrNoGeneration = errors.New("no such generation")
+	// An append-only destination already holds a different copy of a file,
+	// which is never overwritten
+	ErrWriteOnce = errors.New("archived file differs from its source")
+	// A followed symbolic link points to one of the directories containing it
+	ErrSymlinkLoop = errors.New("symbolic link loop")
+)
+
+// Errors aggregates the errors of a build which kept going after the first
+// one. errors.Is and errors.As match any of them
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() []error { return e }
+
+// Combines the non-nil errors, returning nil when there are none and the
+// error itself when there is only one
+func joinErrors(errs ...error) error {
+	var all Errors
+	for _, err := range errs {
+		var nested Errors
+		if errors.As(err, &nested) && err == error(nested) {
+			all = append(all, nested...)
+		} else if err != nil {
+			all = append(all, err)
+		}
+	}
+	switch len(all) {
+	case 0:
+		return nil
+	case 1:
+		return all[0]
+	}
+	return all
+}
@@ -0,0 +1,66 @@
+package statik
+
+import "sync"
+
+// The kinds of events reported while a build runs
+type EventKind int
+
+const (
+	// A file has been found in the source and is going to be listed
+	FileWalked EventKind = iota
+	// A file has been copied, or linked, into the destination
+	FileCopied
+	// A listing page has been written
+	PageRendered
+	// Something went wrong without stopping the build
+	Warning
+	// A build, or an update of the output when watching, has started
+	BuildStarted
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case FileWalked:
+		return "walked"
+	case FileCopied:
+		return "copied"
+	case PageRendered:
+		return "rendered"
+	case Warning:
+		return "warning"
+	case BuildStarted:
+		return "started"
+	}
+	return "unknown"
+}
+
+// An Event reports the progress of a build. Path is relative to the source
+// for files and to the destination for pages
+type Event struct {
+	Kind  EventKind
+	Path  string
+	Bytes int64
+	// The problem a Warning is about
+	Err error
+}
+
+var (
+	onEvent func(Event)
+	// Events are emitted from the walker and the renderers alike, but handed
+	// to the callback one at a time
+	eventsMu sync.Mutex
+)
+
+func emit(e Event) {
+	if onEvent == nil {
+		return
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	onEvent(e)
+}
+
+// Reports a problem which does not stop the build
+func warn(err error, path string) {
+	emit(Event{Kind: Warning, Path: path, Err: err})
+}
@@ -0,0 +1,10 @@
+//go:build linux
+
+package statik
+
+import "golang.org/x/sys/unix"
+
+// Swaps two directories atomically through renameat2
+func exchangeDirs(a, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
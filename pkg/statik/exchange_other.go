@@ -0,0 +1,10 @@
+//go:build !linux
+
+package statik
+
+import "errors"
+
+// Swapping directories atomically is not supported on this platform
+func exchangeDirs(a, b string) error {
+	return errors.New("exchanging directories is not supported on this platform")
+}
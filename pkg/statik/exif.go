@@ -0,0 +1,149 @@
+package statik
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+const (
+	// EXIF segments are capped at 64KiB, and come first in JPEG files
+	exifReadLimit  = 128 << 10
+	exifTimeFormat = "2006:01:02 15:04:05"
+
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+var errNoExif = errors.New("no EXIF data")
+
+// Reads the camera and the time a picture was taken out of the EXIF tags of
+// JPEG and TIFF files. Relies on the mime enricher having run first
+type exifEnricher struct{}
+
+func (exifEnricher) Name() string { return "exif" }
+
+func (exifEnricher) Enrich(src fs.FS, f *File) error {
+	if !f.MIME.Is("image/jpeg") && !f.MIME.Is("image/tiff") {
+		return nil
+	}
+	file, err := src.Open(f.FuzzyFile.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	raw, err := io.ReadAll(io.LimitReader(file, exifReadLimit))
+	if err != nil {
+		return err
+	}
+	tags, err := readExif(raw)
+	if err != nil {
+		// Pictures without metadata, or with metadata we cannot make sense of,
+		// are listed as they are
+		return nil
+	}
+	if camera := strings.TrimSpace(tags[tagMake] + " " + tags[tagModel]); camera != "" {
+		f.SetMeta("camera", camera)
+	}
+	taken := tags[tagDateTimeOriginal]
+	if taken == "" {
+		taken = tags[tagDateTime]
+	}
+	if t, err := time.Parse(exifTimeFormat, taken); err == nil {
+		f.SetMeta("taken", t.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// Extracts the ASCII tags of the main and EXIF directories, from either a
+// JPEG or a bare TIFF file
+func readExif(raw []byte) (map[uint16]string, error) {
+	if bytes.HasPrefix(raw, []byte{0xff, 0xd8}) {
+		var err error
+		if raw, err = jpegExifSegment(raw[2:]); err != nil {
+			return nil, err
+		}
+	}
+	return readTIFF(raw)
+}
+
+// Finds the TIFF structure embedded in the APP1 segment of a JPEG file
+func jpegExifSegment(raw []byte) ([]byte, error) {
+	for len(raw) >= 4 && raw[0] == 0xff {
+		marker := raw[1]
+		size := int(binary.BigEndian.Uint16(raw[2:4]))
+		// Image data follows the start of scan, no metadata can come after it
+		if marker == 0xda || size < 2 || len(raw) < 2+size {
+			break
+		}
+		segment := raw[4 : 2+size]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+		raw = raw[2+size:]
+	}
+	return nil, errNoExif
+}
+
+func readTIFF(raw []byte) (map[uint16]string, error) {
+	if len(raw) < 8 {
+		return nil, errNoExif
+	}
+	var order binary.ByteOrder
+	switch string(raw[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errNoExif
+	}
+	if order.Uint16(raw[2:4]) != 42 {
+		return nil, errNoExif
+	}
+	tags := map[uint16]string{}
+	exifOffset := readIFD(raw, order, order.Uint32(raw[4:8]), tags)
+	if exifOffset != 0 {
+		readIFD(raw, order, exifOffset, tags)
+	}
+	return tags, nil
+}
+
+// Collects the ASCII entries of an image file directory, returning the offset
+// of the EXIF directory when it points to one
+func readIFD(raw []byte, order binary.ByteOrder, offset uint32, tags map[uint16]string) (exifOffset uint32) {
+	if int64(offset)+2 > int64(len(raw)) {
+		return 0
+	}
+	count := int(order.Uint16(raw[offset:]))
+	entries := raw[offset+2:]
+	for i := 0; i < count && len(entries) >= 12*(i+1); i++ {
+		entry := entries[12*i : 12*(i+1)]
+		tag, kind, n := order.Uint16(entry), order.Uint16(entry[2:]), order.Uint32(entry[4:])
+		switch {
+		case tag == tagExifIFD && kind == 4:
+			exifOffset = order.Uint32(entry[8:])
+		case kind == 2:
+			// Values up to four bytes long are stored in the entry itself
+			value := entry[8:12]
+			if n > 4 {
+				start := order.Uint32(entry[8:])
+				if int64(start)+int64(n) > int64(len(raw)) {
+					continue
+				}
+				value = raw[start : start+n]
+			} else {
+				value = value[:n]
+			}
+			tags[tag] = strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+		}
+	}
+	return
+}
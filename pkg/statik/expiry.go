@@ -0,0 +1,69 @@
+package statik
+
+import (
+	"sort"
+	"time"
+)
+
+// Files expiring within this long are reported, unless configured otherwise
+const defaultExpiryNotice = 7 * 24 * time.Hour
+
+// An ExpiringFile is a listed file whose expiry date, as set by its sidecar,
+// is near
+type ExpiringFile struct {
+	Path    string    `json:"path"`
+	Expires time.Time `json:"expires"`
+}
+
+var (
+	// Expired files are neither listed nor copied, removing the copies left
+	// by previous runs when resuming or syncing
+	dropExpired  bool
+	expiryNotice time.Duration
+	// The files delisted in the current build and the ones about to be
+	expiredFiles  []string
+	expiringFiles []ExpiringFile
+)
+
+// Moves the files whose expiry date has passed out of the listing, keeping
+// them aside to be copied unless expired files are dropped, and records the
+// ones about to expire. Returns the paths of the delisted files
+func expireFiles(dir *Directory) (expired map[string]bool) {
+	now := time.Now()
+	files := dir.Files[:0]
+	for _, f := range dir.Files {
+		if f.Expires.IsZero() || f.Expires.After(now) {
+			if !f.Expires.IsZero() && f.Expires.Before(now.Add(expiryNotice)) {
+				expiringFiles = append(expiringFiles, ExpiringFile{f.FuzzyFile.Path, f.Expires})
+			}
+			files = append(files, f)
+			continue
+		}
+		if expired == nil {
+			expired = map[string]bool{}
+		}
+		expired[f.FuzzyFile.Path] = true
+		expiredFiles = append(expiredFiles, f.FuzzyFile.Path)
+		dir.TotalBytes -= f.Bytes
+		if !dropExpired {
+			dir.expired = append(dir.expired, f)
+		}
+	}
+	dir.Files = files
+	return
+}
+
+// The files about to expire, the soonest first
+func expiringReport() []ExpiringFile {
+	sort.SliceStable(expiringFiles, func(i, j int) bool { return expiringFiles[i].Expires.Before(expiringFiles[j].Expires) })
+	return expiringFiles
+}
+
+// Parses an expiry date, either a day, which the file expires at the start
+// of, or a time as in RFC 3339
+func parseExpiry(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,55 @@
+package statik
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Options applied to all the include and exclude filters parsed by ParseFilter
+type FilterOptions struct {
+	// Match names regardless of their case, as with a (?i) prefix
+	IgnoreCase bool
+	// Match whole names only, as if the pattern were wrapped in ^ and $
+	Anchored bool
+}
+
+// ParseFilter parses an include or exclude filter, matched against the names
+// of files and directories. A filter is a regular expression, optionally
+// prefixed with its kind:
+//
+//	re:\.iso$     a regular expression, the default, matching anywhere in
+//	              the name unless anchored
+//	glob:*.iso    a shell pattern, always matching the whole name
+//	exact:.git    a literal name, always matching the whole name
+//
+// Regular expressions can carry their own flags, as in (?i)readme, which
+// apply on top of the given options.
+func ParseFilter(spec string, opts FilterOptions) (*regexp.Regexp, error) {
+	kind, pattern, ok := strings.Cut(spec, ":")
+	if !ok || !validFilterKind(kind) {
+		kind, pattern = "re", spec
+	}
+	expr := pattern
+	switch kind {
+	case "glob":
+		expr, opts.Anchored = globToRegexp(pattern), true
+	case "exact":
+		expr, opts.Anchored = regexp.QuoteMeta(pattern), true
+	}
+	if opts.Anchored {
+		expr = "^(?:" + expr + ")$"
+	}
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid filter %q: %s", ErrInvalidConfig, spec, err)
+	}
+	return re, nil
+}
+
+func validFilterKind(kind string) bool {
+	return kind == "re" || kind == "glob" || kind == "exact"
+}
@@ -0,0 +1,76 @@
+package statik_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		spec      string
+		opts      statik.FilterOptions
+		match     []string
+		mismatch  []string
+		wantError bool
+	}{
+		{spec: `\.git`, match: []string{".git", ".github"}},
+		{spec: `\.git`, opts: statik.FilterOptions{Anchored: true}, match: []string{".git"}, mismatch: []string{".github", "digits.git.txt"}},
+		{spec: "readme", match: []string{"readme.md"}, mismatch: []string{"README.md"}},
+		{spec: "readme", opts: statik.FilterOptions{IgnoreCase: true}, match: []string{"README.md", "readme"}},
+		{spec: "(?i)readme", match: []string{"ReadMe.txt"}},
+		{spec: "re:iso$", match: []string{"debian.iso"}, mismatch: []string{"iso.txt"}},
+		{spec: "glob:*.iso", match: []string{"debian.iso"}, mismatch: []string{"debian.iso.sig", "iso"}},
+		{spec: "glob:v?.[0-9]", match: []string{"v1.2"}, mismatch: []string{"v10.2", "v1.x"}},
+		{spec: "exact:a+b.txt", match: []string{"a+b.txt"}, mismatch: []string{"aab.txt", "xa+b.txt"}},
+		{spec: "exact:Makefile", opts: statik.FilterOptions{IgnoreCase: true}, match: []string{"makefile"}},
+		// Unknown kinds are part of the expression
+		{spec: "http:", match: []string{"http:"}},
+		{spec: "re:(", wantError: true},
+	}
+	for _, tt := range tests {
+		re, err := statik.ParseFilter(tt.spec, tt.opts)
+		if (err != nil) != tt.wantError {
+			t.Errorf("ParseFilter(%q, %+v) error = %v, want error %v", tt.spec, tt.opts, err, tt.wantError)
+			continue
+		}
+		for _, name := range tt.match {
+			if !re.MatchString(name) {
+				t.Errorf("ParseFilter(%q, %+v) does not match %q", tt.spec, tt.opts, name)
+			}
+		}
+		for _, name := range tt.mismatch {
+			if re.MatchString(name) {
+				t.Errorf("ParseFilter(%q, %+v) matches %q", tt.spec, tt.opts, name)
+			}
+		}
+	}
+}
+
+func TestAnchoredExclude(t *testing.T) {
+	c := statik.DefaultConfig()
+	c.FS = fstest.MapFS{
+		".git/HEAD":          {Data: []byte("ref: refs/heads/main\n")},
+		"notes.git.txt":      {Data: []byte("Not a repository\n")},
+		"src/.git/config":    {Data: []byte("[core]\n")},
+		"src/main.go":        {Data: []byte("package main\n")},
+		"src/.gitattributes": {Data: []byte("* text=auto\n")},
+	}
+	var err error
+	if c.Exclude, err = statik.ParseFilter(`\.git`, statik.FilterOptions{Anchored: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := statiktest.Build(t, c)
+	for p, want := range map[string]bool{
+		".git": false, "src/.git": false,
+		"notes.git.txt": true, "src/main.go": true, "src/.gitattributes": true,
+	} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(p))); (err == nil) != want {
+			t.Errorf("%s in the output: %v, want %v", p, err == nil, want)
+		}
+	}
+}
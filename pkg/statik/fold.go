@@ -0,0 +1,88 @@
+package statik
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Letters folded into their plain ASCII spelling in search keys, grouped by
+// the spelling they fold to
+var foldGroups = map[string]string{
+	"a":  "àáâãäåāăąǎǻạảấầẩẫậắằẳẵặ",
+	"c":  "çćĉċč",
+	"d":  "ďđð",
+	"e":  "èéêëēĕėęěẹẻẽếềểễệ",
+	"g":  "ĝğġģ",
+	"h":  "ĥħ",
+	"i":  "ìíîïĩīĭįıǐỉị",
+	"j":  "ĵ",
+	"k":  "ķ",
+	"l":  "ĺļľŀł",
+	"n":  "ñńņňŉ",
+	"o":  "òóôõöøōŏőǒǿọỏốồổỗộớờởỡợơ",
+	"r":  "ŕŗř",
+	"s":  "śŝşšș",
+	"t":  "ţťŧț",
+	"u":  "ùúûüũūŭůűųǔụủứừửữựư",
+	"w":  "ŵ",
+	"y":  "ýÿŷỳỵỷỹ",
+	"z":  "źżž",
+	"ae": "æǽ",
+	"oe": "œ",
+	"ss": "ß",
+	"th": "þ",
+	"ij": "ĳ",
+	// Greek
+	"α": "ά", "ε": "έ", "η": "ή", "ι": "ίϊΐ", "ο": "ό", "υ": "ύϋΰ", "ω": "ώ", "σ": "ς",
+}
+
+// Transliterations of the Greek and Cyrillic alphabets
+var transliterations = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu",
+	'я': "ia", 'є': "ie", 'і': "i", 'ї': "i", 'ґ': "g", 'ў': "u",
+}
+
+// The spelling each folded letter is replaced with
+var folds = map[rune]string{}
+
+func init() {
+	for plain, letters := range foldGroups {
+		for _, r := range letters {
+			folds[r] = plain
+		}
+	}
+}
+
+// Normalizes a name into a search key: lowercase, without accents and spelled
+// with ASCII letters where a transliteration is known, so that searching for
+// "strasse" or "jalapeno" finds "Straße" and "jalapeño"
+func searchKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if folded, ok := folds[r]; ok {
+			// Greek letters keep going, to be transliterated below
+			if rs := []rune(folded); len(rs) == 1 && rs[0] > unicode.MaxASCII {
+				r = rs[0]
+			} else {
+				b.WriteString(folded)
+				continue
+			}
+		}
+		if t, ok := transliterations[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		// Combining marks left over by decomposed input carry no meaning
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
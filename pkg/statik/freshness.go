@@ -0,0 +1,56 @@
+package statik
+
+import (
+	"net/url"
+	"path"
+	"time"
+)
+
+const lastBuildFileName = "last-build.json"
+
+// Publishes when the last build ran in a last-build.json at the root, which
+// mirrors and monitoring can poll, and shows in a banner of the listings how
+// long ago that was. Listings left untouched by a sync still tell the age of
+// the last build, as the banner reads it from the file
+type freshnessTarget struct{}
+
+// The contents of last-build.json
+type lastBuild struct {
+	Build string `json:"build"`
+	Time  string `json:"generated_at"`
+	Unix  int64  `json:"generated_at_unix"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"size_bytes"`
+}
+
+func (freshnessTarget) Name() string               { return "freshness" }
+func (freshnessTarget) Start() error               { return nil }
+func (freshnessTarget) Directory(*Directory) error { return nil }
+
+func (freshnessTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{lastBuildFileName}
+	}
+	return nil
+}
+
+func (freshnessTarget) Finish(root *Directory, fz []FuzzyFile) error {
+	now := time.Now()
+	return jsonToFile(path.Join(root.DstPath, lastBuildFileName), lastBuild{
+		Build: buildID,
+		Time:  now.Format(time.RFC3339),
+		Unix:  now.Unix(),
+		Files: len(fz),
+		Bytes: root.TotalBytes,
+	})
+}
+
+// The URL of last-build.json, nil when the freshness target is not enabled
+func freshnessURL() *url.URL {
+	for _, t := range enabledTargets {
+		if t.Name() == "freshness" {
+			return withBaseURL(lastBuildFileName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package statik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The statik.json of a previous build, or a tree assembled from them, which
+// the listings are rebuilt from without reading any source file
+var metadataSource string
+
+// Reads the entries of a listing from its metadata, following the statik.json
+// files of subdirectories next to it
+func readMetadata(p string, rel string, entries []*remoteEntry) ([]*remoteEntry, error) {
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("could not read metadata %s:\n%w", p, err)
+	}
+	var dir scrapedDirectory
+	if err = json.Unmarshal(raw, &dir); err != nil {
+		return nil, fmt.Errorf("could not parse metadata %s:\n%w", p, err)
+	}
+	return metadataEntries(filepath.Dir(p), rel, &dir, entries)
+}
+
+func metadataEntries(base, rel string, dir *scrapedDirectory, entries []*remoteEntry) (_ []*remoteEntry, err error) {
+	if err = buildCtx.Err(); err != nil {
+		return nil, err
+	}
+	for _, f := range dir.Files {
+		if !entryName(f.Name) {
+			return nil, fmt.Errorf("invalid file name %q in the metadata of %s", f.Name, rel)
+		}
+		// The files are not around to be copied, so they are linked where
+		// they were published unless they moved under a new remote URL
+		entry := f.entry(rel)
+		if remoteURL == nil || f.MIME == linkMIME.String() {
+			if entry.Link, err = url.Parse(f.URL); err != nil {
+				return nil, fmt.Errorf("could not parse the URL of %s:\n%w", entry.EntryPath, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	for i := range dir.Directories {
+		d := &dir.Directories[i]
+		if !entryName(d.Name) {
+			return nil, fmt.Errorf("invalid directory name %q in the metadata of %s", d.Name, rel)
+		}
+		sub := path.Join(rel, d.Name)
+		entries = append(entries, &remoteEntry{EntryPath: sub, EntryName: d.Name, Time: parseTime(d.Time), Dir: true})
+		if !isRecursive {
+			continue
+		}
+		if !d.isEmpty() {
+			if entries, err = metadataEntries(filepath.Join(base, d.Name), sub, d, entries); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		p := filepath.Join(base, d.Name, metadataFileName)
+		listed, err := readMetadata(p, sub, entries)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Warn().Str("path", p).Msg("Missing metadata, listing the directory as empty")
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		entries = listed
+	}
+	return entries, nil
+}
+
+// Whether a name read from metadata names a direct child of its directory
+func entryName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// The metadata file given directly or found in the given output directory
+func metadataFile(p string) (string, error) {
+	p = getAbsPath(p)
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", fmt.Errorf("could not stat metadata %s:\n%w", p, err)
+	}
+	if info.IsDir() {
+		p = path.Join(p, metadataFileName)
+	}
+	return p, nil
+}
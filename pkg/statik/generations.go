@@ -0,0 +1,203 @@
+package statik
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// The symlink in the destination pointing at the published generation
+	currentLinkName = "current"
+	// Generations are named after the time their build starts at, in UTC
+	generationTimeFormat = "20060102T150405.000000000Z"
+	// Names given by earlier versions, in local time
+	legacyGenerationFormat = "20060102T150405"
+)
+
+var (
+	// Number of generations kept in the destination, zero when builds are
+	// written into it directly
+	keepGenerations int
+	// The name of the generation being built
+	generation string
+)
+
+// Points the build at the staging directory of a new generation, named after
+// the time it starts at. The time is moved forward on the rare collision
+// with an existing generation, keeping names in build order
+func stageGeneration() (err error) {
+	if err = os.MkdirAll(outputRoot, os.ModeDir|os.ModePerm); err != nil {
+		return fmt.Errorf("could not create output directory %s:\n%w", outputRoot, err)
+	}
+	// Generations left half-written by builds which have been killed
+	stale, _ := filepath.Glob(filepath.Join(outputRoot, ".*"+stagingSuffix+"*"))
+	for _, p := range stale {
+		if err = os.RemoveAll(p); err != nil {
+			return fmt.Errorf("could not clear staging directory %s:\n%w", p, err)
+		}
+	}
+	for t := time.Now().UTC(); ; t = t.Add(time.Nanosecond) {
+		generation = t.Format(generationTimeFormat)
+		if _, err = os.Lstat(filepath.Join(outputRoot, generation)); errors.Is(err, fs.ErrNotExist) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("could not stat generation %s:\n%w", generation, err)
+		}
+	}
+	outputDir = filepath.Join(outputRoot, currentLinkName)
+	dstDir = siblingDir(filepath.Join(outputRoot, generation), stagingSuffix)
+	return nil
+}
+
+// Moves a complete generation into place, publishes it and removes the ones
+// beyond those kept
+func publishGeneration(staging string) (err error) {
+	dst := filepath.Join(outputRoot, generation)
+	if err = os.Rename(staging, dst); err != nil {
+		return
+	}
+	if err = switchGeneration(outputRoot, generation); err != nil {
+		return
+	}
+	log.Info().Str("generation", generation).Msg("Published generation")
+	return pruneGenerations()
+}
+
+// Points the current symlink at a generation, replacing it in a single step
+func switchGeneration(root, name string) (err error) {
+	tmp := filepath.Join(root, "."+currentLinkName+".tmp")
+	if err = os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err = os.Symlink(name, tmp); err != nil {
+		return fmt.Errorf("could not link generation %s:\n%w", name, err)
+	}
+	if err = os.Rename(tmp, filepath.Join(root, currentLinkName)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("could not publish generation %s:\n%w", name, err)
+	}
+	return nil
+}
+
+// The oldest generations beyond the number kept once as many new ones have
+// been added, sparing the published one
+func expiredGenerations(added int) (names []string, err error) {
+	kept, err := Generations(outputRoot)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	current, _ := CurrentGeneration(outputRoot)
+	for i, name := range kept {
+		if i >= len(kept)+added-keepGenerations {
+			break
+		}
+		if name != current {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// Removes the oldest generations beyond the number kept
+func pruneGenerations() error {
+	names, err := expiredGenerations(0)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		p := filepath.Join(outputRoot, name)
+		if err = os.RemoveAll(p); err != nil {
+			return fmt.Errorf("could not remove generation %s:\n%w", p, err)
+		}
+		os.Remove(siblingDir(p, manifestSuffix))
+		log.Info().Str("generation", name).Msg("Removed old generation")
+	}
+	return nil
+}
+
+// OutputDir returns the directory the output of a configuration is published
+// at, which is the current generation when generations are kept
+func OutputDir(cfg Config) string {
+	if cfg.Keep > 0 {
+		return filepath.Join(cfg.Destination, currentLinkName)
+	}
+	return cfg.Destination
+}
+
+// The time a generation has been built at, telling whether the name is one
+func generationTime(name string) (time.Time, bool) {
+	if t, err := time.Parse(generationTimeFormat, name); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation(legacyGenerationFormat, name, time.Local)
+	return t, err == nil
+}
+
+// Generations lists the generations kept in a destination, oldest first
+func Generations(dst string) (names []string, err error) {
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		return nil, fmt.Errorf("could not read output directory %s:\n%w", dst, err)
+	}
+	times := map[string]time.Time{}
+	for _, entry := range entries {
+		if t, ok := generationTime(entry.Name()); ok && entry.IsDir() {
+			names = append(names, entry.Name())
+			times[entry.Name()] = t
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool { return times[names[i]].Before(times[names[j]]) })
+	return names, nil
+}
+
+// CurrentGeneration returns the name of the generation published in a
+// destination
+func CurrentGeneration(dst string) (string, error) {
+	target, err := os.Readlink(filepath.Join(dst, currentLinkName))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s has no published generation", ErrNotOutput, dst)
+	}
+	return filepath.Base(target), nil
+}
+
+// Rollback publishes the given generation of a destination, or the one
+// preceding the published generation when empty, returning its name
+func Rollback(dst, to string) (name string, err error) {
+	generating.Lock()
+	defer generating.Unlock()
+	names, err := Generations(dst)
+	if err != nil {
+		return
+	}
+	current, err := CurrentGeneration(dst)
+	if err != nil {
+		return
+	}
+	index := func(name string) int {
+		for i, n := range names {
+			if n == name {
+				return i
+			}
+		}
+		return -1
+	}
+	if to == "" {
+		i := index(current)
+		if i <= 0 {
+			return "", fmt.Errorf("%w: no generation precedes %s", ErrNoGeneration, current)
+		}
+		to = names[i-1]
+	} else if index(to) < 0 {
+		return "", fmt.Errorf("%w: %s", ErrNoGeneration, to)
+	}
+	return to, switchGeneration(dst, to)
+}
@@ -0,0 +1,40 @@
+package statik_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+func TestGenerationsInQuickSuccession(t *testing.T) {
+	c := statik.DefaultConfig()
+	c.FS = statiktest.Fixture()
+	c.Destination = filepath.Join(t.TempDir(), "out")
+	c.Keep = 2
+	for i := 0; i < 3; i++ {
+		statiktest.Build(t, c)
+	}
+	names, err := statik.Generations(c.Destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("kept generations %v, want 2", names)
+	}
+	current, err := statik.CurrentGeneration(c.Destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != names[1] {
+		t.Errorf("published generation %s, want the newest of %v", current, names)
+	}
+	previous, err := statik.Rollback(c.Destination, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != names[0] {
+		t.Errorf("rolled back to %s, want %s", previous, names[0])
+	}
+}
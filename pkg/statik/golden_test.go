@@ -0,0 +1,54 @@
+package statik_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(c *statik.Config)
+	}{
+		{"default", func(c *statik.Config) {
+			c.Targets = []string{"html", "json", "tree", "sitemap"}
+		}},
+		// Tokens are read from the manifest, so that the output is the same
+		// on every run
+		{"unlisted", func(c *statik.Config) {
+			c.Unlisted = statik.Globs{"docs/nested"}
+			c.UnlistedManifest = filepath.Join(t.TempDir(), "unlisted.json")
+			tokens := `{"docs/nested": "00112233445566778899aabbccddeeff"}`
+			if err := os.WriteFile(c.UnlistedManifest, []byte(tokens), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"objects", func(c *statik.Config) {
+			c.Checksum = "sha256"
+			c.Objects = true
+			c.Aliases.Set("app-*.tar.gz=app-latest.tar.gz")
+		}},
+		// Streaming the pages and the index leaves the output unchanged
+		{"low-memory", func(c *statik.Config) {
+			c.Targets = []string{"html", "json", "tree", "sitemap"}
+			c.LowMemory = true
+		}},
+	}
+	golden := map[string]string{"low-memory": "default"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := statik.DefaultConfig()
+			c.FS = statiktest.Fixture()
+			tt.configure(&c)
+			dir := tt.name
+			if g, ok := golden[tt.name]; ok {
+				dir = g
+			}
+			statiktest.CompareGolden(t, statiktest.Build(t, c), filepath.Join("testdata", "golden", dir))
+		})
+	}
+}
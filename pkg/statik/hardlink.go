@@ -0,0 +1,74 @@
+package statik
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Identifies an inode shared by multiple hard links
+type inodeKey struct {
+	dev, ino uint64
+}
+
+var (
+	preserveHardlinks bool
+
+	// Inodes whose size has already been accounted for in directory totals
+	countedInodes = map[inodeKey]bool{}
+	// Destination paths of the first copy of each hardlinked inode
+	linkedInodes = map[inodeKey]string{}
+)
+
+// Returns the inode of a file which has more than one hard link, or nil
+func hardlinkKey(info fs.FileInfo) *inodeKey {
+	if linkCount(info) < 2 {
+		return nil
+	}
+	dev, ino, ok := fileID(info)
+	if !ok {
+		return nil
+	}
+	return &inodeKey{dev, ino}
+}
+
+// Returns the number of bytes a file contributes to directory totals, so that
+// hardlinked files are only counted the first time they are encountered
+func countedBytes(f File) int64 {
+	if f.inode == nil {
+		return f.Bytes
+	}
+	if countedInodes[*f.inode] {
+		return 0
+	}
+	countedInodes[*f.inode] = true
+	return f.Bytes
+}
+
+// Claims the inode of a file for its copy, returning the destination of the
+// copy which another link to the same inode has already claimed, if any
+func claimInode(f File) (first string) {
+	if !preserveHardlinks || f.inode == nil {
+		return ""
+	}
+	if first, ok := linkedInodes[*f.inode]; ok {
+		return first
+	}
+	linkedInodes[*f.inode] = f.DstPath
+	return ""
+}
+
+// Recreates a hard link to an inode which has already been copied into the
+// destination
+func linkCopy(f FuzzyFile, first string) (err error) {
+	if err = os.Remove(f.DstPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not replace %s:\n%w", f.DstPath, err)
+	}
+	if err = os.Link(first, f.DstPath); err != nil {
+		return fmt.Errorf("could not link %s to %s:\n%w", f.DstPath, first, err)
+	}
+	log.Printf("Linked %s to %s", f.DstPath, first)
+	return nil
+}
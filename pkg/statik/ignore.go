@@ -0,0 +1,226 @@
+package statik
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A single pattern of a .gitignore style file
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// The rules of an ignore file, which apply to the directory it lives in and
+// all of its descendants
+type ignoreFile struct {
+	base  string
+	rules []ignoreRule
+}
+
+var (
+	// Names of the ignore files looked up in each directory. Files later in
+	// the list override the ones before them
+	ignoreFileNames []string
+
+	// Parsed ignore files by directory, for the current build
+	ignoreMu    sync.Mutex
+	ignoreCache map[string][]*ignoreFile
+)
+
+// Reports whether a name is one of the ignore files, which are never listed
+func isIgnoreFile(name string) bool {
+	for _, n := range ignoreFileNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Drops the entries of a directory which are matched by the ignore files of
+// the directory and its ancestors
+func withoutIgnored(rel string, entries []fs.DirEntry) []fs.DirEntry {
+	if len(ignoreFileNames) == 0 {
+		return entries
+	}
+	var chains [][]*ignoreFile
+	for i := range ignoreFileNames {
+		chains = append(chains, ignoreChain(rel, i))
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if isIgnoreFile(entry.Name()) {
+			continue
+		}
+		p := path.Join(rel, entry.Name())
+		if ignoredBy(chains, p, entry.IsDir()) {
+			log.Debug().Str("path", p).Msg("Skipping ignored entry")
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// Reports whether a path is ignored, trusting the verdict of the last kind of
+// ignore file which has an opinion on it
+func ignoredBy(chains [][]*ignoreFile, p string, dir bool) bool {
+	for i := len(chains) - 1; i >= 0; i-- {
+		if ignored, matched := matchChain(chains[i], p, dir); matched {
+			return ignored
+		}
+	}
+	return false
+}
+
+// Matches a path against a chain of ignore files, ordered from the root down.
+// The last matching rule wins, as with git
+func matchChain(chain []*ignoreFile, p string, dir bool) (ignored, matched bool) {
+	for _, file := range chain {
+		rel := p
+		if file.base != "." {
+			rel = strings.TrimPrefix(p, file.base+"/")
+		}
+		for _, rule := range file.rules {
+			if (rule.dirOnly && !dir) || !rule.pattern.MatchString(rel) {
+				continue
+			}
+			ignored, matched = !rule.negate, true
+		}
+	}
+	return
+}
+
+// The ignore files of the given kind found in a directory and its ancestors,
+// ordered from the root down
+func ignoreChain(rel string, kind int) (chain []*ignoreFile) {
+	var dirs []string
+	for dir := rel; ; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "." {
+			break
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if file := loadIgnoreFile(dirs[i], kind); file != nil {
+			chain = append(chain, file)
+		}
+	}
+	return
+}
+
+func loadIgnoreFile(dir string, kind int) *ignoreFile {
+	ignoreMu.Lock()
+	defer ignoreMu.Unlock()
+	if ignoreCache == nil {
+		ignoreCache = map[string][]*ignoreFile{}
+	}
+	files, ok := ignoreCache[dir]
+	if !ok {
+		files = make([]*ignoreFile, len(ignoreFileNames))
+		for i, name := range ignoreFileNames {
+			p := path.Join(dir, name)
+			raw, err := fs.ReadFile(srcFS, p)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					log.Warn().Err(err).Str("path", p).Msg("Could not read ignore file")
+					warn(err, p)
+				}
+				continue
+			}
+			files[i] = &ignoreFile{base: dir, rules: parseIgnoreRules(raw)}
+		}
+		ignoreCache[dir] = files
+	}
+	return files[kind]
+}
+
+// Parses the patterns of a .gitignore style file
+func parseIgnoreRules(raw []byte) (rules []ignoreRule) {
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		// Patterns containing a slash are relative to the ignore file, the
+		// others match at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		expr := globToRegexp(line)
+		if !anchored {
+			expr = "(.*/)?" + expr
+		}
+		var err error
+		if rule.pattern, err = regexp.Compile("^" + expr + "$"); err != nil {
+			log.Warn().Err(err).Str("pattern", line).Msg("Skipping invalid ignore pattern")
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// Translates a gitignore glob into a regular expression, where ** spans
+// directories and the other wildcards stay within a single path segment
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
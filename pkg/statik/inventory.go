@@ -0,0 +1,117 @@
+package statik
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CSVInventory = "csv"
+	TSVInventory = "tsv"
+	// The name of the inventory, followed by the extension of its format
+	inventoryName = "inventory"
+)
+
+// The format of the inventory target
+var inventoryFormat string
+
+// Collects the files of all public directories, for the targets listing them
+// all at the root
+type fileCollector struct {
+	mu sync.Mutex
+	// The files of each directory, by its path
+	dirs map[string][]File
+}
+
+func (c *fileCollector) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs = map[string][]File{}
+	return nil
+}
+
+func (c *fileCollector) Directory(dir *Directory) error {
+	if isRestricted(dir.Path) || isUnlisted(dir.Path) {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs[dir.Path] = append([]File(nil), dir.Files...)
+	return nil
+}
+
+func (c *fileCollector) Forget(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.dirs {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			delete(c.dirs, p)
+		}
+	}
+}
+
+// The collected files, sorted by path
+func (c *fileCollector) files() (files []File) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, dirFiles := range c.dirs {
+		files = append(files, dirFiles...)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FuzzyFile.Path < files[j].FuzzyFile.Path })
+	return
+}
+
+// Lists every public file with its metadata in an inventory.csv (or .tsv) at
+// the root, for loading into spreadsheets and databases
+type inventoryTarget struct {
+	fileCollector
+}
+
+var inventoryHeader = []string{"path", "url", "mime", "size_bytes", "time", "checksum"}
+
+func inventoryFileName() string { return inventoryName + "." + inventoryFormat }
+
+func (*inventoryTarget) Name() string { return "inventory" }
+
+func (*inventoryTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{inventoryFileName()}
+	}
+	return nil
+}
+
+func (t *inventoryTarget) Finish(root *Directory, _ []FuzzyFile) (err error) {
+	p := path.Join(root.DstPath, inventoryFileName())
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile)
+	if err != nil {
+		return fmt.Errorf("could not create inventory %s:\n%w", p, err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	if inventoryFormat == TSVInventory {
+		w.Comma = '\t'
+	}
+	w.Write(inventoryHeader)
+	for _, f := range t.files() {
+		w.Write([]string{
+			f.FuzzyFile.Path,
+			f.URL.String(),
+			f.MIME.String(),
+			strconv.FormatInt(f.Bytes, 10),
+			f.ModTime.Format(time.RFC3339),
+			f.Checksum,
+		})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return fmt.Errorf("could not write inventory %s:\n%w", p, err)
+	}
+	return finalizeFile(p)
+}
@@ -0,0 +1,100 @@
+package statik
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Bytes read from the head of source files looking for an SPDX header
+	spdxHeadBytes = 8 << 10
+	// Bytes of license files compared against the known licenses
+	licenseFileBytes = 64 << 10
+)
+
+var (
+	spdxHeader = regexp.MustCompile(`SPDX-License-Identifier:\s*([^\r\n]+)`)
+	// Trailing comment delimiters left after the identifier on its line
+	spdxTrailer = regexp.MustCompile(`\s*(\*/|-->|#}|\*\)|-}|"""|''')?\s*$`)
+	// Names of license files, e.g. LICENSE, COPYING.md or LICENSE-MIT
+	licenseFileName = regexp.MustCompile(`(?i)^(licen[cs]e|copying|unlicense)([.-].*)?$`)
+)
+
+// A known license, told apart by phrases found in its text. Licenses which
+// contain the text of others come first
+type knownLicense struct {
+	id      string
+	phrases []string
+}
+
+var knownLicenses = []knownLicense{
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license version 2.0"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+}
+
+// Detects the license of source files from their SPDX header, and the one of
+// license files from their text
+type licenseEnricher struct{}
+
+func (licenseEnricher) Name() string { return "license" }
+
+func (licenseEnricher) Enrich(src fs.FS, f *File) error {
+	if f.MIME == linkMIME || !isTextMIME(f.MIME) {
+		return nil
+	}
+	isLicense := licenseFileName.MatchString(f.FuzzyFile.Name)
+	limit := int64(spdxHeadBytes)
+	if isLicense {
+		limit = licenseFileBytes
+	}
+	file, err := src.Open(f.FuzzyFile.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	head, err := io.ReadAll(io.LimitReader(file, limit))
+	if err != nil {
+		return err
+	}
+
+	if m := spdxHeader.FindSubmatch(head); m != nil {
+		f.License = spdxTrailer.ReplaceAllString(string(bytes.TrimSpace(m[1])), "")
+		return nil
+	}
+	if isLicense {
+		f.License = identifyLicense(head)
+	}
+	return nil
+}
+
+// The SPDX identifier of a license text, empty when it is unknown
+func identifyLicense(text []byte) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(string(text)), " "))
+	for _, l := range knownLicenses {
+		matched := true
+		for _, phrase := range l.phrases {
+			if !strings.Contains(normalized, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return l.id
+		}
+	}
+	return ""
+}
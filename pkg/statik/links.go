@@ -0,0 +1,78 @@
+package statik
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"html"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Extracts the target of a link file, returning an empty target for files
+// which turn out not to be links, such as application .desktop entries
+type linkParser func(raw []byte) (string, error)
+
+// The shortcut formats listed as links to their target, by file suffix
+var linkFormats = map[string]linkParser{
+	linkSuffix: func(raw []byte) (string, error) { return strings.TrimSpace(string(raw)), nil },
+	// Windows Internet Shortcuts
+	".url": func(raw []byte) (string, error) { return iniValue(raw, "InternetShortcut", "URL") },
+	// freedesktop.org desktop entries of the Link type
+	".desktop": func(raw []byte) (string, error) {
+		if kind, err := iniValue(raw, "Desktop Entry", "Type"); err != nil || kind != "Link" {
+			return "", err
+		}
+		return iniValue(raw, "Desktop Entry", "URL")
+	},
+	// macOS Website Locations, in their XML property list form
+	".webloc": parseWebloc,
+}
+
+// The rel attribute of anchors to external links
+var linkRel string
+
+var weblocURL = regexp.MustCompile(`<key>URL</key>\s*<string>([^<]*)</string>`)
+
+// Looks up the shortcut format of a file name, returning its suffix
+func linkFormat(name string) (string, linkParser) {
+	suffix := path.Ext(name)
+	return suffix, linkFormats[strings.ToLower(suffix)]
+}
+
+// Reports whether a link points to a host other than the one the listing is
+// published on
+func isExternal(u *url.URL) bool {
+	return u.Host != "" && !strings.EqualFold(u.Host, baseURL.Host)
+}
+
+// Reads a key out of a section of an INI style file
+func iniValue(raw []byte, section, key string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.EqualFold(line[1:len(line)-1], section)
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if inSection && ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			return strings.TrimSpace(v), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+func parseWebloc(raw []byte) (string, error) {
+	if bytes.HasPrefix(raw, []byte("bplist")) {
+		return "", errors.New("binary property lists are not supported, convert it with plutil -convert xml1")
+	}
+	m := weblocURL.FindSubmatch(raw)
+	if m == nil {
+		return "", errors.New("no URL found in the property list")
+	}
+	return html.UnescapeString(strings.TrimSpace(string(m[1]))), nil
+}
@@ -0,0 +1,262 @@
+package statik
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The build manifest is kept next to the output it describes, named after it,
+// so that it is never published along with the output
+const manifestSuffix = ".statik-manifest"
+
+// A ManifestEntry records a file which has been fully written into the
+// destination, together with the size and modification time its source had
+// at the time of the copy. Directories are recorded too once their listings
+// have been written, along with the digest of what they were rendered from,
+// the names of the outputs generated for them and when they were last found
+// up to date
+type ManifestEntry struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size,omitempty"`
+	ModTime  time.Time `json:"time"`
+	Checksum string    `json:"checksum,omitempty"`
+	Listing  string    `json:"listing,omitempty"`
+	Outputs  []string  `json:"outputs,omitempty"`
+	Checked  int64     `json:"checked,omitempty"`
+}
+
+// The build manifest is an append-only journal stored next to the
+// destination directory, where the paths of unlisted subtrees are only given
+// by their tokens. An entry is appended as soon as a file has been copied, so that
+// an interrupted build leaves behind an accurate record of its progress which
+// the next run can pick up from when resuming.
+type Manifest struct {
+	// Pages are rendered, and their directories recorded, in the background
+	mu      sync.Mutex
+	entries map[string]ManifestEntry
+	// Paths which have been copied or confirmed as up to date in this run
+	seen   map[string]bool
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	enc    *json.Encoder
+}
+
+func readManifest(r io.Reader, entries map[string]ManifestEntry) error {
+	dirs := unlistedDirsByToken()
+	dec := json.NewDecoder(r)
+	for {
+		var entry ManifestEntry
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			// A build killed mid-write can leave a truncated last line behind,
+			// which we can safely ignore as the file will be copied again
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		entry.Path = unpublishedPath(entry.Path, dirs)
+		entries[entry.Path] = entry
+	}
+}
+
+// Where the manifest of an output directory is kept. Published generations
+// are reached through a symlink, which is resolved first
+func manifestPath(dir string) string {
+	if target, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = target
+	}
+	return siblingDir(dir, manifestSuffix)
+}
+
+// Opens the manifest of the given directory. When resume is set the entries
+// recorded by the previous run are loaded, otherwise the journal is truncated
+func openManifest(dir string, resume bool) (m *Manifest, err error) {
+	p := manifestPath(dir)
+	m = &Manifest{
+		entries: map[string]ManifestEntry{},
+		seen:    map[string]bool{},
+		path:    p,
+	}
+	flags := os.O_RDWR | os.O_CREATE
+	if resume {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	if err = os.MkdirAll(dir, os.ModeDir|os.ModePerm); err != nil {
+		return nil, fmt.Errorf("could not create output directory %s:\n%w", dir, err)
+	}
+	if m.file, err = os.OpenFile(p, flags, regularFile); err != nil {
+		return nil, fmt.Errorf("could not open build manifest %s:\n%w", p, err)
+	}
+	if err = finalizeFile(p); err != nil {
+		m.file.Close()
+		return nil, err
+	}
+	if resume {
+		if err = readManifest(m.file, m.entries); err != nil {
+			m.file.Close()
+			return nil, fmt.Errorf("could not parse build manifest %s:\n%w", p, err)
+		}
+	}
+	m.writer = bufio.NewWriter(m.file)
+	m.enc = json.NewEncoder(m.writer)
+	return m, nil
+}
+
+// Reports whether the given file has already been copied by a previous run
+// and neither its source nor its destination have changed since. When
+// syncing, a source whose checksum is unchanged counts as unchanged no matter
+// its modification time
+func (m *Manifest) Done(file File) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := file.FuzzyFile
+	entry, ok := m.entries[f.Path]
+	if !ok {
+		return false
+	}
+	src, err := fs.Stat(srcFS, f.Path)
+	if err != nil || src.Size() != entry.Size {
+		return false
+	}
+	sameContents := syncOutput && file.Checksum != "" && file.Checksum == entry.Checksum
+	if !sameContents && !src.ModTime().Equal(entry.ModTime) {
+		return false
+	}
+	dst, err := os.Stat(verbatimPath(f.DstPath))
+	if err != nil || dst.Size() != entry.Size {
+		return false
+	}
+	m.seen[f.Path] = true
+	return true
+}
+
+// Appends an entry for the given file to the journal and flushes it to disk
+func (m *Manifest) Record(file File) (err error) {
+	var info fs.FileInfo
+	f := file.FuzzyFile
+	if info, err = fs.Stat(srcFS, f.Path); err != nil {
+		return fmt.Errorf("could not stat %s:\n%w", f.SrcPath, err)
+	}
+	return m.append(ManifestEntry{Path: f.Path, Size: info.Size(), ModTime: info.ModTime(), Checksum: file.Checksum})
+}
+
+// Reports whether the listings of a directory have been written by a previous
+// run from the same digest, and are still in place
+func (m *Manifest) Listed(dir *Directory, digest string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[dir.Path]
+	if !ok || entry.Listing != digest {
+		return false
+	}
+	for _, name := range generatedNames(dir) {
+		if _, err := os.Stat(path.Join(dir.DstPath, name)); err != nil {
+			return false
+		}
+	}
+	entry.Checked = time.Now().Unix()
+	m.entries[dir.Path] = entry
+	m.seen[dir.Path] = true
+	return true
+}
+
+// Appends an entry for a directory whose listings have just been written
+func (m *Manifest) RecordListing(dir *Directory, digest string) error {
+	return m.append(ManifestEntry{Path: dir.Path, Listing: digest, Outputs: generatedNames(dir), Checked: time.Now().Unix()})
+}
+
+// Returns when the listings of a directory, and so its whole subtree, were
+// last written or found up to date, zero when never
+func (m *Manifest) Checked(p string) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if checked := m.entries[p].Checked; checked != 0 {
+		return time.Unix(checked, 0)
+	}
+	return time.Time{}
+}
+
+func (m *Manifest) append(entry ManifestEntry) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.Path] = entry
+	m.seen[entry.Path] = true
+	if err = m.enc.Encode(entry.published()); err != nil {
+		return fmt.Errorf("could not write build manifest entry:\n%w", err)
+	}
+	return m.writer.Flush()
+}
+
+// Returns the entries recorded by a previous run which have been neither
+// copied nor confirmed in this one, meaning their source has disappeared
+func (m *Manifest) Orphans() (orphans []ManifestEntry) {
+	for p, entry := range m.entries {
+		if !m.seen[p] {
+			orphans = append(orphans, entry)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Path < orphans[j].Path })
+	return
+}
+
+// Removes an entry from the manifest, once its output has been cleaned up
+func (m *Manifest) Forget(p string) { delete(m.entries, p) }
+
+// Rewrites the journal keeping only the latest entry for each path still in
+// the manifest. Further entries should not be recorded afterwards.
+func (m *Manifest) Compact() (err error) {
+	var (
+		file *os.File
+		keys []string
+	)
+	tmp := m.path + ".tmp"
+	if file, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile); err != nil {
+		return fmt.Errorf("could not create build manifest %s:\n%w", tmp, err)
+	}
+	defer file.Close()
+
+	for p := range m.entries {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, p := range keys {
+		if err = enc.Encode(m.entries[p].published()); err != nil {
+			return fmt.Errorf("could not write build manifest entry:\n%w", err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("could not write build manifest %s:\n%w", tmp, err)
+	}
+	if err = finalizeFile(tmp); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("could not replace build manifest %s:\n%w", m.path, err)
+	}
+	return nil
+}
+
+func (m *Manifest) Close() error {
+	if err := m.writer.Flush(); err != nil {
+		return err
+	}
+	return m.file.Close()
+}
@@ -0,0 +1,236 @@
+package statik
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A directory published at a path of the listed tree, the root when At is "."
+type mount struct {
+	At, Dir string
+}
+
+// Mounts is a flag.Value collecting -mount directories, each given as
+// /at=dir or as a lone dir merged into the root
+type Mounts []mount
+
+func (m *Mounts) String() string {
+	var mounts []string
+	for _, mnt := range *m {
+		mounts = append(mounts, "/"+strings.TrimPrefix(mnt.At, ".")+"="+mnt.Dir)
+	}
+	return strings.Join(mounts, ",")
+}
+
+func (m *Mounts) Set(s string) error {
+	at, dir, ok := strings.Cut(s, "=")
+	if !ok {
+		at, dir = "/", s
+	}
+	if dir == "" {
+		return fmt.Errorf("expected a mount in the [/at=]dir form, got %q", s)
+	}
+	if at = strings.Trim(path.Clean("/"+at), "/"); at == "" {
+		at = "."
+	}
+	*m = append(*m, mount{at, dir})
+	return nil
+}
+
+// The mounted directories, with absolute paths, when the source is made of
+// several of them
+var mounts Mounts
+
+// The relative path of rel within a mount, when the mount covers it
+func (m mount) sub(rel string) (string, bool) {
+	if m.At == "." {
+		return rel, true
+	}
+	if rel == m.At {
+		return ".", true
+	}
+	return strings.TrimPrefix(rel, m.At+"/"), strings.HasPrefix(rel, m.At+"/")
+}
+
+// The child of rel leading to the mount point, when the mount lives below rel
+func (m mount) below(rel string) (string, bool) {
+	if m.At == "." || rel == m.At {
+		return "", false
+	}
+	rest := m.At
+	if rel != "." {
+		if !strings.HasPrefix(m.At, rel+"/") {
+			return "", false
+		}
+		rest = strings.TrimPrefix(m.At, rel+"/")
+	}
+	name, _, _ := strings.Cut(rest, "/")
+	return name, true
+}
+
+// Where a source entry lives on disk: in the first mount holding it, or
+// under srcDir for the directories leading to mount points
+func mountedPath(rel string) string {
+	for _, m := range mounts {
+		if sub, ok := m.sub(rel); ok {
+			abs := filepath.Join(m.Dir, filepath.FromSlash(sub))
+			if _, err := os.Lstat(abs); err == nil {
+				return abs
+			}
+		}
+	}
+	return path.Join(srcDir, rel)
+}
+
+// The path of a source entry on disk
+func srcPathOf(rel string) string {
+	if len(mounts) == 0 {
+		return path.Join(srcDir, rel)
+	}
+	return mountedPath(rel)
+}
+
+// The source made of several mounted directories, merging the contents of
+// the ones published at the same path. The directories leading to mount
+// points exist on their own when no mount holds them
+type mountFS struct{}
+
+func (mountFS) virtual(rel string) bool {
+	for _, m := range mounts {
+		if _, ok := m.below(rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (fsys mountFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, m := range mounts {
+		if sub, ok := m.sub(name); ok {
+			file, err := os.Open(filepath.Join(m.Dir, filepath.FromSlash(sub)))
+			if err == nil || !os.IsNotExist(err) {
+				return file, err
+			}
+		}
+	}
+	if fsys.virtual(name) {
+		return &virtualDir{fsys: fsys, name: name}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (fsys mountFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, m := range mounts {
+		if sub, ok := m.sub(name); ok {
+			info, err := os.Stat(filepath.Join(m.Dir, filepath.FromSlash(sub)))
+			if err == nil || !os.IsNotExist(err) {
+				return info, err
+			}
+		}
+	}
+	if fsys.virtual(name) {
+		return virtualInfo(path.Base(name)), nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Lists the entries of every mount holding the directory, the first mount
+// winning on clashing names, along with the mount points right below it
+func (fsys mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	var (
+		entries []fs.DirEntry
+		found   bool
+		seen    = map[string]bool{}
+	)
+	for _, m := range mounts {
+		if sub, ok := m.sub(name); ok {
+			listed, err := os.ReadDir(filepath.Join(m.Dir, filepath.FromSlash(sub)))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			found = true
+			for _, entry := range listed {
+				if !seen[entry.Name()] {
+					seen[entry.Name()] = true
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+	for _, m := range mounts {
+		if child, ok := m.below(name); ok {
+			found = true
+			if !seen[child] {
+				seen[child] = true
+				entries = append(entries, fs.FileInfoToDirEntry(virtualInfo(child)))
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// A directory leading to a mount point, which no mount holds
+type virtualInfo string
+
+func (i virtualInfo) Name() string     { return string(i) }
+func (virtualInfo) Size() int64        { return 0 }
+func (virtualInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (virtualInfo) ModTime() time.Time { return time.Time{} }
+func (virtualInfo) IsDir() bool        { return true }
+func (virtualInfo) Sys() any           { return nil }
+
+type virtualDir struct {
+	fsys    mountFS
+	name    string
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *virtualDir) Stat() (fs.FileInfo, error) { return virtualInfo(path.Base(d.name)), nil }
+func (d *virtualDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+func (d *virtualDir) Close() error { return nil }
+
+func (d *virtualDir) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	if !d.read {
+		if d.entries, err = d.fsys.ReadDir(d.name); err != nil {
+			return nil, err
+		}
+		d.read = true
+	}
+	if n <= 0 {
+		entries, d.entries = d.entries, nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries, d.entries = d.entries[:n], d.entries[n:]
+	return entries, nil
+}
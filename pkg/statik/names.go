@@ -0,0 +1,30 @@
+package statik
+
+import (
+	"path"
+	"strings"
+)
+
+// Files whose extension is left out of their displayed name
+var hiddenExtensions Globs
+
+// The name a file is displayed with, without its extension when it matches
+// one of the -hide-ext globs. Globs without a slash are matched against the
+// name, the others against the path of the file
+func (f File) DisplayName() string {
+	name := f.FuzzyFile.Name
+	ext := path.Ext(name)
+	if ext == "" || ext == name {
+		return name
+	}
+	for _, glob := range hiddenExtensions {
+		subject := name
+		if strings.Contains(glob, "/") {
+			subject = f.FuzzyFile.Path
+		}
+		if ok, _ := path.Match(glob, subject); ok {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
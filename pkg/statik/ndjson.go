@@ -0,0 +1,194 @@
+package statik
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const ndjsonFileName = "inventory.ndjson"
+
+// Streams every public file with its metadata into an inventory.ndjson at the
+// root, one JSON object per line. Files are written out as soon as their
+// directory has been walked, so that the listing of huge trees is never held
+// in memory as a whole
+type ndjsonTarget struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	// When updating a previous build, the lines of the directories generated
+	// again and the directories gone, replacing theirs on Finish
+	updated   map[string][]byte
+	forgotten []string
+}
+
+func (*ndjsonTarget) Name() string { return "ndjson" }
+
+func (*ndjsonTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{ndjsonFileName}
+	}
+	return nil
+}
+
+// The stream only replaces the previous one once complete
+func ndjsonTmpPath() string { return path.Join(dstDir, ndjsonFileName+".tmp") }
+
+func (t *ndjsonTarget) Start() (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// A stream left open by a failed build is discarded
+	if t.file != nil {
+		t.file.Close()
+	}
+	t.updated, t.forgotten = map[string][]byte{}, nil
+	p := ndjsonTmpPath()
+	if t.file, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile); err != nil {
+		return fmt.Errorf("could not create inventory %s:\n%w", p, err)
+	}
+	t.w = bufio.NewWriter(t.file)
+	t.enc = json.NewEncoder(t.w)
+	return nil
+}
+
+func (t *ndjsonTarget) Directory(dir *Directory) (err error) {
+	if isRestricted(dir.Path) || isUnlisted(dir.Path) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file != nil {
+		for i := range dir.Files {
+			if err = t.enc.Encode(&dir.Files[i]); err != nil {
+				return fmt.Errorf("could not write inventory %s:\n%w", ndjsonTmpPath(), err)
+			}
+		}
+		return nil
+	}
+
+	// Outside of a full build the lines are merged into the previous stream
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range dir.Files {
+		if err = enc.Encode(&dir.Files[i]); err != nil {
+			return fmt.Errorf("could not serialize JSON:\n%w", err)
+		}
+	}
+	t.updated[dir.Path] = buf.Bytes()
+	return nil
+}
+
+func (t *ndjsonTarget) Forget(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.forgotten = append(t.forgotten, dir)
+	for p := range t.updated {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			delete(t.updated, p)
+		}
+	}
+}
+
+func (t *ndjsonTarget) Finish(root *Directory, _ []FuzzyFile) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tmp, dst := ndjsonTmpPath(), path.Join(root.DstPath, ndjsonFileName)
+	if t.file == nil {
+		if err = t.merge(dst, tmp); err != nil {
+			return
+		}
+	} else {
+		err = t.w.Flush()
+		if cerr := t.file.Close(); err == nil {
+			err = cerr
+		}
+		t.file, t.w, t.enc = nil, nil, nil
+		if err != nil {
+			return fmt.Errorf("could not write inventory %s:\n%w", tmp, err)
+		}
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return fmt.Errorf("could not move inventory %s into place:\n%w", dst, err)
+	}
+	return finalizeFile(dst)
+}
+
+// Copies the lines of the previous stream into a new one, leaving out the
+// files of the directories generated again or gone, then appends the lines
+// of the directories generated again
+func (t *ndjsonTarget) merge(dst, tmp string) (err error) {
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile)
+	if err != nil {
+		return fmt.Errorf("could not create inventory %s:\n%w", tmp, err)
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+
+	in, err := os.Open(dst)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not open inventory %s:\n%w", dst, err)
+	}
+	if in != nil {
+		defer in.Close()
+		r := bufio.NewReader(in)
+		for {
+			line, rerr := r.ReadBytes('\n')
+			if len(line) != 0 && !t.replaced(line) {
+				w.Write(line)
+			}
+			if rerr == io.EOF {
+				break
+			} else if rerr != nil {
+				return fmt.Errorf("could not read inventory %s:\n%w", dst, rerr)
+			}
+		}
+	}
+
+	var dirs []string
+	for p := range t.updated {
+		dirs = append(dirs, p)
+	}
+	sort.Strings(dirs)
+	for _, p := range dirs {
+		w.Write(t.updated[p])
+	}
+	t.updated, t.forgotten = map[string][]byte{}, nil
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("could not write inventory %s:\n%w", tmp, err)
+	}
+	if err = out.Close(); err != nil {
+		return fmt.Errorf("could not write inventory %s:\n%w", tmp, err)
+	}
+	return nil
+}
+
+// Reports whether a line of the previous stream lists a file of a directory
+// generated again or gone
+func (t *ndjsonTarget) replaced(line []byte) bool {
+	var entry struct {
+		Path string `json:"path"`
+	}
+	if json.Unmarshal(line, &entry) != nil {
+		return true
+	}
+	dir := path.Dir(entry.Path)
+	if _, ok := t.updated[dir]; ok {
+		return true
+	}
+	for _, gone := range t.forgotten {
+		if dir == gone || strings.HasPrefix(dir, gone+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package statik
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yuin/goldmark"
+)
+
+// Maximum number of lines of the release notes shown atop a listing
+const releaseNotesExcerptLines = 40
+
+// Files recognized as release notes, in order of preference
+var releaseNotesNames = []string{"CHANGELOG.md", "RELEASE_NOTES.md", "CHANGELOG", "RELEASE_NOTES"}
+
+var markdown = goldmark.New()
+
+// The release notes found in a directory. Only an excerpt is kept, covering
+// the first section with some content, usually the latest release
+type ReleaseNotes struct {
+	File    string        `json:"file"`
+	URL     *url.URL      `json:"-"`
+	Excerpt string        `json:"excerpt"`
+	HTML    template.HTML `json:"-"`
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+func headingLevel(line string) int {
+	return len(line) - len(strings.TrimLeft(line, "#"))
+}
+
+// Extracts the first section of a document, capped to a maximum number of
+// lines. The section is introduced by the heading right before the first
+// line of content and ends at the next heading of the same or higher level
+func excerpt(content string, markdown bool) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	level, section := 0, 0
+	for i, line := range lines {
+		if i == releaseNotesExcerptLines {
+			return strings.TrimSpace(strings.Join(lines[:i], "\n"))
+		}
+		if !markdown {
+			continue
+		}
+		if l := headingLevel(line); l > 0 {
+			if section > 0 && l <= section {
+				return strings.TrimSpace(strings.Join(lines[:i], "\n"))
+			}
+			level = l
+		} else if section == 0 && strings.TrimSpace(line) != "" {
+			section = level
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Renders markdown (or plain text) to HTML
+func renderMarkdown(content string, isMD bool) (template.HTML, error) {
+	if !isMD {
+		return template.HTML("<pre>" + html.EscapeString(content) + "</pre>"), nil
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(content), &buf); err != nil {
+		return "", err
+	}
+	// goldmark omits raw HTML from its output by default, so this is safe
+	return template.HTML(buf.String()), nil
+}
+
+// Looks for release notes among the files of a directory
+func findReleaseNotes(dir *Directory) (*ReleaseNotes, error) {
+	byName := map[string]*File{}
+	for i := range dir.Files {
+		byName[strings.ToUpper(dir.Files[i].FuzzyFile.Name)] = &dir.Files[i]
+	}
+	for _, name := range releaseNotesNames {
+		f, ok := byName[strings.ToUpper(name)]
+		if !ok || f.MIME == linkMIME {
+			continue
+		}
+		raw, err := readSourceFile(f.FuzzyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read release notes %s:\n%w", f.SrcPath, err)
+		}
+		md := isMarkdown(f.FuzzyFile.Name)
+		notes := &ReleaseNotes{
+			File:    f.FuzzyFile.Name,
+			URL:     f.URL,
+			Excerpt: excerpt(string(raw), md),
+		}
+		if notes.HTML, err = renderMarkdown(notes.Excerpt, md); err != nil {
+			return nil, fmt.Errorf("could not render release notes %s:\n%w", f.SrcPath, err)
+		}
+		log.Debug().Str("path", f.FuzzyFile.Path).Msg("Found release notes")
+		return notes, nil
+	}
+	return nil, nil
+}
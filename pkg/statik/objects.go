@@ -0,0 +1,113 @@
+package statik
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The directory at the root of the destination holding the contents of all
+// files, once each, when they are stored by their checksum
+const objectsDirName = "objects"
+
+var (
+	objectsLayout bool
+	// Objects whose copy has been claimed by a file in the current build
+	claimedObjects = map[string]bool{}
+)
+
+// Points a file at the object named by its checksum, which all the files
+// with the same contents share. Files without one are copied where they are
+func storeAsObject(f *File) {
+	_, digest, ok := strings.Cut(f.Checksum, ":")
+	if !objectsLayout || !ok || f.MIME == linkMIME {
+		return
+	}
+	rel := path.Join(objectsDirName, digest)
+	f.DstPath = path.Join(dstDir, rel)
+	f.URL = withBaseURL(rel)
+	f.inode = nil
+}
+
+// Reports whether a file is stored as an object
+func isObject(f FuzzyFile) bool {
+	return objectsLayout && path.Dir(f.DstPath) == path.Join(dstDir, objectsDirName)
+}
+
+// Object reports whether the file links to its object, which is then saved
+// under the name of the file instead
+func (f File) Object() bool { return isObject(f.FuzzyFile) }
+
+// Claims the object of a file for its copy, reporting whether no other file
+// of the build has claimed it already
+func claimObject(f File) bool {
+	name := path.Base(f.DstPath)
+	if claimedObjects[name] {
+		return false
+	}
+	claimedObjects[name] = true
+	return true
+}
+
+// Reports whether the object a file is stored as has already been written
+// whole, by this build or a previous one
+func storedObject(file File) bool {
+	if !isObject(file.FuzzyFile) {
+		return false
+	}
+	src, err := fs.Stat(srcFS, file.FuzzyFile.Path)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(file.DstPath)
+	return err == nil && info.Size() == src.Size()
+}
+
+// Leaves out a source entry at the root named as the objects directory,
+// which would otherwise be overwritten by it
+func withoutObjects(rel string, entries []fs.DirEntry) []fs.DirEntry {
+	if !objectsLayout || rel != "." {
+		return entries
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Name() == objectsDirName {
+			err := fmt.Errorf("%w: %s", ErrCollision, objectsDirName)
+			log.Warn().Err(err).Msg("A source entry is left out for the objects directory")
+			warn(err, objectsDirName)
+			collisions = append(collisions, objectsDirName)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// Removes the objects no file of the build is stored as anymore
+func removeUnusedObjects() error {
+	if !objectsLayout {
+		return nil
+	}
+	dir := path.Join(dstDir, objectsDirName)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not read objects directory %s:\n%w", dir, err)
+	}
+	for _, entry := range entries {
+		if claimedObjects[entry.Name()] {
+			continue
+		}
+		p := path.Join(dir, entry.Name())
+		if err = os.RemoveAll(p); err != nil {
+			return fmt.Errorf("could not remove unused object %s:\n%w", p, err)
+		}
+		log.Info().Str("path", p).Msg("Removed unused object")
+	}
+	return nil
+}
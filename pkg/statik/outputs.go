@@ -0,0 +1,138 @@
+package statik
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Entries at the root of an output which belong to statik itself
+var ownEntries = []string{nginxAuthFileName, assetsDirName}
+
+// Entries at the root of an output meant for the web server, or left behind
+// by versions which kept the build bookkeeping inside the output
+var privateEntries = []string{nginxAuthFileName, manifestSuffix, manifestSuffix + ".tmp", trashSuffix}
+
+// IsPrivate reports whether a path of the output, relative to its root, is
+// statik's own and not to be served to visitors
+func IsPrivate(p string) bool {
+	name, _, _ := strings.Cut(strings.TrimPrefix(path.Clean("/"+p), "/"), "/")
+	for _, private := range privateEntries {
+		if name == private {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// The outputs recorded by the manifests found in the source, by the
+	// directory they live in. Directories without a manifest map to nil
+	outputsMu       sync.Mutex
+	recordedOutputs map[string]map[string][]string
+)
+
+// Drops the entries of a directory which have been generated by a previous
+// run of statik, as recorded by the manifest of the directory or of one of its
+// ancestors. This happens when the output, or part of it, ends up within the
+// source, e.g. when publishing in place or mirroring an existing listing
+func withoutOutputs(rel string, entries []fs.DirEntry) []fs.DirEntry {
+	outputs, ok := generatedEntries(rel)
+	if !ok {
+		return entries
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if outputs[entry.Name()] {
+			log.Debug().Str("path", path.Join(rel, entry.Name())).Msg("Skipping output of a previous run")
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// The names in a directory generated by a previous run, if any run wrote the
+// directory or one of its ancestors
+func generatedEntries(rel string) (map[string]bool, bool) {
+	var dirs []string
+	for dir := rel; ; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "." {
+			break
+		}
+	}
+	// The closest manifest is the one describing the directory
+	for _, root := range dirs {
+		listings := manifestListings(root)
+		if listings == nil {
+			continue
+		}
+		sub := strings.TrimPrefix(strings.TrimPrefix(rel, root), "/")
+		if sub == "" {
+			sub = "."
+		}
+		names := map[string]bool{}
+		if sub == "." {
+			for _, name := range ownEntries {
+				names[name] = true
+			}
+		}
+		for _, name := range listings[sub] {
+			names[name] = true
+		}
+		return names, true
+	}
+	return nil, false
+}
+
+// Reads the listings recorded by the manifest of a directory of the source,
+// found next to it, caching the outcome for the rest of the build
+func manifestListings(dir string) map[string][]string {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	if recordedOutputs == nil {
+		recordedOutputs = map[string]map[string][]string{}
+	}
+	if listings, ok := recordedOutputs[dir]; ok {
+		return listings
+	}
+	var listings map[string][]string
+	p := path.Join(path.Dir(dir), "."+path.Base(dir)+manifestSuffix)
+	open := func() (fs.File, error) { return srcFS.Open(p) }
+	// The manifest of an output published in place lives next to the source
+	if dir == "." {
+		p = manifestPath(srcDir)
+		open = func() (fs.File, error) { return os.Open(p) }
+	}
+	if file, err := open(); err == nil {
+		entries := map[string]ManifestEntry{}
+		if err = readManifest(file, entries); err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Could not parse the manifest of a previous run")
+			warn(err, p)
+		}
+		file.Close()
+		listings = map[string][]string{}
+		for _, entry := range entries {
+			if entry.Listing == "" {
+				continue
+			}
+			outputs := entry.Outputs
+			// Manifests written before outputs were recorded
+			if outputs == nil {
+				outputs = []string{"index.html", metadataFileName, fuzzyFileName, archiveFileName}
+			}
+			listings[entry.Path] = outputs
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.Warn().Err(err).Str("path", p).Msg("Could not open the manifest of a previous run")
+		warn(err, p)
+	}
+	recordedOutputs[dir] = listings
+	return listings
+}
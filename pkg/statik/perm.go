@@ -0,0 +1,163 @@
+package statik
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// The attributes of source entries which can be carried over to the outputs
+const (
+	PreserveTimes = "times"
+	PreserveMode  = "mode"
+	PreserveOwner = "owner"
+)
+
+var (
+	// Permission overrides for the outputs, a zero value keeps the default
+	// behaviour: files are created as 0666 minus the umask while directories
+	// mirror the permissions of their source
+	fileMode fs.FileMode
+	dirMode  fs.FileMode
+
+	// Ownership applied to all outputs, -1 leaves the respective id untouched
+	chownUID = -1
+	chownGID = -1
+
+	// Whether to carry over the SELinux context and POSIX ACL of copied files,
+	// and a fixed SELinux context to label all outputs with
+	preserveXattrs bool
+	selinuxContext string
+
+	// The attributes of source files and directories carried over to their
+	// copies, as listed by -preserve
+	preservedAttrs map[string]bool
+)
+
+// ParsePreserve parses a comma separated list of attributes to preserve
+func ParsePreserve(s string) (attrs []string, err error) {
+	for _, attr := range strings.Split(s, ",") {
+		switch attr = strings.TrimSpace(attr); attr {
+		case "":
+		case PreserveTimes, PreserveMode, PreserveOwner:
+			attrs = append(attrs, attr)
+		default:
+			return nil, fmt.Errorf("unknown attribute %q, expected times, mode or owner", attr)
+		}
+	}
+	return
+}
+
+// ParseMode parses an octal permission string such as 644 or 0755
+func ParseMode(s string) (mode fs.FileMode, err error) {
+	if s == "" {
+		return 0, nil
+	}
+	var raw uint64
+	if raw, err = strconv.ParseUint(s, 8, 32); err != nil {
+		return 0, fmt.Errorf("invalid octal file mode %q", s)
+	}
+	if raw > 0777 {
+		return 0, fmt.Errorf("file mode %q is out of range", s)
+	}
+	return fs.FileMode(raw), nil
+}
+
+// ParseOwner parses an ownership specification in the uid:gid form, where either side
+// can be omitted and both numeric ids and user/group names are accepted
+func ParseOwner(s string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if s == "" {
+		return
+	}
+	owner, group, _ := strings.Cut(s, ":")
+	if owner != "" {
+		if uid, err = strconv.Atoi(owner); err != nil {
+			var u *user.User
+			if u, err = user.Lookup(owner); err != nil {
+				return -1, -1, fmt.Errorf("unknown user %q", owner)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			var g *user.Group
+			if g, err = user.LookupGroup(group); err != nil {
+				return -1, -1, fmt.Errorf("unknown group %q", group)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// Applies the configured ownership and SELinux label to an output path
+func applyOwner(path string) error {
+	if chownUID != -1 || chownGID != -1 {
+		if err := os.Lchown(path, chownUID, chownGID); err != nil {
+			return fmt.Errorf("could not change ownership of %s:\n%w", path, err)
+		}
+	}
+	if selinuxContext != "" {
+		return setSELinuxContext(path, selinuxContext)
+	}
+	return nil
+}
+
+// Applies the configured permissions and ownership to a file written into the
+// destination
+func finalizeFile(path string) error {
+	if fileMode != 0 {
+		if err := os.Chmod(path, fileMode); err != nil {
+			return fmt.Errorf("could not set permissions on %s:\n%w", path, err)
+		}
+	}
+	return applyOwner(path)
+}
+
+// Applies the configured permissions and ownership to a directory in the
+// destination, falling back to the given mode when no override is provided
+func finalizeDir(path string, mode fs.FileMode) error {
+	if dirMode != 0 {
+		mode = dirMode
+	}
+	if err := os.Chmod(path, mode.Perm()); err != nil {
+		return fmt.Errorf("could not set permissions on %s:\n%w", path, err)
+	}
+	return applyOwner(path)
+}
+
+// Carries the preserved attributes of a source entry over to its output.
+// Times are set last, as changing the others would not touch them anyway
+func preserveAttrs(dst, rel string, dir bool) error {
+	if len(preservedAttrs) == 0 || remoteSource != "" {
+		return nil
+	}
+	info, err := fs.Stat(srcFS, rel)
+	if err != nil {
+		return fmt.Errorf("could not stat %s:\n%w", rel, err)
+	}
+	// Directories mirror the permissions of their source already
+	if preservedAttrs[PreserveMode] && !dir {
+		if err = os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("could not set permissions on %s:\n%w", dst, err)
+		}
+	}
+	if preservedAttrs[PreserveOwner] {
+		if uid, gid, ok := fileOwner(info); ok {
+			if err = os.Lchown(dst, uid, gid); err != nil {
+				return fmt.Errorf("could not change ownership of %s:\n%w", dst, err)
+			}
+		}
+	}
+	if preservedAttrs[PreserveTimes] {
+		if err = os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("could not set modification time of %s:\n%w", dst, err)
+		}
+	}
+	return nil
+}
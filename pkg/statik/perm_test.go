@@ -0,0 +1,86 @@
+package statik_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    fs.FileMode
+		wantErr bool
+	}{
+		{in: ""},
+		{in: "644", want: 0o644},
+		{in: "0755", want: 0o755},
+		{in: "0", want: 0},
+		{in: "1777", wantErr: true},
+		{in: "888", wantErr: true},
+		{in: "rw-r--r--", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := statik.ParseMode(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMode(%q) = %v, %v, want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestModeOverrides(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not supported on Windows")
+	}
+	c := statik.DefaultConfig()
+	c.FS = statiktest.Fixture()
+	c.FileMode, c.DirMode = 0o640, 0o750
+	out := statiktest.Build(t, c)
+	err := filepath.WalkDir(out, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		want := c.FileMode
+		if info.IsDir() {
+			want = c.DirMode
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %v, want %v", p, got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseOwner(t *testing.T) {
+	tests := []struct {
+		in       string
+		uid, gid int
+		wantErr  bool
+	}{
+		{in: "", uid: -1, gid: -1},
+		{in: "1000:1000", uid: 1000, gid: 1000},
+		{in: "33", uid: 33, gid: -1},
+		{in: ":82", uid: -1, gid: 82},
+		{in: "1000:", uid: 1000, gid: -1},
+		{in: "no-such-user-statik:0", uid: -1, gid: -1, wantErr: true},
+		{in: "0:no-such-group-statik", uid: -1, gid: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		uid, gid, err := statik.ParseOwner(tt.in)
+		if (err != nil) != tt.wantErr || uid != tt.uid || gid != tt.gid {
+			t.Errorf("ParseOwner(%q) = %d, %d, %v, want %d, %d, error %v", tt.in, uid, gid, err, tt.uid, tt.gid, tt.wantErr)
+		}
+	}
+}
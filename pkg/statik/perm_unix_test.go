@@ -0,0 +1,40 @@
+//go:build unix
+
+package statik_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+func TestOwnerOverride(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing ownership requires root")
+	}
+	c := statik.DefaultConfig()
+	c.FS = statiktest.Fixture()
+	c.UID, c.GID = 1234, 5678
+	out := statiktest.Build(t, c)
+	err := filepath.WalkDir(out, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if st := info.Sys().(*syscall.Stat_t); st.Uid != 1234 || st.Gid != 5678 {
+			t.Errorf("%s is owned by %d:%d, want 1234:5678", p, st.Uid, st.Gid)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,179 @@
+package statik
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Appended to the name of a file to get the name of its preview page
+	previewSuffix = ".html"
+	// Larger files are not previewed, as the highlighted page would be huge
+	maxPreviewBytes = 1 << 20
+	// The highlighting styles, matching the light and dark palettes of the
+	// default stylesheet
+	previewLightStyle = "gruvbox-light"
+	previewDarkStyle  = "gruvbox"
+)
+
+var (
+	generatePreviews bool
+	previewTemplate  = template.Must(template.New("preview").Parse(defaultPreviewTemplate))
+	previewFormatter = chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(true),
+		chromahtml.WithLinkableLineNumbers(true, "L"),
+		chromahtml.TabWidth(4),
+	)
+	// The stylesheet of the highlighted code, generated once per build
+	previewStyle string
+)
+
+type PreviewPayload struct {
+	HTMLPayload
+	File File
+	// The name of the language the file has been highlighted as
+	Language  string
+	Code      template.HTML
+	Highlight template.CSS
+}
+
+// Reports whether a MIME type is text, or a type derived from it
+func isTextMIME(m *mimetype.MIME) bool {
+	for ; m != nil; m = m.Parent() {
+		if m.Is("text/plain") || strings.HasPrefix(m.String(), "text/") {
+			return true
+		}
+	}
+	return false
+}
+
+func previewable(f *File) bool {
+	return generatePreviews && f.MIME != linkMIME && f.Bytes <= maxPreviewBytes && isTextMIME(f.MIME)
+}
+
+// The names of the preview pages generated next to the files of a directory
+func previewNames(dir *Directory) (names []string) {
+	for i := range dir.Files {
+		if previewable(&dir.Files[i]) {
+			names = append(names, dir.Files[i].FuzzyFile.Name+previewSuffix)
+		}
+	}
+	return
+}
+
+// Generates the stylesheet of the highlighted code, switching styles along
+// with the color scheme of the listing
+func loadPreviewStyle() error {
+	var buf bytes.Buffer
+	if err := previewFormatter.WriteCSS(&buf, styles.Get(previewLightStyle)); err != nil {
+		return fmt.Errorf("could not generate the highlighting stylesheet:\n%w", err)
+	}
+	buf.WriteString("@media (prefers-color-scheme: dark) {\n")
+	if err := previewFormatter.WriteCSS(&buf, styles.Get(previewDarkStyle)); err != nil {
+		return fmt.Errorf("could not generate the highlighting stylesheet:\n%w", err)
+	}
+	buf.WriteString("}\n")
+	previewStyle = buf.String()
+	return nil
+}
+
+// Points the text files of a directory to their preview pages
+func linkPreviews(dir *Directory) {
+	for i := range dir.Files {
+		if f := &dir.Files[i]; previewable(f) {
+			f.Preview = withBaseURL(publishedPath(f.FuzzyFile.Path) + previewSuffix)
+		}
+	}
+}
+
+// Writes the preview pages of a directory. Files which cannot be highlighted
+// are listed without one
+func writePreviews(dir *Directory) error {
+	payload := newPayload(dir)
+	payload.Root.Directories, payload.Root.Files = nil, nil
+	pool := newWorkerPool(jobsIn(dir.Path))
+	for i := range dir.Files {
+		f := &dir.Files[i]
+		if f.Preview == nil {
+			continue
+		}
+		pool.Go(func() error {
+			dst := path.Join(dir.DstPath, f.FuzzyFile.Name+previewSuffix)
+			if err := writePreview(payload, *f, dst); err != nil {
+				log.Warn().Err(err).Str("path", f.FuzzyFile.Path).Msg("Could not generate preview")
+				warn(err, f.FuzzyFile.Path)
+				dir.addBroken(f.FuzzyFile.Path, "preview", err)
+				f.Preview = nil
+			}
+			return nil
+		})
+	}
+	if err := pool.Wait(); err != nil {
+		return err
+	}
+	return buildCtx.Err()
+}
+
+func writePreview(page HTMLPayload, f File, dst string) (err error) {
+	var raw []byte
+	if raw, err = fs.ReadFile(srcFS, f.FuzzyFile.Path); err != nil {
+		return fmt.Errorf("could not read %s:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	source := string(raw)
+	lexer := lexers.Match(f.FuzzyFile.Name)
+	if lexer == nil {
+		lexer = lexers.MatchMimeType(f.MIME.String())
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(source)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+	var (
+		tokens chroma.Iterator
+		code   bytes.Buffer
+	)
+	if tokens, err = lexer.Tokenise(nil, source); err != nil {
+		return fmt.Errorf("could not tokenize %s:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	if err = previewFormatter.Format(&code, styles.Get(previewLightStyle), tokens); err != nil {
+		return fmt.Errorf("could not highlight %s:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+
+	page.relativize(pageDir(dst))
+	payload := PreviewPayload{
+		HTMLPayload: page,
+		File:        relativeFiles(pageDir(dst), []File{f})[0],
+		Language:    lexer.Config().Name,
+		Code:        template.HTML(code.String()),
+		Highlight:   template.CSS(previewStyle),
+	}
+	return renderPage(dst, previewTemplate, payload)
+}
+
+// Removes the preview page of a file gone from the source, unless a source
+// file of the same name has taken its place
+func removePreview(rel string) {
+	p := rel + previewSuffix
+	if _, ok := manifest.entries[p]; ok {
+		return
+	}
+	if err := os.Remove(path.Join(dstDir, publishedPath(p))); err != nil && !os.IsNotExist(err) {
+		log.Warn().Err(err).Str("path", p).Msg("Could not remove preview")
+	}
+}
@@ -0,0 +1,139 @@
+package statik
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	problemsJSONFileName = "problems.json"
+	problemsHTMLFileName = "problems.html"
+)
+
+// The kinds of broken entries. Failing enrichers, thumbnails and previews
+// are reported under their own name, such as mime or checksum
+const (
+	// The entry cannot be read, and is listed without its contents if at all
+	UnreadableEntry = "unreadable"
+	// The entry is a symbolic link to nothing
+	DanglingSymlink = "dangling_symlink"
+	// The entry is a symbolic link to one of the directories containing it
+	SymlinkLoop = "symlink_loop"
+	// The entry cannot be written into the destination
+	SkippedEntry = "skipped"
+	// The entry is overwritten by an output generated next to it
+	CollidingEntry = "collision"
+)
+
+var problemsTemplate = template.Must(template.New("problems").Parse(defaultProblemsTemplate))
+
+// A BrokenEntry is a source entry which has been left out of the listing, or
+// listed without some of its metadata, because of a problem with the source
+// data
+type BrokenEntry struct {
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+}
+
+type ProblemsPayload struct {
+	HTMLPayload
+	Problems []BrokenEntry
+}
+
+// Broken entries are found by the jobs inspecting and rendering files
+var brokenMu sync.Mutex
+
+func newBrokenEntry(p, kind string, err error) *BrokenEntry {
+	return &BrokenEntry{Path: p, Kind: kind, Error: err.Error()}
+}
+
+func (dir *Directory) addBroken(p, kind string, err error) {
+	brokenMu.Lock()
+	defer brokenMu.Unlock()
+	dir.Broken = append(dir.Broken, *newBrokenEntry(p, kind, err))
+}
+
+// Tells whether an entry could not be inspected because of the source data,
+// in which case it is left out, rather than because of the build
+func brokenKind(entry fs.DirEntry, err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return UnreadableEntry
+	case errors.Is(err, fs.ErrNotExist) && entry.Type()&fs.ModeSymlink != 0:
+		return DanglingSymlink
+	}
+	return ""
+}
+
+// Lists the broken entries of all public directories in a problems.json and
+// a problems.html page at the root, for maintainers to fix the source data
+type problemsTarget struct {
+	mu   sync.Mutex
+	dirs map[string][]BrokenEntry
+}
+
+func (*problemsTarget) Name() string { return "problems" }
+
+func (t *problemsTarget) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirs = map[string][]BrokenEntry{}
+	return nil
+}
+
+func (*problemsTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{problemsJSONFileName, problemsHTMLFileName}
+	}
+	return nil
+}
+
+func (t *problemsTarget) Directory(dir *Directory) error {
+	if isRestricted(dir.Path) || isUnlisted(dir.Path) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	brokenMu.Lock()
+	defer brokenMu.Unlock()
+	if len(dir.Broken) == 0 {
+		delete(t.dirs, dir.Path)
+	} else {
+		t.dirs[dir.Path] = append([]BrokenEntry(nil), dir.Broken...)
+	}
+	return nil
+}
+
+func (t *problemsTarget) Forget(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for p := range t.dirs {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			delete(t.dirs, p)
+		}
+	}
+}
+
+func (t *problemsTarget) Finish(root *Directory, _ []FuzzyFile) (err error) {
+	t.mu.Lock()
+	entries := []BrokenEntry{}
+	for _, broken := range t.dirs {
+		entries = append(entries, broken...)
+	}
+	t.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	if err = jsonToFile(path.Join(root.DstPath, problemsJSONFileName), entries); err != nil {
+		return
+	}
+	payload := ProblemsPayload{HTMLPayload: newPayload(root), Problems: entries}
+	payload.Root.Directories, payload.Root.Files = nil, nil
+	payload.relativize(".")
+	return renderPage(path.Join(root.DstPath, problemsHTMLFileName), problemsTemplate, payload)
+}
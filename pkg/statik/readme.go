@@ -0,0 +1,48 @@
+package statik
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Files describing the contents of a directory, in order of preference
+var readmeNames = []string{"HEADER.md", "README.md"}
+
+var showReadme bool
+
+// The description of a directory, rendered in full atop its listing
+type Readme struct {
+	File string        `json:"file"`
+	URL  *url.URL      `json:"-"`
+	HTML template.HTML `json:"-"`
+}
+
+// Looks for a readme among the files of a directory
+func findReadme(dir *Directory) (*Readme, error) {
+	if !showReadme {
+		return nil, nil
+	}
+	for _, name := range readmeNames {
+		for i := range dir.Files {
+			f := &dir.Files[i]
+			if !strings.EqualFold(f.FuzzyFile.Name, name) || f.MIME == linkMIME {
+				continue
+			}
+			raw, err := readSourceFile(f.FuzzyFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read readme %s:\n%w", f.SrcPath, err)
+			}
+			readme := &Readme{File: f.FuzzyFile.Name, URL: f.URL}
+			if readme.HTML, err = renderMarkdown(string(raw), true); err != nil {
+				return nil, fmt.Errorf("could not render readme %s:\n%w", f.SrcPath, err)
+			}
+			log.Debug().Str("path", f.FuzzyFile.Path).Msg("Found readme")
+			return readme, nil
+		}
+	}
+	return nil, nil
+}
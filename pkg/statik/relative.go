@@ -0,0 +1,106 @@
+package statik
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Links between the outputs are written relative to the page or listing
+// holding them, so that the output works from any host or over file://
+var relativeLinks bool
+
+// The location of a URL relative to the published directory from, when it
+// points within the output. Directories end with a slash, as in ./sub/ or ../
+func relativeURL(from string, u *url.URL, dir bool) *url.URL {
+	if !relativeLinks || u == nil || u.Scheme != baseURL.Scheme || u.Host != baseURL.Host {
+		return u
+	}
+	base := strings.TrimSuffix(baseURL.Path, "/")
+	if u.Path != base && !strings.HasPrefix(u.Path, base+"/") {
+		return u
+	}
+	to := strings.Split(strings.Trim(strings.TrimPrefix(u.Path, base), "/"), "/")
+	if to[0] == "" {
+		to = nil
+	}
+	var parts []string
+	if from != "." {
+		parts = strings.Split(from, "/")
+	}
+	common := 0
+	for common < len(parts) && common < len(to) && parts[common] == to[common] {
+		common++
+	}
+	rel := strings.Repeat("../", len(parts)-common)
+	if rel == "" {
+		rel = "./"
+	}
+	rel += strings.Join(to[common:], "/")
+	if dir && !strings.HasSuffix(rel, "/") {
+		rel += "/"
+	}
+	return &url.URL{Path: rel, RawQuery: u.RawQuery, Fragment: u.Fragment}
+}
+
+// The published directory an output is written into, relative to the
+// destination, which the links in it are relative to
+func pageDir(p string) string {
+	return path.Dir(filepath.ToSlash(outputPath(p)))
+}
+
+// Copies files with their links made relative to the directory from
+func relativeFiles(from string, files []File) []File {
+	if !relativeLinks || files == nil {
+		return files
+	}
+	cpy := make([]File, len(files))
+	for i, f := range files {
+		f.URL = relativeURL(from, f.URL, false)
+		f.Thumbnail = relativeURL(from, f.Thumbnail, false)
+		f.Preview = relativeURL(from, f.Preview, false)
+		if f.Torrent != nil {
+			torrent := *f.Torrent
+			torrent.URL = relativeURL(from, torrent.URL, false)
+			f.Torrent = &torrent
+		}
+		cpy[i] = f
+	}
+	return cpy
+}
+
+// Copies directories with their links made relative to the directory from
+func relativeDirs(from string, dirs []Directory) []Directory {
+	if !relativeLinks || dirs == nil {
+		return dirs
+	}
+	cpy := make([]Directory, len(dirs))
+	for i, d := range dirs {
+		d.URL = relativeURL(from, d.URL, true)
+		cpy[i] = d
+	}
+	return cpy
+}
+
+// Makes the links of a listing relative to the directory from. The listing
+// itself keeps its URL, which pages show as their title
+func (d *Directory) relativize(from string) {
+	d.Directories = relativeDirs(from, d.Directories)
+	d.Files = relativeFiles(from, d.Files)
+	d.Archived = relativeFiles(from, d.Archived)
+	d.Bundle = relativeURL(from, d.Bundle, false)
+}
+
+// Makes the links of a page relative to the directory from. OpenSearch
+// descriptions are only ever linked absolutely
+func (p *HTMLPayload) relativize(from string) {
+	if !relativeLinks {
+		return
+	}
+	p.Root.relativize(from)
+	p.Parts = relativeDirs(from, p.Parts)
+	p.Feed = relativeURL(from, p.Feed, false)
+	p.Freshness = relativeURL(from, p.Freshness, false)
+	p.ArchiveURL = relativeURL(from, p.ArchiveURL, false)
+}
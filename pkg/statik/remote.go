@@ -0,0 +1,215 @@
+package statik
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var (
+	// An rclone remote (e.g. s3:bucket/prefix) to list instead of srcDir, with
+	// the public URL its objects are reachable at
+	remoteSource string
+	remoteURL    *url.URL
+	rcloneBinary string
+)
+
+// An object as reported by `rclone lsjson`, usable as a fs.DirEntry so that
+// the include/exclude filters apply to remote listings as well
+type remoteEntry struct {
+	EntryPath string    `json:"Path"`
+	EntryName string    `json:"Name"`
+	Bytes     int64     `json:"Size"`
+	MimeType  string    `json:"MimeType"`
+	Time      time.Time `json:"ModTime"`
+	Dir       bool      `json:"IsDir"`
+	// An explicit URL for the object, as for links in scraped statik metadata
+	Link *url.URL `json:"-"`
+}
+
+func (e *remoteEntry) Name() string               { return e.EntryName }
+func (e *remoteEntry) IsDir() bool                { return e.Dir }
+func (e *remoteEntry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e *remoteEntry) Info() (fs.FileInfo, error) { return e, nil }
+func (e *remoteEntry) Size() int64                { return e.Bytes }
+func (e *remoteEntry) ModTime() time.Time         { return e.Time }
+func (e *remoteEntry) Sys() any                   { return nil }
+func (e *remoteEntry) Mode() fs.FileMode {
+	if e.Dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// Lists all objects in the remote through rclone, without downloading them
+func listRemote(remote string) (entries []*remoteEntry, err error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(buildCtx, rcloneBinary, "lsjson", "--recursive", remote)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("could not list remote %s:\n%w\n%s", remote, err, strings.TrimSpace(stderr.String()))
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		return nil, fmt.Errorf("could not parse the listing of remote %s:\n%w", remote, err)
+	}
+	return entries, nil
+}
+
+// Reads the contents of a listed file, downloading it when the source is remote
+func readSourceFile(f FuzzyFile) ([]byte, error) {
+	switch {
+	case remoteSource == "":
+		return fs.ReadFile(srcFS, f.Path)
+	case metadataSource == "" && !IsHTTPSource(remoteSource):
+		return catRemote(f.SrcPath)
+	default:
+		body, _, err := fetch(f.URL)
+		return body, err
+	}
+}
+
+// Downloads an object of the remote through rclone
+func catRemote(object string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(buildCtx, rcloneBinary, "cat", object)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// Joins the public URL of the remote with the path of an object
+func withRemoteURL(rel string) *url.URL {
+	u := *remoteURL
+	u.Path = path.Join(remoteURL.Path, rel)
+	return &u
+}
+
+func newRemoteFile(e *remoteEntry) (fz FuzzyFile, f File) {
+	mime := mimetype.Lookup(strings.Split(e.MimeType, ";")[0])
+	if mime == nil {
+		mime = mimetype.Lookup("application/octet-stream")
+	}
+	link := e.Link
+	if link == nil {
+		link = withRemoteURL(e.EntryPath)
+	}
+	fz = FuzzyFile{
+		Name:    e.EntryName,
+		Path:    e.EntryPath,
+		SrcPath: remoteSource + "/" + e.EntryPath,
+		DstPath: path.Join(dstDir, publishedPath(e.EntryPath)),
+		URL:     link,
+		MIME:    mime,
+		Mode:    e.Mode(),
+	}
+	return fz, File{
+		FuzzyFile: fz,
+		Size:      humanize.Bytes(uint64(e.Bytes)),
+		Bytes:     e.Bytes,
+		ModTime:   e.Time,
+	}
+}
+
+// Builds the directory tree out of a flat remote listing, visiting each
+// directory in the same order as walk does for local sources
+func walkRemote(entries []*remoteEntry, visit func(*Directory) error) (Directory, []FuzzyFile, error) {
+	children := map[string][]*remoteEntry{}
+	for _, e := range entries {
+		parent := path.Dir(e.EntryPath)
+		children[parent] = append(children[parent], e)
+	}
+	root := &remoteEntry{EntryPath: ".", EntryName: path.Base(remoteSource), Dir: true}
+	return walkRemoteDir(root, children, visit)
+}
+
+func walkRemoteDir(e *remoteEntry, children map[string][]*remoteEntry, visit func(*Directory) error) (dir Directory, fz []FuzzyFile, err error) {
+	var (
+		subdir Directory
+		subfz  []FuzzyFile
+	)
+	if err = buildCtx.Err(); err != nil {
+		return
+	}
+	rel := e.EntryPath
+	name := e.EntryName
+	if rel == "." && len(baseURL.Path) > 1 {
+		parts := strings.Split(baseURL.Path, string(os.PathSeparator))
+		name = parts[len(parts)-1]
+	}
+	dir = Directory{
+		Name:    name,
+		SrcPath: remoteSource + "/" + rel,
+		DstPath: path.Join(dstDir, publishedDir(rel)),
+		URL:     withBaseURL(publishedDir(rel)),
+		Path:    rel,
+		Size:    humanize.Bytes(0),
+		ModTime: e.Time,
+		Mode:    e.Mode(),
+		GenTime: time.Now(),
+	}
+	dir.Restricted = restrictionOf(rel)
+	dir.View = defaultView
+	if isUnlisted(rel) {
+		dir.Robots = robots{noIndex: true, noFollow: true}.String()
+	}
+
+	entries := children[rel]
+	walked := map[int]walkedDir{}
+	for _, i := range walkOrder(len(entries), func(i int) string { return entries[i].EntryPath }) {
+		child := entries[i]
+		if !child.IsDir() || !isRecursive || !includeDir(child) {
+			continue
+		}
+		if subdir, subfz, err = walkRemoteDir(child, children, visit); err != nil {
+			return
+		}
+		if !subdir.isEmpty() || includeEmpty {
+			if lowMemory {
+				subdir.Directories = nil
+				subdir.Files = nil
+				subdir.Archived = nil
+				subdir.Readme = nil
+			}
+			walked[i] = walkedDir{subdir, subfz}
+		}
+	}
+	for i, child := range entries {
+		if sub, ok := walked[i]; ok {
+			fz = append(fz, sub.fz...)
+			if isUnlistedDir(sub.dir.Path) {
+				continue
+			}
+			dir.TotalBytes += sub.dir.TotalBytes
+			dir.Directories = append(dir.Directories, sub.dir)
+		} else if !child.IsDir() && includeFile(child) {
+			fuzzy, file := newRemoteFile(child)
+			fz = append(fz, fuzzy)
+			dir.TotalBytes += file.Bytes
+			dir.Files = append(dir.Files, file)
+			emit(Event{Kind: FileWalked, Path: fuzzy.Path, Bytes: file.Bytes})
+		}
+	}
+	if fz, err = assembleDir(&dir, fz); err != nil {
+		return
+	}
+
+	if dir.isEmpty() && !includeEmpty && rel != "." {
+		return
+	}
+	err = visit(&dir)
+	return
+}
@@ -0,0 +1,63 @@
+//go:build unix
+
+package statik_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+// Serves a fixed listing, and the objects of dir, in place of rclone
+const rcloneStub = `#!/bin/sh
+case "$1" in
+lsjson) cat "$STUB_DIR/listing.json" ;;
+cat) cat "$STUB_DIR/${2#stub:bucket/}" ;;
+*) exit 1 ;;
+esac
+`
+
+const stubListing = `[
+	{"Path": "docs", "Name": "docs", "Size": -1, "ModTime": "2023-01-01T00:00:00Z", "IsDir": true},
+	{"Path": "docs/README.md", "Name": "README.md", "Size": 22, "MimeType": "text/markdown", "ModTime": "2023-01-02T00:00:00Z"},
+	{"Path": "docs/guide.txt", "Name": "guide.txt", "Size": 16, "MimeType": "text/plain", "ModTime": "2023-01-03T00:00:00Z"}
+]`
+
+// Remote listings are assembled as local ones are, readmes included
+func TestRemoteReadme(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"rclone":         rcloneStub,
+		"listing.json":   stubListing,
+		"docs/README.md": "# Remote documentation\n",
+		"docs/guide.txt": "Read the manual\n",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("STUB_DIR", dir)
+
+	c := statik.DefaultConfig()
+	c.Remote = "stub:bucket"
+	c.RemoteURL, _ = url.Parse("https://files.example.com/")
+	c.Rclone = filepath.Join(dir, "rclone")
+	out := statiktest.Build(t, c)
+	page, err := os.ReadFile(filepath.Join(out, "docs", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "Remote documentation") {
+		t.Error("docs/index.html does not show the readme of the remote directory")
+	}
+}
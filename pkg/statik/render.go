@@ -0,0 +1,125 @@
+package statik
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Buffers used to hold rendered pages before minification are recycled across
+// directories, as a page is usually about the same size as the previous one
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) { bufferPool.Put(buf) }
+
+// Renders a template straight into the minifier, which streams its output to
+// w instead of the whole page being buffered first
+func renderMinified(w io.Writer, t *template.Template, data any) error {
+	bw := bufio.NewWriter(w)
+	mw := minifier.Writer("text/html", bw)
+	if err := t.Execute(mw, data); err != nil {
+		mw.Close()
+		return fmt.Errorf("could not generate %s template:\n%w", t.Name(), err)
+	}
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("could not minify %s template output:\n%w", t.Name(), err)
+	}
+	return bw.Flush()
+}
+
+// Renders a standalone page, other than a listing, into a minified file
+func renderPage(dst string, t *template.Template, data any) (err error) {
+	var file *os.File
+	if file, err = os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile); err != nil {
+		return fmt.Errorf("could not create output file %s:\n%w", dst, err)
+	}
+	defer file.Close()
+	if lowMemory {
+		if err = renderMinified(file, t, data); err != nil {
+			return fmt.Errorf("could not write output file %s:\n%w", dst, err)
+		}
+		log.Printf("Generated %s", dst)
+		return finalizeFile(dst)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err = t.Execute(buf, data); err != nil {
+		return fmt.Errorf("could not generate %s template:\n%w", t.Name(), err)
+	}
+	if err = minifier.Minify("text/html", file, buf); err != nil {
+		return fmt.Errorf("could not minify %s:\n%w", dst, err)
+	}
+	log.Printf("Generated %s", dst)
+	return finalizeFile(dst)
+}
+
+// A workerPool runs jobs on a bounded number of goroutines, keeping the first
+// error returned by any of them. With a size of one or less jobs are run
+// synchronously on the calling goroutine.
+type workerPool struct {
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{}
+	if size > 1 {
+		p.sem = make(chan struct{}, size)
+	}
+	return p
+}
+
+func (p *workerPool) fail(err error) {
+	if err == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errs = append(p.errs, err)
+}
+
+// Schedules a job, blocking until a worker is available. Jobs are dropped
+// once the build has been cancelled
+func (p *workerPool) Go(job func() error) {
+	if buildCtx.Err() != nil {
+		return
+	}
+	if p.sem == nil {
+		p.fail(job())
+		return
+	}
+	p.sem <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.sem
+			p.wg.Done()
+		}()
+		p.fail(job())
+	}()
+}
+
+// Waits for all scheduled jobs to complete and returns their errors
+func (p *workerPool) Wait() error {
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return joinErrors(p.errs...)
+}
@@ -0,0 +1,59 @@
+package statik
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	reportSuccess = "success"
+	reportFailure = "failure"
+)
+
+// A Report summarizes the outcome of a build
+type Report struct {
+	Status string `json:"status"`
+	// The id embedded in the outputs written by the build
+	Build       string `json:"build"`
+	Error       string `json:"error,omitempty"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Files       int    `json:"files"`
+	Bytes       int64  `json:"bytes"`
+	// The directories which could not be read, listed without their contents
+	Unreadable []string `json:"unreadable,omitempty"`
+	// The source entries overwritten by or left out for generated outputs
+	Collisions []string `json:"collisions,omitempty"`
+	// The files delisted as expired, and the ones expiring soon
+	Expired  []string       `json:"expired,omitempty"`
+	Expiring []ExpiringFile `json:"expiring,omitempty"`
+	// The subtrees left for the next run once the time budget ran out
+	Pending  []string      `json:"pending,omitempty"`
+	Started  time.Time     `json:"started_at"`
+	Duration time.Duration `json:"-"`
+}
+
+// Records the end of the build and its outcome
+func (r *Report) finish(err error) {
+	r.Duration = time.Since(r.Started)
+	r.Status = reportSuccess
+	if err != nil {
+		r.Status = reportFailure
+		r.Error = err.Error()
+	}
+}
+
+func (r *Report) Failed() bool { return r.Status == reportFailure }
+
+func (r *Report) MarshalJSON() ([]byte, error) {
+	type ReportAlias Report
+	return json.Marshal(&struct {
+		Started  string  `json:"started_at"`
+		Duration float64 `json:"duration"`
+		*ReportAlias
+	}{
+		Started:     r.Started.Format(time.RFC3339),
+		Duration:    r.Duration.Seconds(),
+		ReportAlias: (*ReportAlias)(r),
+	})
+}
@@ -0,0 +1,136 @@
+package statik
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	htaccessFileName  = ".htaccess"
+	nginxAuthFileName = ".statik-auth.nginx.conf"
+	defaultRestricted = "restricted"
+)
+
+// A rule marking the directories whose path matches a glob as restricted,
+// e.g. private/*=staff only
+type restrictRule struct {
+	glob, label string
+}
+
+// RestrictRules is a flag.Value collecting -restrict rules
+type RestrictRules []restrictRule
+
+var (
+	restrictedDirs RestrictRules
+	// Restricted directories generated in this build, by relative path
+	restricted = map[string]*Directory{}
+)
+
+func (r *RestrictRules) String() string {
+	var rules []string
+	for _, rule := range *r {
+		rules = append(rules, rule.glob+"="+rule.label)
+	}
+	return strings.Join(rules, ",")
+}
+
+func (r *RestrictRules) Set(s string) error {
+	glob, label, _ := strings.Cut(s, "=")
+	if glob == "" {
+		return fmt.Errorf("expected a rule in the glob[=label] form, got %q", s)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid glob %q:\n%w", glob, err)
+	}
+	if label == "" {
+		label = defaultRestricted
+	}
+	*r = append(*r, restrictRule{glob, label})
+	return nil
+}
+
+// Returns the label of the first rule matching the relative directory path,
+// or an empty string when the directory is public
+func restrictionOf(rel string) string {
+	for _, rule := range restrictedDirs {
+		if ok, _ := path.Match(rule.glob, rel); ok {
+			return rule.label
+		}
+	}
+	return ""
+}
+
+// Drops the fuzzy entries living inside restricted or unlisted directories, so
+// that the search index doesn't leak their contents
+func withoutRestricted(fz []FuzzyFile) []FuzzyFile {
+	if len(restrictedDirs) == 0 && len(unlistedDirs) == 0 {
+		return fz
+	}
+	var kept []FuzzyFile
+	for _, f := range fz {
+		if dir := path.Dir(f.Path); !isRestricted(dir) && !isUnlisted(dir) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// Reports whether a directory, given by its relative path, is restricted or
+// lives inside a restricted directory
+func isRestricted(dir string) bool {
+	for ; ; dir = path.Dir(dir) {
+		if restrictionOf(dir) != "" {
+			return true
+		}
+		if dir == "." || dir == "/" {
+			return false
+		}
+	}
+}
+
+var htaccessTemplate = template.Must(template.New("htaccess").Parse(`# Generated by statik: fill in the path of the password file
+AuthType Basic
+AuthName "{{ . }}"
+AuthUserFile /path/to/.htpasswd
+Require valid-user
+`))
+
+var nginxAuthTemplate = template.Must(template.New("nginx").Parse(`# Generated by statik: include in the server block and fill in the path of
+# the password file
+{{ range . }}
+location {{ .Path }}{{ if ne .Path "/" }}/{{ end }} {
+	auth_basic "{{ .Label }}";
+	auth_basic_user_file /path/to/.htpasswd;
+}
+{{ end }}`))
+
+// Writes an Apache auth stub into a restricted directory and remembers it for
+// the nginx snippet
+func writeHtaccess(dir *Directory) error {
+	restricted[dir.Path] = &Directory{Path: dir.Path, URL: dir.URL, Restricted: dir.Restricted}
+	return writeTemplate(path.Join(dir.DstPath, htaccessFileName), htaccessTemplate, dir.Restricted)
+}
+
+// A location of the site which requires authentication, along with the
+// path of the directory relative to the output
+type authLocation struct{ Path, Dir, Label string }
+
+// Returns the locations of all restricted directories, sorted by path
+func restrictedLocations() (locations []authLocation) {
+	for _, dir := range restricted {
+		locations = append(locations, authLocation{"/" + strings.Trim(dir.URL.Path, "/"), dir.Path, dir.Restricted})
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Path < locations[j].Path })
+	return
+}
+
+// Writes the nginx auth snippet covering all restricted directories
+func writeNginxAuth() error {
+	if len(restricted) == 0 {
+		return nil
+	}
+	return writeTemplate(path.Join(dstDir, nginxAuthFileName), nginxAuthTemplate, restrictedLocations())
+}
@@ -0,0 +1,61 @@
+package statik_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+// An interrupted build is completed by resuming it, copying only what is
+// missing or has changed since
+func TestResume(t *testing.T) {
+	fsys := statiktest.Fixture()
+	var copied []string
+	c := statik.DefaultConfig()
+	c.FS = fsys
+	c.Targets = []string{"html", "json", "tree", "sitemap"}
+	c.Destination = filepath.Join(t.TempDir(), "out")
+	c.Resume = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Events = func(e statik.Event) {
+		if e.Kind == statik.FileCopied {
+			copied = append(copied, e.Path)
+			cancel()
+		}
+	}
+	if _, err := statik.Generate(ctx, c); !statik.Interrupted(err) {
+		t.Fatalf("the build has not been interrupted: %v", err)
+	}
+	if len(copied) != 1 {
+		t.Fatalf("copied %v before the interruption, want a single file", copied)
+	}
+	first := copied[0]
+
+	copied = nil
+	c.Events = func(e statik.Event) {
+		if e.Kind == statik.FileCopied {
+			copied = append(copied, e.Path)
+		}
+	}
+	out := statiktest.Build(t, c)
+	for _, p := range copied {
+		if p == first {
+			t.Errorf("%s has been copied again when resuming", p)
+		}
+	}
+	statiktest.CompareGolden(t, out, filepath.Join("testdata", "golden", "default"))
+
+	copied = nil
+	fsys["README.txt"] = &fstest.MapFile{Data: []byte("Changed\n"), Mode: 0o644, ModTime: statiktest.FixtureEpoch.Add(time.Hour)}
+	statiktest.Build(t, c)
+	if len(copied) != 1 || copied[0] != "README.txt" {
+		t.Errorf("copied %v when resuming after a change, want README.txt only", copied)
+	}
+}
@@ -0,0 +1,59 @@
+package statik
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// Directives for search engines, as given in the robots meta tag
+type robots struct {
+	noIndex, noFollow bool
+}
+
+func (r robots) String() string {
+	var directives []string
+	if r.noIndex {
+		directives = append(directives, "noindex")
+	}
+	if r.noFollow {
+		directives = append(directives, "nofollow")
+	}
+	return strings.Join(directives, ", ")
+}
+
+var (
+	// Robots directives by directory, for the current build
+	robotsMu    sync.Mutex
+	robotsCache map[string]robots
+)
+
+// The robots directives of a directory, inherited from its parent and
+// overridden by its own settings file
+func robotsOf(rel string) robots {
+	robotsMu.Lock()
+	defer robotsMu.Unlock()
+	return lookupRobots(rel)
+}
+
+func lookupRobots(rel string) robots {
+	if robotsCache == nil {
+		robotsCache = map[string]robots{}
+	}
+	if r, ok := robotsCache[rel]; ok {
+		return r
+	}
+	var r robots
+	if rel != "." {
+		r = lookupRobots(path.Dir(rel))
+	}
+	s := settingsOf(rel)
+	if s.noIndex != nil {
+		r.noIndex = *s.noIndex
+	}
+	if s.noFollow != nil {
+		r.noFollow = *s.noFollow
+	}
+	robotsCache[rel] = r
+	return r
+}
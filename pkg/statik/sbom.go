@@ -0,0 +1,184 @@
+package statik
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SPDXBOM      = "spdx"
+	CycloneDXBOM = "cyclonedx"
+)
+
+// The format of the sbom target
+var sbomFormat string
+
+// Describes every public file, with its checksum and license when known, in
+// an SPDX or CycloneDX document at the root for supply chain tooling
+type sbomTarget struct {
+	fileCollector
+}
+
+func sbomFileName() string {
+	if sbomFormat == CycloneDXBOM {
+		return "sbom.cdx.json"
+	}
+	return "sbom.spdx.json"
+}
+
+func (*sbomTarget) Name() string { return "sbom" }
+
+func (*sbomTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{sbomFileName()}
+	}
+	return nil
+}
+
+func (t *sbomTarget) Finish(root *Directory, _ []FuzzyFile) error {
+	p := path.Join(root.DstPath, sbomFileName())
+	if sbomFormat == CycloneDXBOM {
+		return jsonToFile(p, cycloneDXDocument(root, t.files()))
+	}
+	return jsonToFile(p, spdxDocument(root, t.files()))
+}
+
+// The algorithm and hex digest of a checksum, with the algorithm spelled as
+// in SPDX. SPDX and CycloneDX only differ in the dash of the SHA family
+func splitChecksum(checksum string) (algorithm, digest string, ok bool) {
+	if algorithm, digest, ok = strings.Cut(checksum, ":"); ok {
+		algorithm = strings.ToUpper(algorithm)
+	}
+	return
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxFile struct {
+	Name      string         `json:"fileName"`
+	ID        string         `json:"SPDXID"`
+	Checksums []spdxChecksum `json:"checksums"`
+	// The license found in the file, NOASSERTION when none was detected
+	LicenseInfo []string `json:"licenseInfoInFiles"`
+	License     string   `json:"licenseConcluded"`
+	Copyright   string   `json:"copyrightText"`
+	Comment     string   `json:"comment,omitempty"`
+}
+
+func spdxDocument(root *Directory, files []File) any {
+	type creationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+	doc := struct {
+		Version      string       `json:"spdxVersion"`
+		DataLicense  string       `json:"dataLicense"`
+		ID           string       `json:"SPDXID"`
+		Name         string       `json:"name"`
+		Namespace    string       `json:"documentNamespace"`
+		CreationInfo creationInfo `json:"creationInfo"`
+		Files        []spdxFile   `json:"files"`
+	}{
+		Version:      "SPDX-2.3",
+		DataLicense:  "CC0-1.0",
+		ID:           "SPDXRef-DOCUMENT",
+		Name:         root.Name,
+		Namespace:    pageURL(root.URL) + "spdx/" + buildID,
+		CreationInfo: creationInfo{Created: root.GenTime.UTC().Format(time.RFC3339), Creators: []string{"Tool: statik"}},
+		Files:        []spdxFile{},
+	}
+	for i, f := range files {
+		file := spdxFile{
+			Name:        "./" + f.FuzzyFile.Path,
+			ID:          "SPDXRef-File-" + strconv.Itoa(i+1),
+			Checksums:   []spdxChecksum{},
+			LicenseInfo: []string{"NOASSERTION"},
+			License:     "NOASSERTION",
+			Copyright:   "NOASSERTION",
+			Comment:     fmt.Sprintf("%d bytes, published at %s", f.Bytes, f.URL),
+		}
+		if algorithm, digest, ok := splitChecksum(f.Checksum); ok {
+			file.Checksums = append(file.Checksums, spdxChecksum{algorithm, digest})
+		}
+		if f.License != "" {
+			file.LicenseInfo = []string{f.License}
+		}
+		doc.Files = append(doc.Files, file)
+	}
+	return &doc
+}
+
+type cycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	Ref        string              `json:"bom-ref"`
+	Name       string              `json:"name"`
+	MIME       string              `json:"mime-type,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	Licenses   []map[string]string `json:"licenses,omitempty"`
+	References []map[string]string `json:"externalReferences"`
+	Properties []cycloneDXProperty `json:"properties"`
+}
+
+func cycloneDXDocument(root *Directory, files []File) any {
+	type tool struct {
+		Name string `json:"name"`
+	}
+	type metadata struct {
+		Timestamp string `json:"timestamp"`
+		Tools     []tool `json:"tools"`
+	}
+	doc := struct {
+		Format     string               `json:"bomFormat"`
+		Spec       string               `json:"specVersion"`
+		Serial     string               `json:"serialNumber"`
+		Version    int                  `json:"version"`
+		Metadata   metadata             `json:"metadata"`
+		Components []cycloneDXComponent `json:"components"`
+	}{
+		Format:     "CycloneDX",
+		Spec:       "1.5",
+		Serial:     "urn:uuid:" + buildUUID(),
+		Version:    1,
+		Metadata:   metadata{Timestamp: root.GenTime.UTC().Format(time.RFC3339), Tools: []tool{{"statik"}}},
+		Components: []cycloneDXComponent{},
+	}
+	for _, f := range files {
+		media, _, _ := strings.Cut(f.MIME.String(), ";")
+		component := cycloneDXComponent{
+			Type:       "file",
+			Ref:        f.FuzzyFile.Path,
+			Name:       f.FuzzyFile.Path,
+			MIME:       media,
+			References: []map[string]string{{"type": "distribution", "url": f.URL.String()}},
+			Properties: []cycloneDXProperty{{"statik:size_bytes", strconv.FormatInt(f.Bytes, 10)}},
+		}
+		if algorithm, digest, ok := splitChecksum(f.Checksum); ok {
+			// SHA256 is spelled SHA-256, MD5 stays as it is
+			if strings.HasPrefix(algorithm, "SHA") {
+				algorithm = "SHA-" + strings.TrimPrefix(algorithm, "SHA")
+			}
+			component.Hashes = []cycloneDXHash{{algorithm, digest}}
+		}
+		if f.License != "" {
+			component.Licenses = []map[string]string{{"expression": f.License}}
+		}
+		doc.Components = append(doc.Components, component)
+	}
+	return &doc
+}
@@ -0,0 +1,216 @@
+package statik
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+const scrapeTimeout = 30 * time.Second
+
+var (
+	scrapeClient = &http.Client{Timeout: scrapeTimeout}
+
+	// Timestamps and sizes as they appear next to links in the autoindex pages
+	// generated by Apache, nginx and lighttpd
+	indexTimeRegEx   = regexp.MustCompile(`\d{2}-[A-Z][a-z]{2}-\d{4} \d{2}:\d{2}|\d{4}-\d{2}-\d{2} \d{2}:\d{2}|\d{4}-[A-Z][a-z]{2}-\d{2} \d{2}:\d{2}:\d{2}`)
+	indexSizeRegEx   = regexp.MustCompile(`(?:^|\s)(\d+(?:\.\d+)?[KMGT]?)(?:\s|$)`)
+	indexTimeFormats = []string{"02-Jan-2006 15:04", "2006-01-02 15:04", "2006-Jan-02 15:04:05"}
+)
+
+// IsHTTPSource reports whether a remote source is an HTTP listing
+func IsHTTPSource(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// The subset of a statik.json file needed to reconstruct the listing.
+// Subdirectories only carry their own entries in hand-assembled trees, as
+// each listing describes a single level
+type scrapedDirectory struct {
+	Name        string             `json:"name"`
+	Path        string             `json:"path"`
+	Time        string             `json:"time"`
+	Directories []scrapedDirectory `json:"directories"`
+	Files       []scrapedFile      `json:"files"`
+}
+
+type scrapedFile struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	MIME string `json:"mime"`
+	Size string `json:"size"`
+	Time string `json:"time"`
+	// Only present from version 2 of the metadata
+	Bytes *int64 `json:"size_bytes"`
+}
+
+func (d *scrapedDirectory) isEmpty() bool { return len(d.Directories) == 0 && len(d.Files) == 0 }
+
+// The entry of a file listed in statik metadata, without an explicit URL
+func (f *scrapedFile) entry(rel string) *remoteEntry {
+	size, _ := humanize.ParseBytes(f.Size)
+	if f.Bytes != nil {
+		size = uint64(*f.Bytes)
+	}
+	return &remoteEntry{
+		EntryPath: path.Join(rel, f.Name),
+		EntryName: f.Name,
+		Bytes:     int64(size),
+		MimeType:  f.MIME,
+		Time:      parseTime(f.Time),
+	}
+}
+
+func fetch(u *url.URL) (body []byte, contentType string, err error) {
+	var (
+		req *http.Request
+		res *http.Response
+	)
+	if req, err = http.NewRequestWithContext(buildCtx, http.MethodGet, u.String(), nil); err != nil {
+		return nil, "", err
+	}
+	if res, err = scrapeClient.Do(req); err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected response status %s for %s", res.Status, u)
+	}
+	body, err = io.ReadAll(res.Body)
+	return body, res.Header.Get("Content-Type"), err
+}
+
+// Crawls a remote listing, preferring the statik.json metadata of an existing
+// statik deployment and falling back to parsing autoindex HTML pages
+func scrapeRemote(root *url.URL) (entries []*remoteEntry, err error) {
+	base := *root
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return scrapeDir(&base, ".", entries)
+}
+
+func scrapeDir(u *url.URL, rel string, entries []*remoteEntry) ([]*remoteEntry, error) {
+	log.Debug().Str("url", u.String()).Msg("Scraping remote directory")
+	if body, _, err := fetch(u.ResolveReference(&url.URL{Path: metadataFileName})); err == nil {
+		var dir scrapedDirectory
+		if err = json.Unmarshal(body, &dir); err == nil {
+			return scrapeStatik(u, rel, &dir, entries)
+		}
+	}
+
+	body, contentType, err := fetch(u)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch remote listing:\n%w", err)
+	}
+	if !strings.HasPrefix(contentType, "text/html") {
+		return nil, fmt.Errorf("remote listing %s is not an HTML page", u)
+	}
+	return scrapeAutoindex(u, rel, string(body), entries)
+}
+
+func parseTime(raw string) time.Time {
+	t, _ := time.Parse(time.RFC3339, raw)
+	return t
+}
+
+func scrapeStatik(u *url.URL, rel string, dir *scrapedDirectory, entries []*remoteEntry) (_ []*remoteEntry, err error) {
+	for _, f := range dir.Files {
+		// Files are linked relative to the scraped URL, as the base URL of
+		// the original deployment may not be reachable. Links are kept as-is
+		entry := f.entry(rel)
+		if f.MIME == linkMIME.String() {
+			entry.Link, _ = url.Parse(f.URL)
+		}
+		entries = append(entries, entry)
+	}
+	for _, d := range dir.Directories {
+		sub := path.Join(rel, d.Name)
+		entries = append(entries, &remoteEntry{EntryPath: sub, EntryName: d.Name, Time: parseTime(d.Time), Dir: true})
+		if !isRecursive {
+			continue
+		}
+		if entries, err = scrapeDir(u.ResolveReference(&url.URL{Path: url.PathEscape(d.Name) + "/"}), sub, entries); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Extracts the entries of an autoindex page: every link pointing to a direct
+// child of the page, with the time and size found in the text following it
+func scrapeAutoindex(u *url.URL, rel string, body string, entries []*remoteEntry) (_ []*remoteEntry, err error) {
+	type link struct {
+		href, text string
+	}
+	var (
+		links   []link
+		current *link
+	)
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		switch {
+		case tt == html.StartTagToken && token.Data == "a":
+			for _, attr := range token.Attr {
+				if attr.Key == "href" {
+					links = append(links, link{href: attr.Val})
+					current = &links[len(links)-1]
+				}
+			}
+		case tt == html.TextToken && current != nil:
+			current.text += " " + token.Data
+		}
+	}
+
+	for _, l := range links {
+		target, err := u.Parse(l.href)
+		if err != nil || target.RawQuery != "" || target.Host != u.Host {
+			continue
+		}
+		name, isDir := strings.CutSuffix(strings.TrimPrefix(target.Path, u.Path), "/")
+		if name == "" || strings.Contains(name, "/") || name == ".." {
+			continue
+		}
+		entry := &remoteEntry{
+			EntryPath: path.Join(rel, name),
+			EntryName: name,
+			Dir:       isDir,
+			MimeType:  mime.TypeByExtension(path.Ext(name)),
+		}
+		if t := indexTimeRegEx.FindString(l.text); t != "" {
+			for _, format := range indexTimeFormats {
+				if entry.Time, err = time.Parse(format, t); err == nil {
+					break
+				}
+			}
+			l.text = strings.Replace(l.text, t, "", 1)
+		}
+		if m := indexSizeRegEx.FindStringSubmatch(l.text); m != nil && !isDir {
+			size, _ := humanize.ParseBytes(m[1])
+			entry.Bytes = int64(size)
+		}
+		entries = append(entries, entry)
+		if isDir && isRecursive {
+			if entries, err = scrapeDir(target, entry.EntryPath, entries); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
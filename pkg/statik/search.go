@@ -0,0 +1,89 @@
+package statik
+
+import (
+	"encoding/xml"
+	"html/template"
+	"net/url"
+	"path"
+)
+
+const (
+	searchFileName     = "search.html"
+	openSearchFileName = "opensearch.xml"
+	// The longest short name OpenSearch allows
+	openSearchNameLength = 16
+)
+
+var searchTemplate = template.Must(template.New("search").Parse(defaultSearchTemplate))
+
+// A search page looking up the fuzzy.json index, along with an OpenSearch
+// description at the root for browsers to register it as a search engine
+type searchTarget struct{}
+
+type SearchPayload struct {
+	HTMLPayload
+	// Where the search index is published
+	Index *url.URL
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Rel      string `xml:"rel,attr,omitempty"`
+	Template string `xml:"template,attr"`
+}
+
+func (searchTarget) Name() string { return "search" }
+func (searchTarget) Start() error { return nil }
+
+func (searchTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{searchFileName, openSearchFileName}
+	}
+	return nil
+}
+
+func (searchTarget) Directory(*Directory) error { return nil }
+
+func (searchTarget) Finish(root *Directory, _ []FuzzyFile) (err error) {
+	payload := SearchPayload{HTMLPayload: newPayload(root), Index: relativeURL(".", withBaseURL(fuzzyFileName), false)}
+	payload.Root.Directories, payload.Root.Files = nil, nil
+	payload.relativize(".")
+	if err = renderPage(path.Join(root.DstPath, searchFileName), searchTemplate, payload); err != nil {
+		return
+	}
+
+	name := []rune(root.Name)
+	if len(name) > openSearchNameLength {
+		name = name[:openSearchNameLength]
+	}
+	page := withBaseURL(searchFileName).String() + "?q={searchTerms}"
+	description := struct {
+		XMLName       xml.Name        `xml:"OpenSearchDescription"`
+		XMLNS         string          `xml:"xmlns,attr"`
+		ShortName     string          `xml:"ShortName"`
+		Description   string          `xml:"Description"`
+		InputEncoding string          `xml:"InputEncoding"`
+		URLs          []openSearchURL `xml:"Url"`
+	}{
+		XMLNS:         "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:     string(name),
+		Description:   "Search the files of " + pageURL(root.URL),
+		InputEncoding: "UTF-8",
+		URLs: []openSearchURL{
+			{Type: "text/html", Template: page},
+			{Type: "application/opensearchdescription+xml", Rel: "self", Template: searchURL().String()},
+		},
+	}
+	return xmlToFile(path.Join(root.DstPath, openSearchFileName), &description)
+}
+
+// The URL of the OpenSearch description, nil when the search target is not
+// enabled
+func searchURL() *url.URL {
+	for _, t := range enabledTargets {
+		if t.Name() == "search" {
+			return withBaseURL(openSearchFileName)
+		}
+	}
+	return nil
+}
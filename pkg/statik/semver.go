@@ -0,0 +1,202 @@
+package statik
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const latestAlias = "latest"
+
+var (
+	semverAware bool
+	latestStub  bool
+
+	semverRegEx = regexp.MustCompile(`v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z.-]+)?`)
+)
+
+// A semantic version found in a file name, along with the text surrounding it
+// which identifies the group of files it should be compared within
+type version struct {
+	prefix, suffix      string
+	major, minor, patch int
+	pre                 []string
+}
+
+func parseVersion(name string) *version {
+	m := semverRegEx.FindStringSubmatchIndex(name)
+	if m == nil {
+		return nil
+	}
+	v := &version{prefix: name[:m[0]], suffix: name[m[1]:]}
+	v.major, _ = strconv.Atoi(name[m[2]:m[3]])
+	v.minor, _ = strconv.Atoi(name[m[4]:m[5]])
+	v.patch, _ = strconv.Atoi(name[m[6]:m[7]])
+	if m[8] >= 0 {
+		v.pre = strings.Split(name[m[8]:m[9]], ".")
+	}
+	return v
+}
+
+func (v *version) group() string { return v.prefix + "\x00" + v.suffix }
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Compares two versions following the semver precedence rules
+func (v *version) compare(o *version) int {
+	if c := compareInts(v.major, o.major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.minor, o.minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.patch, o.patch); c != 0 {
+		return c
+	}
+	// A pre-release has a lower precedence than the associated release
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		a, aErr := strconv.Atoi(v.pre[i])
+		b, bErr := strconv.Atoi(o.pre[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInts(a, b); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(v.pre[i], o.pre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(v.pre), len(o.pre))
+}
+
+// Orders names lexically, except for names carrying a version within the same
+// group, which are ordered by version
+func lessName(a, b string) bool {
+	if semverAware {
+		va, vb := parseVersion(a), parseVersion(b)
+		if va != nil && vb != nil && va.group() == vb.group() {
+			if c := va.compare(vb); c != 0 {
+				return c < 0
+			}
+		}
+	}
+	return a < b
+}
+
+// Returns the index of the newest stable release in each group of versioned
+// names
+func newest[T Named](entries []T) (latest map[int]bool) {
+	best := map[string]int{}
+	versions := make([]*version, len(entries))
+	for i, e := range entries {
+		v := parseVersion(e.GetName())
+		if v == nil || len(v.pre) != 0 {
+			continue
+		}
+		versions[i] = v
+		if j, ok := best[v.group()]; !ok || versions[j].compare(v) < 0 {
+			best[v.group()] = i
+		}
+	}
+	latest = map[int]bool{}
+	for _, i := range best {
+		latest[i] = true
+	}
+	return
+}
+
+// Flags the newest stable release among the versioned files and directories
+func markLatest(dir *Directory) {
+	if !semverAware {
+		return
+	}
+	for i := range newest(dir.Directories) {
+		dir.Directories[i].Latest = true
+	}
+	for i := range newest(dir.Files) {
+		dir.Files[i].Latest = true
+	}
+}
+
+var latestStubTemplate = template.Must(template.New("latest").Parse(`<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta http-equiv="refresh" content="0; url={{ . }}">
+    <link rel="canonical" href="{{ . }}">
+    <title>Redirecting to {{ . }}</title>
+  </head>
+  <body><a href="{{ . }}">{{ . }}</a></body>
+</html>
+`))
+
+// Writes a latest/ redirect stub pointing to the newest versioned directory,
+// unless an entry called latest already exists
+func writeLatestStub(dir *Directory) (err error) {
+	var target *Directory
+	for i := range dir.Directories {
+		if dir.Directories[i].Name == latestAlias {
+			return nil
+		}
+		if dir.Directories[i].Latest {
+			target = &dir.Directories[i]
+		}
+	}
+	for _, f := range dir.Files {
+		if f.FuzzyFile.Name == latestAlias {
+			return nil
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	return writeRedirect(path.Join(dir.DstPath, latestAlias), target.URL.String(), dir.Mode)
+}
+
+// Writes a page redirecting to the target URL as the index of stubDir
+func writeRedirect(stubDir, target string, mode os.FileMode) (err error) {
+	if err = os.MkdirAll(stubDir, os.ModeDir|os.ModePerm); err != nil {
+		return fmt.Errorf("could not create output directory %s:\n%w", stubDir, err)
+	}
+	stub := path.Join(stubDir, "index.html")
+	file, err := os.OpenFile(stub, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile)
+	if err != nil {
+		return fmt.Errorf("could not create output file %s:\n%w", stub, err)
+	}
+	defer file.Close()
+	if err = latestStubTemplate.Execute(file, target); err != nil {
+		return fmt.Errorf("could not generate redirect stub %s:\n%w", stub, err)
+	}
+	log.Printf("Generated %s", stub)
+	if err = finalizeFile(stub); err != nil {
+		return err
+	}
+	return finalizeDir(stubDir, mode)
+}
@@ -0,0 +1,25 @@
+package statik
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestLessName(t *testing.T) {
+	semverAware = true
+	defer func() { semverAware = false }()
+	names := []string{
+		"app-1.10.0.tar.gz", "app-1.2.0.tar.gz", "app-v2.0.0.tar.gz", "app-1.9.9.zip", "README",
+		"v1.2.0", "v1.2.0-rc.1", "v1.2.0-beta", "v1.2.0-rc.10", "v1.2.0-rc.2", "v1.10.0", "v0.9.0",
+	}
+	want := []string{
+		"README", "app-1.2.0.tar.gz", "app-1.10.0.tar.gz", "app-1.9.9.zip", "app-v2.0.0.tar.gz",
+		"v0.9.0", "v1.2.0-beta", "v1.2.0-rc.1", "v1.2.0-rc.2", "v1.2.0-rc.10", "v1.2.0", "v1.10.0",
+	}
+	sort.SliceStable(names, func(i, j int) bool { return lessName(names[i], names[j]) })
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("sorted names = %q, want %q", names, want)
+		}
+	}
+}
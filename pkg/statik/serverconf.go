@@ -0,0 +1,196 @@
+package statik
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// Web servers to generate a configuration snippet for
+	serverConfigs         []string
+	registerMIMETypes     bool
+	serverConfigTemplates = map[string]struct {
+		file string
+		tmpl *template.Template
+	}{
+		"nginx":  {".statik.nginx.conf", nginxConfigTemplate},
+		"apache": {".statik.apache.conf", apacheConfigTemplate},
+		"caddy":  {".statik.Caddyfile", caddyConfigTemplate},
+	}
+)
+
+// The data server configuration snippets are rendered with
+type serverConfig struct {
+	// The path the site is served under, with a trailing slash
+	Root  string
+	Dir   string
+	Types []mimeType
+	Auth  []authLocation
+}
+
+type mimeType struct{ Ext, MIME string }
+
+// ParseServerConfigs parses a comma separated list of web servers
+func ParseServerConfigs(s string) (servers []string, err error) {
+	for _, server := range strings.Split(s, ",") {
+		if server = strings.TrimSpace(server); server == "" {
+			continue
+		}
+		if _, ok := serverConfigTemplates[server]; !ok {
+			return nil, fmt.Errorf("unknown web server %q, expected nginx, apache or caddy", server)
+		}
+		servers = append(servers, server)
+	}
+	return
+}
+
+// Renders a template into the file at p
+func writeTemplate(p string, t *template.Template, data any) (err error) {
+	var file *os.File
+	if file, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile); err != nil {
+		return fmt.Errorf("could not create %s:\n%w", p, err)
+	}
+	defer file.Close()
+	if err = t.Execute(file, data); err != nil {
+		return fmt.Errorf("could not write %s:\n%w", p, err)
+	}
+	return finalizeFile(p)
+}
+
+// Collects the content types detected for the listed files with extensions
+// unknown to the standard tables, so that the web server serves them the same
+// way they are shown in the listing
+func detectedTypes(fz []FuzzyFile) (types []mimeType) {
+	seen := map[string]bool{}
+	for _, f := range fz {
+		ext := strings.ToLower(path.Ext(f.Name))
+		if ext == "" || seen[ext] || mime.TypeByExtension(ext) != "" ||
+			f.MIME == linkMIME || f.MIME.Is("application/octet-stream") {
+			continue
+		}
+		seen[ext] = true
+		typ, _, _ := strings.Cut(f.MIME.String(), ";")
+		types = append(types, mimeType{ext[1:], typ})
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Ext < types[j].Ext })
+	return
+}
+
+// Writes the configuration snippets of the requested web servers
+func writeServerConfigs(fz []FuzzyFile) (err error) {
+	var dir string
+	if len(serverConfigs) == 0 {
+		return nil
+	}
+	// Staged outputs are served from where they end up
+	if dir, err = filepath.Abs(finalOutputDir()); err != nil {
+		return fmt.Errorf("could not resolve the output directory %s:\n%w", finalOutputDir(), err)
+	}
+	conf := serverConfig{
+		Root:  "/" + strings.TrimPrefix(strings.TrimSuffix(baseURL.Path, "/")+"/", "/"),
+		Dir:   dir,
+		Types: detectedTypes(fz),
+		Auth:  restrictedLocations(),
+	}
+	for _, server := range serverConfigs {
+		t := serverConfigTemplates[server]
+		if err = writeTemplate(path.Join(dstDir, t.file), t.tmpl, conf); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+var nginxConfigTemplate = template.Must(template.New("nginx").Parse(`# Generated by statik: include in the server block serving the site
+location {{ .Root }} {
+	alias {{ .Dir }}/;
+	index index.html;
+	try_files $uri $uri/index.html =404;
+	gzip_static on;
+	location ~ /\.statik { deny all; }
+	include mime.types;
+{{- if .Types }}
+	types {
+{{- range .Types }}
+		{{ .MIME }} {{ .Ext }};
+{{- end }}
+	}
+{{- end }}
+}
+{{ range .Auth }}
+location {{ .Path }}{{ if ne .Path "/" }}/{{ end }} {
+	alias {{ $.Dir }}/{{ if ne .Dir "." }}{{ .Dir }}/{{ end }};
+	auth_basic "{{ .Label }}";
+	auth_basic_user_file /path/to/.htpasswd;
+}
+{{ end }}`))
+
+var apacheConfigTemplate = template.Must(template.New("apache").Parse(`# Generated by statik: include in the virtual host serving the site
+Alias "{{ .Root }}" "{{ .Dir }}/"
+<Directory "{{ .Dir }}">
+	Options -Indexes
+	DirectoryIndex index.html
+	AllowOverride AuthConfig
+	Require all granted
+	<FilesMatch "^\.statik">
+		Require all denied
+	</FilesMatch>
+</Directory>
+{{- if .Types }}
+<IfModule mod_mime.c>
+{{- range .Types }}
+	AddType {{ .MIME }} .{{ .Ext }}
+{{- end }}
+</IfModule>
+{{- end }}
+{{ range .Auth }}
+<Location "{{ .Path }}">
+	AuthType Basic
+	AuthName "{{ .Label }}"
+	AuthUserFile /path/to/.htpasswd
+	Require valid-user
+</Location>
+{{ end }}`))
+
+var caddyConfigTemplate = template.Must(template.New("caddy").Parse(`# Generated by statik: import in the site block serving the site
+handle_path {{ .Root }}* {
+	root * {{ .Dir }}
+	@hidden path /.statik*
+	respond @hidden 404
+{{- range .Types }}
+	@ext_{{ .Ext }} path *.{{ .Ext }}
+	header @ext_{{ .Ext }} Content-Type {{ .MIME }}
+{{- end }}
+{{- range .Auth }}
+	basicauth {{ .Path }}/* {
+		# user hashed_password
+	}
+{{- end }}
+	try_files {path} {path}/index.html
+	file_server {
+		precompressed br gzip
+	}
+}
+`))
+
+// Registers the content types detected for the listed files with the mime
+// package, so that a server running in the same process serves them the same
+// way they are shown in the listing
+func registerTypes(fz []FuzzyFile) {
+	if !registerMIMETypes {
+		return
+	}
+	for _, t := range detectedTypes(fz) {
+		if err := mime.AddExtensionType("."+t.Ext, t.MIME); err != nil {
+			log.Debug().Err(err).Str("ext", t.Ext).Msg("Could not register content type")
+		}
+	}
+}
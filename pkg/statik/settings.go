@@ -0,0 +1,241 @@
+package statik
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The settings file of a directory. Robots directives apply to the directory
+// it lives in and all of its descendants unless they override them, while the
+// title, description, order and annotations only describe its own listing
+const dirSettingsFileName = ".statik.yml"
+
+// The contents of a settings file, where the robots directives are only set
+// when given, as they are otherwise inherited
+type dirSettings struct {
+	noIndex, noFollow  *bool
+	title, description string
+	// When the file a sidecar describes expires
+	expires time.Time
+	// Entries shown first, in this order, ahead of the sorted rest
+	order []string
+	// Annotations of the entries of the directory, by name
+	entries map[string]string
+}
+
+var (
+	// Settings by directory, for the current build
+	settingsMu    sync.Mutex
+	settingsCache map[string]*dirSettings
+)
+
+// The settings file of a directory, empty when it has none or the listing
+// does not come from a source directory
+func settingsOf(rel string) *dirSettings {
+	if remoteSource != "" {
+		return &dirSettings{}
+	}
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	if settingsCache == nil {
+		settingsCache = map[string]*dirSettings{}
+	}
+	if s, ok := settingsCache[rel]; ok {
+		return s
+	}
+	s := &dirSettings{}
+	p := path.Join(rel, dirSettingsFileName)
+	raw, err := fs.ReadFile(srcFS, p)
+	if err == nil {
+		err = parseSettings(raw, s)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Warn().Err(err).Str("path", p).Msg("Could not read directory settings")
+		warn(err, p)
+	}
+	settingsCache[rel] = s
+	return s
+}
+
+// Sets the title and description of a directory from its settings, the
+// description falling back to the annotation its parent gives it
+func describeDir(dir *Directory) {
+	s := settingsOf(dir.Path)
+	dir.Title, dir.Description = s.title, s.description
+	if dir.Description == "" && dir.Path != "." {
+		dir.Description = settingsOf(path.Dir(dir.Path)).entries[path.Base(dir.Path)]
+	}
+}
+
+// Annotates the files of a directory which have no sidecar and moves the
+// entries its settings list in their order ahead of the others
+func arrangeEntries(dir *Directory) {
+	s := settingsOf(dir.Path)
+	for i := range dir.Files {
+		if note, ok := s.entries[dir.Files[i].Name]; ok && dir.Files[i].Description == "" {
+			dir.Files[i].Description = note
+		}
+	}
+	if len(s.order) != 0 {
+		orderFirst(dir.Files, s.order)
+		orderFirst(dir.Directories, s.order)
+	}
+}
+
+// Stably moves the entries named in order to the front, in that order
+func orderFirst[T Named](entries []T, order []string) {
+	rank := map[string]int{}
+	for i, name := range order {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		ri, iok := rank[entries[i].GetName()]
+		rj, jok := rank[entries[j].GetName()]
+		if iok && jok {
+			return ri < rj
+		}
+		return iok && !jok
+	})
+}
+
+// Strips a trailing comment, which starts with a # at the beginning of the
+// line or after a space
+func stripComment(text string) string {
+	if strings.HasPrefix(strings.TrimSpace(text), "#") {
+		return ""
+	}
+	if i := strings.Index(text, " #"); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+// Strips the quotes around a value, if any
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Splits a key: value pair, where the key may be quoted to hold a colon
+func cutKey(text string) (key, value string, ok bool) {
+	if q := text[0]; q == '"' || q == '\'' {
+		if end := strings.IndexByte(text[1:], q); end >= 0 {
+			key, rest := text[1:end+1], strings.TrimSpace(text[end+2:])
+			value, ok = strings.CutPrefix(rest, ":")
+			return key, unquote(value), ok
+		}
+	}
+	key, value, ok = strings.Cut(text, ":")
+	return strings.TrimSpace(key), unquote(value), ok
+}
+
+func parseBool(line int, key, value string) (*bool, error) {
+	var b bool
+	switch strings.ToLower(value) {
+	case "true", "yes", "on":
+		b = true
+	case "false", "no", "off":
+	default:
+		return nil, fmt.Errorf("line %d: expected a boolean for %s, got %q", line, key, value)
+	}
+	return &b, nil
+}
+
+// Parses the subset of YAML settings files are written in: key: value pairs,
+// the order as a list, the annotations as a mapping of names, and the title
+// and description as literal (|) or folded (>) blocks
+func parseSettings(raw []byte, s *dirSettings) (err error) {
+	var section, block string
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for line := 1; scanner.Scan(); line++ {
+		text := stripComment(scanner.Text())
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+		nested := text[0] == ' ' || text[0] == '\t'
+		text = strings.TrimSpace(text)
+		if item, ok := strings.CutPrefix(text, "- "); ok && section == "order" {
+			s.order = append(s.order, unquote(item))
+			continue
+		}
+		if nested {
+			switch section {
+			case "entries":
+				name, note, ok := cutKey(text)
+				if !ok || name == "" {
+					return fmt.Errorf("line %d: expected a name: annotation pair", line)
+				}
+				s.entries[name] = note
+			case "title", "description":
+				target := &s.title
+				if section == "description" {
+					target = &s.description
+				}
+				if *target != "" && block == "|" {
+					*target += "\n"
+				} else if *target != "" {
+					*target += " "
+				}
+				*target += text
+			default:
+				return fmt.Errorf("line %d: unexpected indentation", line)
+			}
+			continue
+		}
+		key, value, ok := cutKey(text)
+		if !ok {
+			return fmt.Errorf("line %d: expected a key: value pair", line)
+		}
+		section = ""
+		switch key {
+		case "noindex":
+			s.noIndex, err = parseBool(line, key, value)
+		case "nofollow":
+			s.noFollow, err = parseBool(line, key, value)
+		case "title", "description":
+			if value == "|" || value == ">" {
+				section, block = key, value
+				value = ""
+			}
+			if key == "title" {
+				s.title = value
+			} else {
+				s.description = value
+			}
+		case "expires":
+			if s.expires, err = parseExpiry(value); err != nil {
+				err = fmt.Errorf("line %d: expected a date for expires, got %q", line, value)
+			}
+		case "order":
+			section, s.order = key, nil
+			if value = strings.Trim(value, "[]"); value != "" {
+				for _, name := range strings.Split(value, ",") {
+					s.order = append(s.order, unquote(name))
+				}
+			}
+		case "entries":
+			section, s.entries = key, map[string]string{}
+		default:
+			log.Warn().Str("key", key).Int("line", line).Msg("Ignoring unknown directory setting")
+		}
+		if err != nil {
+			return
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,233 @@
+package statik
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sitemapFileName = "sitemap.xml"
+	feedFileName    = "feed.xml"
+	// Number of the most recently modified files listed in the feed, unless
+	// configured otherwise
+	defaultFeedEntries = 20
+)
+
+var feedEntries int
+
+// Lists the pages of all public directories in a sitemap.xml at the root
+type sitemapTarget struct {
+	mu   sync.Mutex
+	dirs map[string]sitemapURL
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func (*sitemapTarget) Name() string { return "sitemap" }
+
+func (t *sitemapTarget) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirs = map[string]sitemapURL{}
+	return nil
+}
+
+func (*sitemapTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{sitemapFileName}
+	}
+	return nil
+}
+
+func (t *sitemapTarget) Directory(dir *Directory) error {
+	if isRestricted(dir.Path) || isUnlisted(dir.Path) || strings.Contains(dir.Robots, "noindex") {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirs[dir.Path] = sitemapURL{Loc: pageURL(dir.URL), LastMod: dir.ModTime.Format(time.RFC3339)}
+	return nil
+}
+
+func (t *sitemapTarget) Forget(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for p := range t.dirs {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			delete(t.dirs, p)
+		}
+	}
+}
+
+func (t *sitemapTarget) Finish(root *Directory, _ []FuzzyFile) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var keys []string
+	for p := range t.dirs {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+	urlset := struct {
+		XMLName xml.Name     `xml:"urlset"`
+		XMLNS   string       `xml:"xmlns,attr"`
+		URLs    []sitemapURL `xml:"url"`
+	}{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, p := range keys {
+		urlset.URLs = append(urlset.URLs, t.dirs[p])
+	}
+	return xmlToFile(path.Join(root.DstPath, sitemapFileName), &urlset)
+}
+
+// Lists the most recently modified public files in an Atom feed.xml at the
+// root, for following new releases
+type feedTarget struct {
+	mu      sync.Mutex
+	entries []feedEntry
+}
+
+type feedEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    feedLink `xml:"link"`
+	Updated string   `xml:"updated"`
+
+	path    string
+	modTime time.Time
+}
+
+type feedLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+func (*feedTarget) Name() string { return "feed" }
+
+func (t *feedTarget) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = nil
+	return nil
+}
+
+func (*feedTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{feedFileName}
+	}
+	return nil
+}
+
+func (t *feedTarget) Directory(dir *Directory) error {
+	if isRestricted(dir.Path) || isUnlisted(dir.Path) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// The files of a directory generated again replace the previous ones
+	t.drop(func(p string) bool { return path.Dir(p) == dir.Path })
+	for _, f := range dir.Files {
+		u := f.URL.String()
+		t.entries = append(t.entries, feedEntry{
+			Title:   f.FuzzyFile.Name,
+			ID:      u,
+			Link:    feedLink{Href: u},
+			Updated: f.ModTime.Format(time.RFC3339),
+			path:    f.FuzzyFile.Path,
+			modTime: f.ModTime,
+		})
+	}
+	// Only the newest entries are ever kept around
+	sort.SliceStable(t.entries, func(i, j int) bool {
+		if !t.entries[i].modTime.Equal(t.entries[j].modTime) {
+			return t.entries[i].modTime.After(t.entries[j].modTime)
+		}
+		return t.entries[i].path < t.entries[j].path
+	})
+	if len(t.entries) > feedEntries {
+		t.entries = t.entries[:feedEntries]
+	}
+	return nil
+}
+
+func (t *feedTarget) drop(match func(p string) bool) {
+	kept := t.entries[:0]
+	for _, e := range t.entries {
+		if !match(e.path) {
+			kept = append(kept, e)
+		}
+	}
+	t.entries = kept
+}
+
+func (t *feedTarget) Forget(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drop(func(p string) bool { return strings.HasPrefix(p, dir+"/") })
+}
+
+func (t *feedTarget) Finish(root *Directory, _ []FuzzyFile) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	updated := root.GenTime
+	if len(t.entries) != 0 {
+		updated = t.entries[0].modTime
+	}
+	self := withBaseURL(feedFileName).String()
+	feed := struct {
+		XMLName xml.Name    `xml:"feed"`
+		XMLNS   string      `xml:"xmlns,attr"`
+		Title   string      `xml:"title"`
+		ID      string      `xml:"id"`
+		Links   []feedLink  `xml:"link"`
+		Updated string      `xml:"updated"`
+		Entries []feedEntry `xml:"entry"`
+	}{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		Title:   root.Name,
+		ID:      pageURL(root.URL),
+		Links:   []feedLink{{Href: pageURL(root.URL)}, {Rel: "self", Href: self}},
+		Updated: updated.Format(time.RFC3339),
+		Entries: t.entries,
+	}
+	return xmlToFile(path.Join(root.DstPath, feedFileName), &feed)
+}
+
+// The URL the feed is published at, when it is generated
+func feedURL() *url.URL {
+	for _, t := range enabledTargets {
+		if t.Name() == "feed" {
+			return withBaseURL(feedFileName)
+		}
+	}
+	return nil
+}
+
+// The URL a directory listing is served at, as the index of the directory
+func pageURL(dir *url.URL) string {
+	u := *dir
+	if u.Path = path.Join("/", u.Path); u.Path != "/" {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+func xmlToFile(p string, v any) (err error) {
+	var data []byte
+	if data, err = xml.MarshalIndent(v, "", "  "); err != nil {
+		return fmt.Errorf("could not serialize XML:\n%w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	if err = os.WriteFile(p, data, regularFile); err != nil {
+		return fmt.Errorf("could not write %s:\n%w", p, err)
+	}
+	return finalizeFile(p)
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package statik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Reports whether the file occupies fewer blocks on disk than its apparent
+// size would require, meaning it contains holes
+func isSparse(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Blocks*512 < info.Size()
+}
+
+// Copies the contents of src into dst. Sparse files are copied one data
+// segment at a time, leaving holes in place instead of writing out zeroes
+func copyContents(ctx context.Context, dst, src *os.File) (err error) {
+	var info os.FileInfo
+	if info, err = src.Stat(); err != nil {
+		return
+	}
+	if !isSparse(info) {
+		return copyChunks(ctx, dst, src, -1)
+	}
+
+	var data, hole int64
+	size := info.Size()
+	for hole < size {
+		if data, err = src.Seek(hole, unix.SEEK_DATA); err != nil {
+			// No data past the offset, the rest of the file is a hole
+			if errors.Is(err, syscall.ENXIO) {
+				break
+			}
+			return
+		}
+		if hole, err = src.Seek(data, unix.SEEK_HOLE); err != nil {
+			return
+		}
+		if _, err = src.Seek(data, io.SeekStart); err != nil {
+			return
+		}
+		if _, err = dst.Seek(data, io.SeekStart); err != nil {
+			return
+		}
+		if err = copyChunks(ctx, dst, src, hole-data); err != nil {
+			return
+		}
+	}
+	// Extend the file to its full size in case it ends with a hole
+	return dst.Truncate(size)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package statik
+
+import (
+	"context"
+	"os"
+)
+
+// Copies the contents of src into dst. Holes in sparse files are not detected
+// on this platform and get written out in full
+func copyContents(ctx context.Context, dst, src *os.File) error {
+	return copyChunks(ctx, dst, src, -1)
+}
@@ -0,0 +1,175 @@
+package statik
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Full builds are written into a sibling of the destination, which only
+// replaces it once complete, so that a build failing or killed midway leaves
+// the previous output in place
+const (
+	stagingSuffix  = ".statik-new"
+	replacedSuffix = ".statik-old"
+)
+
+var (
+	// The destination as configured, which holds the generations when they
+	// are kept
+	outputRoot string
+	// The destination while a build is staged, when dstDir points to the
+	// staging directory instead
+	outputDir string
+)
+
+func siblingDir(dst, suffix string) string {
+	return filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+suffix)
+}
+
+// The directory the output is published at, staged or not
+func finalOutputDir() string {
+	if keepGenerations > 0 {
+		return filepath.Join(outputRoot, currentLinkName)
+	}
+	return outputRoot
+}
+
+// Reports whether a path is the output, staged or not, its bookkeeping or
+// inside of them
+func isOutputPath(p string) bool {
+	staging := siblingDir(outputRoot, stagingSuffix)
+	for _, out := range []string{
+		outputRoot, staging, siblingDir(outputRoot, trashSuffix),
+		manifestPath(outputRoot), manifestPath(outputRoot) + ".tmp",
+		manifestPath(staging), manifestPath(staging) + ".tmp",
+	} {
+		if p == out || strings.HasPrefix(p, out+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Points the build at an empty staging directory. Destinations which cannot
+// be renamed over, such as mount points and symlinks, are cleared in place
+func stageOutput() (err error) {
+	if keepGenerations > 0 {
+		return stageGeneration()
+	}
+	if !replaceable(dstDir) {
+		log.Warn().Str("path", dstDir).Msg("The output directory cannot be replaced as a whole, clearing it in place")
+		return clearDestination()
+	}
+	staging := siblingDir(dstDir, stagingSuffix)
+	// Left behind by a build which has been killed
+	if err = os.RemoveAll(staging); err != nil {
+		return fmt.Errorf("could not clear staging directory %s:\n%w", staging, err)
+	}
+	outputDir, dstDir = dstDir, staging
+	return nil
+}
+
+// Whether a directory can be replaced by renaming a sibling over it
+func replaceable(dst string) bool {
+	info, err := os.Lstat(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	} else if err != nil || !info.IsDir() {
+		return false
+	}
+	parent, err := os.Stat(filepath.Dir(dst))
+	if err != nil {
+		return false
+	}
+	dev, _, ok := fileID(info)
+	parentDev, _, parentOk := fileID(parent)
+	return !ok || !parentOk || dev == parentDev
+}
+
+// Moves a staged output over the destination once the build has succeeded,
+// discarding it otherwise. The kept tree is pointed at the destination
+func commitOutput(built error) (err error) {
+	if outputDir == "" {
+		return built
+	}
+	staging := dstDir
+	journal := manifestPath(staging)
+	dstDir, outputDir = outputDir, ""
+	if built != nil {
+		os.RemoveAll(staging)
+		os.Remove(journal)
+		return built
+	}
+	if keepGenerations > 0 {
+		err = publishGeneration(staging)
+	} else {
+		err = replaceDir(staging, dstDir)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		os.Remove(journal)
+		return fmt.Errorf("could not move the output into %s:\n%w", dstDir, err)
+	}
+	// The manifest follows the output it describes
+	p := manifestPath(dstDir)
+	if err = os.Rename(journal, p); err != nil {
+		return fmt.Errorf("could not move build manifest %s to %s:\n%w", journal, p, err)
+	}
+	if builtTree != nil {
+		rebaseDirectory(builtTree, staging, dstDir)
+		for i := range builtFuzzy {
+			builtFuzzy[i].DstPath = rebase(builtFuzzy[i].DstPath, staging, dstDir)
+		}
+	}
+	return nil
+}
+
+// Replaces dst with src, in a single step where the platform allows it
+func replaceDir(src, dst string) (err error) {
+	if _, err = os.Lstat(dst); errors.Is(err, fs.ErrNotExist) {
+		return os.Rename(src, dst)
+	}
+	if exchangeDirs(src, dst) == nil {
+		return os.RemoveAll(src)
+	}
+	old := siblingDir(dst, replacedSuffix)
+	if err = os.RemoveAll(old); err != nil {
+		return
+	}
+	if err = os.Rename(dst, old); err != nil {
+		return
+	}
+	if err = os.Rename(src, dst); err != nil {
+		os.Rename(old, dst)
+		return
+	}
+	return os.RemoveAll(old)
+}
+
+func rebase(p, from, to string) string {
+	if rel, err := filepath.Rel(from, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join(to, rel)
+	}
+	return p
+}
+
+func rebaseDirectory(dir *Directory, from, to string) {
+	dir.DstPath = rebase(dir.DstPath, from, to)
+	for _, files := range [][]File{dir.Files, dir.Archived} {
+		for i := range files {
+			files[i].FuzzyFile.DstPath = rebase(files[i].FuzzyFile.DstPath, from, to)
+			if files[i].Torrent != nil {
+				files[i].Torrent.DstPath = rebase(files[i].Torrent.DstPath, from, to)
+			}
+		}
+	}
+	for i := range dir.Directories {
+		rebaseDirectory(&dir.Directories[i], from, to)
+	}
+}
@@ -0,0 +1,56 @@
+package statik_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+// A build replaces the output only once complete, leaving the previous one
+// untouched when it fails midway
+func TestStagedOutput(t *testing.T) {
+	fsys := statiktest.Fixture()
+	c := statik.DefaultConfig()
+	c.FS = fsys
+	c.Destination = filepath.Join(t.TempDir(), "out")
+	statiktest.Build(t, c)
+	readme := func() string {
+		data, err := os.ReadFile(filepath.Join(c.Destination, "README.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	old := readme()
+
+	fsys["README.txt"] = &fstest.MapFile{Data: []byte("Changed\n"), Mode: 0o644, ModTime: statiktest.FixtureEpoch.Add(time.Hour)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Events = func(e statik.Event) {
+		if e.Kind == statik.FileCopied {
+			cancel()
+		}
+	}
+	if _, err := statik.Generate(ctx, c); !statik.Interrupted(err) {
+		t.Fatalf("the build has not been interrupted: %v", err)
+	}
+	if got := readme(); got != old {
+		t.Errorf("the interrupted build changed the output: README.txt holds %q, want %q", got, old)
+	}
+	siblings, _ := filepath.Glob(filepath.Join(filepath.Dir(c.Destination), ".out.statik-new*"))
+	if len(siblings) != 0 {
+		t.Errorf("the interrupted build left %v behind", siblings)
+	}
+
+	c.Events = nil
+	statiktest.Build(t, c)
+	if got := readme(); got != "Changed\n" {
+		t.Errorf("README.txt holds %q once rebuilt, want the new contents", got)
+	}
+}
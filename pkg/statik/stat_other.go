@@ -0,0 +1,13 @@
+//go:build !unix
+
+package statik
+
+import "io/fs"
+
+// Device and inode numbers are not exposed by os.Stat on this platform
+func fileID(info fs.FileInfo) (dev, ino uint64, ok bool) { return 0, 0, false }
+
+// Ownership is not exposed as numeric ids on this platform
+func fileOwner(info fs.FileInfo) (uid, gid int, ok bool) { return -1, -1, false }
+
+func linkCount(info fs.FileInfo) uint64 { return 1 }
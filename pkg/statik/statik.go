@@ -0,0 +1,1432 @@
+package statik
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/rs/zerolog/log"
+	"github.com/tdewolff/minify/v2"
+)
+
+var (
+	//go:embed "page.gohtml"
+	defaultPageTemplate string
+	//go:embed "gallery.gohtml"
+	defaultGalleryTemplate string
+	//go:embed "preview.gohtml"
+	defaultPreviewTemplate string
+	//go:embed "search.gohtml"
+	defaultSearchTemplate string
+	//go:embed "problems.gohtml"
+	defaultProblemsTemplate string
+	//go:embed "style.css"
+	defaultStyle string
+	style        string
+	minifier     *minify.M
+
+	workDir string
+	srcDir  string
+	dstDir  string
+	// The filesystem the source is read from, rooted at srcDir unless a
+	// custom one has been given
+	srcFS fs.FS
+	// Whether srcFS is the operating system's view of srcDir, which copies
+	// of extended attributes and filesystem boundaries rely on
+	osSource bool
+
+	pageTemplatePath  string
+	styleTemplatePath string
+
+	isRecursive  bool
+	includeEmpty bool
+	enableSort   bool
+	convertLink  bool
+	resumeBuild  bool
+	lowMemory    bool
+	// Files are left where the source is served from, only listings are
+	// written
+	noCopy bool
+	// Pages are rendered without any script
+	noScripts bool
+
+	oneFileSystem bool
+	srcDevice     uint64
+	includeRegEx  *regexp.Regexp
+	excludeRegEx  *regexp.Regexp
+	baseURL       *url.URL
+
+	linkMIME *mimetype.MIME
+	manifest *Manifest
+	renderer *workerPool
+	buildCtx = context.Background()
+	// Errors which did not stop the current build
+	problems []error
+	// Directories which could not be read in the current build, by path
+	unreadable []string
+	// Source entries overwritten by or left out for generated outputs, which
+	// are reported without failing the build
+	collisions []string
+	// Number of files inspected and copied at once within a directory
+	jobs int
+)
+
+const (
+	linkSuffix  = ".link"
+	regularFile = os.FileMode(0666)
+	defaultSrc  = "./"
+	defaultDst  = "site"
+
+	fuzzyFileName    = "fuzzy.json"
+	metadataFileName = "statik.json"
+	// Bumped whenever fields are added to or changed in statik.json
+	metadataVersion = 3
+)
+
+type HTMLPayload struct {
+	Parts      []Directory
+	Root       Directory
+	Stylesheet template.CSS
+	Today      time.Time
+	Checksums  bool
+	// Whether any file of the directory has a license, shown in a column
+	Licenses bool
+	// Whether any entry of the directory has a description, shown in a column
+	Descriptions bool
+	// The rel attribute of anchors to external links
+	LinkRel string
+	// Where the feed of recent files is published, nil when not generated
+	Feed *url.URL
+	// Where the OpenSearch description is published, nil when not generated
+	Search *url.URL
+	// Where the time of the last build is published, nil when not generated,
+	// in which case no freshness banner is shown
+	Freshness *url.URL
+	// The id of the build rendering the page
+	Build string
+	// The density rows are shown with, unless the visitor picked another
+	Density string
+	// Whether the page may carry scripts. Everything they enhance is
+	// rendered without them too
+	Scripts bool
+	// Set when rendering the archive page of a directory, listing tombstones
+	Archive    bool
+	ArchiveURL *url.URL
+}
+
+type Directory struct {
+	Name        string        `json:"name"`
+	Path        string        `json:"path"`
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	SrcPath     string        `json:"-"`
+	DstPath     string        `json:"-"`
+	URL         *url.URL      `json:"url"`
+	Size        string        `json:"size"`
+	Bytes       int64         `json:"-"`
+	ModTime     time.Time     `json:"time"`
+	Mode        fs.FileMode   `json:"-"`
+	TotalBytes  int64         `json:"-"`
+	Notes       *ReleaseNotes `json:"release_notes,omitempty"`
+	Readme      *Readme       `json:"readme,omitempty"`
+	Latest      bool          `json:"latest,omitempty"`
+	Directories []Directory   `json:"directories,omitempty"`
+	Files       []File        `json:"files,omitempty"`
+	Archived    []File        `json:"archived,omitempty"`
+	Restricted  string        `json:"restricted,omitempty"`
+	Unreadable  bool          `json:"unreadable,omitempty"`
+	Broken      []BrokenEntry `json:"-"`
+	Robots      string        `json:"robots,omitempty"`
+	Bundle      *url.URL      `json:"-"`
+	View        string        `json:"view,omitempty"`
+	GenTime     time.Time     `json:"generated_at"`
+
+	// Set when the listings written by a previous run from the same contents
+	// are still in place, so that they need not be written again
+	unchanged bool
+	// The metadata version and the id of the build which wrote it, only set
+	// on the directory a statik.json is for
+	version int
+	build   string
+	// The target of the link the directory is written as, when preserving
+	// links, instead of being generated
+	symlink string
+	// Set when the time budget ran out before its whole subtree was walked,
+	// leaving its listing to a later run
+	partial bool
+	// Files delisted as expired, which are still copied unless dropped
+	expired []File
+}
+
+func (d Directory) isEmpty() bool { return len(d.Directories) == 0 && len(d.Files) == 0 }
+
+// The size of all the files listed in the directory and its subdirectories,
+// as an estimate of what downloading all of it amounts to
+func (d Directory) TotalSize() string { return humanize.Bytes(uint64(d.TotalBytes)) }
+
+func (d Directory) hasLicenses() bool {
+	for _, f := range d.Files {
+		if f.License != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Directory) hasDescriptions() bool {
+	for _, f := range d.Files {
+		if f.Description != "" {
+			return true
+		}
+	}
+	for _, sub := range d.Directories {
+		if sub.Description != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// The size of the files listed in the directory itself
+func (d Directory) FilesSize() string {
+	var total int64
+	for _, f := range d.Files {
+		total += f.Bytes
+	}
+	return humanize.Bytes(uint64(total))
+}
+
+// The files of the directory which have a thumbnail, shown as a gallery
+func (d Directory) Gallery() (files []File) {
+	for _, f := range d.Files {
+		if f.Thumbnail != nil {
+			files = append(files, f)
+		}
+	}
+	return
+}
+
+func (d *Directory) MarshalJSON() ([]byte, error) {
+	type DirectoryAlias Directory
+	var bundle string
+	if d.Bundle != nil {
+		bundle = d.Bundle.String()
+	}
+	return json.Marshal(&struct {
+		Version    int    `json:"version,omitempty"`
+		Build      string `json:"build,omitempty"`
+		SizeBytes  int64  `json:"size_bytes"`
+		TotalBytes int64  `json:"total_bytes"`
+		ModUnix    int64  `json:"time_unix"`
+		GenUnix    int64  `json:"generated_at_unix"`
+		URL        string `json:"url"`
+		ModTime    string `json:"time"`
+		GenTime    string `json:"generated_at"`
+		Bundle     string `json:"bundle,omitempty"`
+		*DirectoryAlias
+	}{
+		Version:        d.version,
+		Build:          d.build,
+		SizeBytes:      d.Bytes,
+		TotalBytes:     d.TotalBytes,
+		ModUnix:        d.ModTime.Unix(),
+		GenUnix:        d.GenTime.Unix(),
+		URL:            d.URL.String(),
+		ModTime:        d.ModTime.Format(time.RFC3339),
+		DirectoryAlias: (*DirectoryAlias)(d),
+		GenTime:        d.GenTime.Format(time.RFC3339),
+		Bundle:         bundle,
+	})
+}
+
+type FuzzyFile struct {
+	Name    string         `json:"name"`
+	Path    string         `json:"path"`
+	SrcPath string         `json:"-"`
+	DstPath string         `json:"-"`
+	URL     *url.URL       `json:"url"`
+	MIME    *mimetype.MIME `json:"mime"`
+	Mode    fs.FileMode    `json:"-"`
+
+	// The target of the link the file is written as, when preserving links
+	symlink string
+}
+
+func (f *FuzzyFile) MarshalJSON() ([]byte, error) {
+	type FuzzyFileAlias FuzzyFile
+	return json.Marshal(&struct {
+		URL  string `json:"url"`
+		MIME string `json:"mime"`
+		// The path normalized for searching, so clients need not fold it
+		Key string `json:"key"`
+		*FuzzyFileAlias
+	}{
+		URL:            f.URL.String(),
+		MIME:           f.MIME.String(),
+		Key:            searchKey(f.Path),
+		FuzzyFileAlias: (*FuzzyFileAlias)(f),
+	})
+}
+
+type File struct {
+	FuzzyFile
+	Size    string    `json:"size"`
+	Bytes   int64     `json:"-"`
+	ModTime time.Time `json:"time"`
+	// A .torrent file found next to this file, along with its magnet link
+	Torrent *FuzzyFile   `json:"-"`
+	Magnet  template.URL `json:"-"`
+	Latest  bool         `json:"-"`
+	// Set for links pointing outside of the listing's own host
+	External bool `json:"-"`
+	// The digest of the file contents, prefixed by the hashing algorithm
+	Checksum string `json:"-"`
+	// The SPDX identifier of the license of the file, when detected
+	License string `json:"-"`
+	// The annotation of the file in the settings of its directory
+	Description string `json:"-"`
+	// When the file is delisted, as set by its sidecar
+	Expires time.Time `json:"-"`
+	// Free form metadata gathered by the enrichers, e.g. EXIF tags
+	Meta map[string]string `json:"-"`
+	// A small preview of images, when thumbnails are generated
+	Thumbnail *url.URL `json:"-"`
+	// The page showing text files highlighted, when previews are generated
+	Preview *url.URL `json:"-"`
+
+	inode *inodeKey
+}
+
+func (f *File) MarshalJSON() ([]byte, error) {
+	// Unfortunately due to how go's embedding works, there is no other way
+	// then to explicitly state all fields and reassign them
+	var torrent, thumbnail, preview, expires string
+	if f.Torrent != nil {
+		torrent = f.Torrent.URL.String()
+	}
+	if f.Thumbnail != nil {
+		thumbnail = f.Thumbnail.String()
+	}
+	if f.Preview != nil {
+		preview = f.Preview.String()
+	}
+	if !f.Expires.IsZero() {
+		expires = f.Expires.Format(time.RFC3339)
+	}
+	return json.Marshal(&struct {
+		Name        string            `json:"name"`
+		Path        string            `json:"path"`
+		URL         string            `json:"url"`
+		MIME        string            `json:"mime"`
+		Size        string            `json:"size"`
+		Bytes       int64             `json:"size_bytes"`
+		ModTime     string            `json:"time"`
+		ModUnix     int64             `json:"time_unix"`
+		Torrent     string            `json:"torrent,omitempty"`
+		Magnet      string            `json:"magnet,omitempty"`
+		Latest      bool              `json:"latest,omitempty"`
+		External    bool              `json:"external,omitempty"`
+		Checksum    string            `json:"checksum,omitempty"`
+		License     string            `json:"license,omitempty"`
+		Description string            `json:"description,omitempty"`
+		Expires     string            `json:"expires,omitempty"`
+		Meta        map[string]string `json:"meta,omitempty"`
+		Thumbnail   string            `json:"thumbnail,omitempty"`
+		Preview     string            `json:"preview,omitempty"`
+	}{
+		Name:        f.FuzzyFile.Name,
+		Path:        f.FuzzyFile.Path,
+		URL:         f.URL.String(),
+		MIME:        f.MIME.String(),
+		Size:        f.Size,
+		Bytes:       f.Bytes,
+		ModTime:     f.ModTime.Format(time.RFC3339),
+		ModUnix:     f.ModTime.Unix(),
+		Torrent:     torrent,
+		Magnet:      string(f.Magnet),
+		Latest:      f.Latest,
+		External:    f.External,
+		Checksum:    f.Checksum,
+		License:     f.License,
+		Description: f.Description,
+		Expires:     expires,
+		Meta:        f.Meta,
+		Thumbnail:   thumbnail,
+		Preview:     preview,
+	})
+}
+
+// Joins the baseURL with the given relative path in a new URL instance
+func withBaseURL(rel string) (url *url.URL) {
+	url, _ = url.Parse(baseURL.String())
+	url.Path = path.Join(baseURL.Path, rel)
+	return
+}
+
+func getAbsPath(rel string) string {
+	if filepath.IsAbs(rel) {
+		return rel
+	}
+
+	return path.Join(workDir, rel)
+}
+
+func readIfNotEmpty(path string, dst *string) (err error) {
+	var content []byte
+	if path != "" {
+		content, err = os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read file: %s\n%w", path, err)
+		}
+		*dst = string(content)
+	}
+	return nil
+}
+
+func requireDir(path string) (err error) {
+	dir, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !dir.IsDir() {
+		return fmt.Errorf("%w: %s", ErrNotDirectory, path)
+	}
+	return nil
+}
+
+// The input path dir is relative to the root of srcFS
+func newFile(entry fs.DirEntry, dir string) (fz FuzzyFile, f File, err error) {
+	if entry.IsDir() {
+		return fz, f, errors.New("newFile has been called with a os.FileInfo of type Directory")
+	}
+
+	var (
+		name, size string
+		raw        []byte
+		url        *url.URL
+		mime       *mimetype.MIME
+		info       fs.FileInfo
+	)
+	rel := path.Join(dir, entry.Name())
+	abs := srcPathOf(rel)
+
+	url = withBaseURL(publishedPath(rel))
+	if info, err = fs.Stat(srcFS, rel); err != nil {
+		return
+	}
+
+	bytes := info.Size()
+	size = humanize.Bytes(uint64(bytes))
+	name = entry.Name()
+	// The actual type of regular files is left for the mime enricher to detect
+	mime = genericMIME
+	if suffix, parse := linkFormat(name); parse != nil {
+		var target string
+		if raw, err = fs.ReadFile(srcFS, rel); err != nil {
+			return fz, f, fmt.Errorf("could not read link file: %s\n%w", abs, err)
+		}
+		if target, err = parse(raw); err != nil {
+			return fz, f, fmt.Errorf("could not parse link file %s:\n%w", abs, err)
+		}
+		if target != "" {
+			if url, err = url.Parse(target); err != nil {
+				return fz, f, fmt.Errorf("could not parse URL in file %s\n: %s\n%w", abs, target, err)
+			}
+			bytes = 0
+			size = humanize.Bytes(0)
+			name = name[:len(name)-len(suffix)]
+			rel = rel[:len(rel)-len(suffix)]
+			mime = linkMIME
+		}
+	}
+
+	fz = FuzzyFile{
+		Name:    name,
+		Path:    rel,
+		SrcPath: abs,
+		DstPath: path.Join(dstDir, publishedPath(rel)),
+		URL:     url,
+		MIME:    mime,
+		Mode:    info.Mode(),
+	}
+	// Preserved links are written as links, never as hard links to a copy
+	inode := hardlinkKey(info)
+	if entry.Type()&fs.ModeSymlink != 0 && mime != linkMIME {
+		if fz.symlink, _ = symlinkTarget(rel); fz.symlink != "" {
+			inode = nil
+		}
+	}
+	return fz, File{
+		FuzzyFile: fz,
+		Size:      size,
+		Bytes:     bytes,
+		ModTime:   info.ModTime(),
+		External:  mime == linkMIME && isExternal(url),
+		inode:     inode,
+	}, nil
+}
+
+type Named interface {
+	GetName() string
+}
+
+func (d Directory) GetName() string { return d.Name }
+func (f File) GetName() string      { return f.FuzzyFile.Name }
+
+func sortByName[T Named](infos []T) {
+	sort.Slice(infos, func(i, j int) bool {
+		return lessName(infos[i].GetName(), infos[j].GetName())
+	})
+}
+
+func includeDir(info fs.DirEntry) bool {
+	if excludeRegEx.MatchString(info.Name()) {
+		return false
+	}
+	return !oneFileSystem || onSourceDevice(info)
+}
+
+// Reports whether the given entry lives on the same filesystem as the source
+// directory, so that the walk can avoid crossing into other mounts
+func onSourceDevice(entry fs.DirEntry) bool {
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	dev, _, ok := fileID(info)
+	if ok && dev != srcDevice {
+		log.Debug().Str("name", entry.Name()).Msg("Skipping directory on another filesystem")
+		return false
+	}
+	return true
+}
+
+func includeFile(info fs.DirEntry) bool {
+	return includeRegEx.MatchString(info.Name()) && !excludeRegEx.MatchString(info.Name()) &&
+		!hiddenByEnrichers(info.Name()) && info.Name() != viewFileName &&
+		info.Name() != dirSettingsFileName
+}
+
+// Builds and enriches the files among the entries of a directory, up to jobs
+// at once. The results are aligned with the entries, leaving the excluded ones
+// empty
+func inspectFiles(entries []fs.DirEntry, rel string) (fz []FuzzyFile, files []File, broken []BrokenEntry, err error) {
+	fz, files = make([]FuzzyFile, len(entries)), make([]File, len(entries))
+	found := make([]*BrokenEntry, len(entries))
+	pool := newWorkerPool(jobsIn(rel))
+	for i, entry := range entries {
+		if entry.IsDir() || !includeFile(entry) {
+			continue
+		}
+		i, entry := i, entry
+		pool.Go(func() (err error) {
+			p := path.Join(rel, entry.Name())
+			if fz[i], files[i], err = newFile(entry, rel); err != nil {
+				// Entries which cannot be inspected are left out
+				if kind := brokenKind(entry, err); kind != "" {
+					found[i] = newBrokenEntry(p, kind, err)
+					fz[i], files[i] = FuzzyFile{}, File{}
+					return nil
+				}
+				return fmt.Errorf("error while generating the File structure:\n%w", err)
+			}
+			var failed string
+			if failed, err = enrich(&files[i]); err != nil {
+				if Interrupted(err) {
+					return
+				}
+				// Unreadable files are left out, while the others are listed
+				// with the metadata gathered until then
+				if errors.Is(err, fs.ErrPermission) {
+					found[i] = newBrokenEntry(p, UnreadableEntry, err)
+					fz[i], files[i] = FuzzyFile{}, File{}
+					return nil
+				}
+				found[i] = newBrokenEntry(p, failed, err)
+			}
+			storeAsObject(&files[i])
+			fz[i] = files[i].FuzzyFile
+			return nil
+		})
+	}
+	if err = pool.Wait(); err != nil {
+		return
+	}
+	for _, b := range found {
+		if b != nil {
+			broken = append(broken, *b)
+		}
+	}
+	// Jobs are dropped once the build is cancelled, leaving gaps behind
+	return fz, files, broken, buildCtx.Err()
+}
+
+// A subdirectory as returned by walk, before it is merged into its parent
+type walkedDir struct {
+	dir Directory
+	fz  []FuzzyFile
+}
+
+// Walks the directory tree rooted at rel within srcFS, calling visit on each directory
+// once its whole subtree has been walked and visited. Directories handed to
+// visit are fully populated, while in low memory mode the ones returned to
+// the parent only retain their own metadata and no children listings.
+func walk(rel string, visit func(*Directory) error) (dir Directory, fz []FuzzyFile, err error) {
+	base := srcPathOf(rel)
+	// Avoid infinite recursion over the destination directory
+	if osSource && (isOutputPath(base) || followsIntoOutput(base)) {
+		return
+	}
+	if err = buildCtx.Err(); err != nil {
+		return
+	}
+
+	var (
+		infos   []fs.DirEntry
+		dirInfo fs.FileInfo
+		subdir  Directory
+		subfz   []FuzzyFile
+	)
+	if infos, err = fs.ReadDir(srcFS, rel); err != nil {
+		if errors.Is(err, fs.ErrPermission) && rel != "." {
+			return unreadableDir(rel, err)
+		}
+		return dir, fz, fmt.Errorf("could not read directory %s:\n%w", base, err)
+	}
+	infos = withoutObjects(rel, withoutOutputs(rel, withoutIgnored(rel, infos)))
+
+	if dirInfo, err = fs.Stat(srcFS, rel); err != nil {
+		return dir, fz, fmt.Errorf("could not stat directory %s:\n%w", base, err)
+	}
+	walkingDirs = append(walkingDirs, dirInfo)
+	defer func() { walkingDirs = walkingDirs[:len(walkingDirs)-1] }()
+	infos, loops := resolveSymlinks(rel, infos)
+
+	// Extract an interesting name from the baseURL
+	name := path.Base(base)
+	if rel == "." && len(baseURL.Path) > 1 {
+		parts := strings.Split(baseURL.Path, string(os.PathSeparator))
+		name = parts[len(parts)-1]
+	}
+
+	dir = Directory{
+		Name:    name,
+		SrcPath: base,
+		DstPath: path.Join(dstDir, publishedDir(rel)),
+		URL:     withBaseURL(publishedDir(rel)),
+		Path:    rel,
+		Size:    humanize.Bytes(uint64(dirInfo.Size())),
+		Bytes:   dirInfo.Size(),
+		ModTime: dirInfo.ModTime(),
+		Mode:    dirInfo.Mode(),
+		GenTime: time.Now(),
+	}
+	dir.Restricted = restrictionOf(rel)
+	dir.View = viewOf(rel, infos)
+	dir.Robots = robotsOf(rel).String()
+	describeDir(&dir)
+	if isUnlisted(rel) {
+		dir.Robots = robots{noIndex: true, noFollow: true}.String()
+	}
+
+	// Files are inspected up front, concurrently, and merged in walk order
+	fuzzies, files, broken, err := inspectFiles(infos, rel)
+	if err != nil {
+		return
+	}
+	dir.Broken = append(loops, broken...)
+	// Prioritized subtrees are walked, and so copied and rendered, first,
+	// while the listing keeps the order of the entries
+	walked := map[int]walkedDir{}
+	for _, i := range walkOrder(len(infos), func(i int) string { return path.Join(rel, infos[i].Name()) }) {
+		info := infos[i]
+		if !info.IsDir() || !isRecursive || !includeDir(info) || !creatableDir(&dir, path.Join(rel, info.Name())) {
+			continue
+		}
+		if budgetSpent() {
+			postpone(path.Join(rel, info.Name()))
+			dir.partial = true
+			continue
+		}
+		if link, ok := info.(linkedDirEntry); ok {
+			walked[i] = walkedDir{dir: linkedDir(path.Join(rel, info.Name()), link)}
+			continue
+		}
+		if subdir, subfz, err = walk(path.Join(rel, info.Name()), visit); err != nil {
+			return
+		}
+		if subdir.partial {
+			dir.partial = true
+			continue
+		}
+		if !subdir.unchanged && !subdir.isEmpty() {
+			budgetProgress = true
+		}
+		// Unreadable directories are never visited, their parent reports them
+		if subdir.Unreadable {
+			dir.Broken = append(dir.Broken, subdir.Broken...)
+		}
+		if !subdir.isEmpty() || includeEmpty || subdir.Unreadable {
+			// Include emptydir if isEmptyflag is setted
+			if lowMemory {
+				subdir.Directories = nil
+				subdir.Files = nil
+				subdir.Archived = nil
+				subdir.Readme = nil
+			}
+			walked[i] = walkedDir{subdir, subfz}
+		}
+	}
+	for i, info := range infos {
+		if sub, ok := walked[i]; ok {
+			fz = append(fz, sub.fz...)
+			// Unlisted directories are published without a link from their parent
+			if isUnlistedDir(sub.dir.Path) {
+				continue
+			}
+			dir.TotalBytes += sub.dir.TotalBytes
+			dir.Directories = append(dir.Directories, sub.dir)
+		} else if !info.IsDir() && includeFile(info) && files[i].FuzzyFile.Path != "" {
+			fuzzy, file := fuzzies[i], files[i]
+			fz = append(fz, fuzzy)
+			dir.TotalBytes += countedBytes(file)
+			dir.Files = append(dir.Files, file)
+			emit(Event{Kind: FileWalked, Path: fuzzy.Path, Bytes: file.Bytes})
+		}
+	}
+	if fz, err = assembleDir(&dir, fz); err != nil {
+		return
+	}
+
+	// Empty directories are going to be discarded by the parent, so there is
+	// no point in generating any output for them. Neither is there for the
+	// ones missing subtrees, which a later run completes
+	if dir.partial || dir.isEmpty() && !includeEmpty && rel != "." {
+		return
+	}
+	err = visit(&dir)
+	return
+}
+
+// Completes the listing of a directory out of its entries, for local and
+// remote sources alike. The files delisted along the way are dropped from fz
+func assembleDir(dir *Directory, fz []FuzzyFile) ([]FuzzyFile, error) {
+	var err error
+	if dir.Notes, err = findReleaseNotes(dir); err != nil {
+		return fz, err
+	}
+	if dir.Readme, err = findReadme(dir); err != nil {
+		return fz, err
+	}
+	// Torrents are shown alongside the file they describe, not on their own
+	if attached := associateTorrents(dir); attached != nil {
+		fz = dropFuzzy(fz, attached)
+	}
+	if archived := retireFiles(dir); archived != nil {
+		fz = dropFuzzy(fz, archived)
+	}
+	if expired := expireFiles(dir); expired != nil {
+		fz = dropFuzzy(fz, expired)
+	}
+	if enableSort {
+		sortByName(dir.Files)
+		sortByName(dir.Directories)
+	}
+	arrangeEntries(dir)
+	markLatest(dir)
+	return fz, nil
+}
+
+// Lists a directory whose contents cannot be read as such, so that the rest
+// of the build can go on without it. No listing is generated for it
+func unreadableDir(rel string, cause error) (dir Directory, fz []FuzzyFile, err error) {
+	log.Warn().Err(cause).Str("path", rel).Msg("Could not read directory")
+	warn(cause, rel)
+	unreadable = append(unreadable, rel)
+	dir = Directory{
+		Name:       path.Base(rel),
+		SrcPath:    srcPathOf(rel),
+		DstPath:    path.Join(dstDir, publishedDir(rel)),
+		URL:        withBaseURL(publishedDir(rel)),
+		Path:       rel,
+		GenTime:    time.Now(),
+		Unreadable: true,
+		Broken:     []BrokenEntry{*newBrokenEntry(rel, UnreadableEntry, cause)},
+	}
+	// The directory itself can usually be stat'ed even when it can't be listed
+	if info, err := fs.Stat(srcFS, rel); err == nil {
+		dir.Size = humanize.Bytes(uint64(info.Size()))
+		dir.Bytes = info.Size()
+		dir.ModTime = info.ModTime()
+		dir.Mode = info.Mode()
+	}
+	return
+}
+
+func copyFile(f FuzzyFile) (err error) {
+	// Windows only accepts long paths and reserved names verbatim
+	f.DstPath = verbatimPath(f.DstPath)
+	warnReserved(f.Path)
+	// Outputs are replaced rather than truncated, as truncating a hard link
+	// left behind by a previous run would write through into the source
+	if err = os.Remove(f.DstPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not replace %s:\n%w", f.DstPath, err)
+	}
+	if f.symlink != "" {
+		return writeSymlink(f.DstPath, f.symlink)
+	}
+	if linkSource(f) {
+		log.Printf("Linked %s to %s", f.DstPath, f.SrcPath)
+		return nil
+	}
+
+	// Open the input file
+	var inputStream fs.File
+	if osSource {
+		inputStream, err = os.Open(verbatimPath(f.SrcPath))
+	} else {
+		inputStream, err = srcFS.Open(f.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not open %s for reading:\n%w", f.SrcPath, err)
+	}
+	defer inputStream.Close()
+
+	// Create the output file
+	outputStream, err := os.Create(f.DstPath)
+	if err != nil {
+		return fmt.Errorf("could not open %s for writing:\n%w", f.DstPath, err)
+	}
+	defer outputStream.Close()
+
+	ctx, cancel := withTimeout(buildCtx, copyTimeout)
+	defer cancel()
+
+	// Stream the file contents, preserving holes in sparse files on disk
+	file, ok := inputStream.(*os.File)
+	switch {
+	case ok && reflinkSource(outputStream, file):
+	case ok:
+		err = copyContents(ctx, outputStream, file)
+	default:
+		err = copyChunks(ctx, outputStream, inputStream, -1)
+	}
+	if err != nil {
+		// Leave no truncated copy behind for a resumed build to trust
+		os.Remove(f.DstPath)
+		return fmt.Errorf("error while copying %s to %s:\n%w", f.SrcPath, f.DstPath, err)
+	}
+	if preserveXattrs && osSource {
+		if err = copyXattrs(f.SrcPath, f.DstPath); err != nil {
+			return err
+		}
+	}
+	if err = preserveAttrs(f.DstPath, f.Path, false); err != nil {
+		return err
+	}
+	if err = finalizeFile(f.DstPath); err != nil {
+		return err
+	}
+
+	log.Printf("Copied %s to %s", f.SrcPath, f.DstPath)
+	return nil
+}
+
+// Copies the files contained in the given directory, without recursing. Up
+// to jobs files are copied at once, while hard links are only recreated once
+// all copies are done, as they may point to any of them
+func writeCopies(dir *Directory) (err error) {
+	// Objects of remote sources, and sources served as they are, are linked
+	// to where they already are
+	if remoteSource != "" || noCopy {
+		return nil
+	}
+	// Files uploaded to a remote destination are read from the source, but
+	// for unlisted ones, which rclone could not rename to their tokens
+	if uploadingSources() && !isUnlisted(dir.Path) {
+		queueUploads(dir)
+		return nil
+	}
+	type link struct {
+		file  File
+		first string
+	}
+	var links []link
+	if objectsLayout {
+		objects := path.Join(dstDir, objectsDirName)
+		if err = os.MkdirAll(objects, os.ModeDir|os.ModePerm); err != nil {
+			return fmt.Errorf("could not create objects directory %s:\n%w", objects, err)
+		}
+	}
+	pool := newWorkerPool(jobsIn(dir.Path))
+	schedule := func(file File) {
+		if file.MIME == linkMIME {
+			return
+		}
+		// Files sharing an object wait for its first copy to be complete
+		if isObject(file.FuzzyFile) && !claimObject(file) {
+			links = append(links, link{file, ""})
+		} else if first := claimInode(file); first != "" {
+			links = append(links, link{file, first})
+		} else {
+			pool.Go(func() error { return writeCopy(file, "") })
+		}
+	}
+	for _, file := range append(dir.Files[:len(dir.Files):len(dir.Files)], dir.expired...) {
+		schedule(file)
+		if file.Torrent != nil {
+			schedule(File{FuzzyFile: *file.Torrent})
+		}
+	}
+	if err = pool.Wait(); err != nil {
+		return err
+	}
+	for _, l := range links {
+		if err = buildCtx.Err(); err != nil {
+			return err
+		}
+		if err = writeCopy(l.file, l.first); err != nil {
+			return err
+		}
+	}
+	for _, d := range dir.Directories {
+		if d.symlink != "" && !(appendOnly && exists(d.DstPath)) {
+			if err = writeSymlink(d.DstPath, d.symlink); err != nil {
+				return err
+			}
+		}
+	}
+	return buildCtx.Err()
+}
+
+// Writes a single file into the destination, either copying it or linking it
+// to the first copy of the same inode
+func writeCopy(file File, first string) (err error) {
+	f := file.FuzzyFile
+	if manifest.Done(file) {
+		log.Debug().Str("path", f.Path).Msg("Skipping file copied by a previous run")
+		return nil
+	}
+	if written, err := writtenOnce(file); written || err != nil {
+		return err
+	}
+	if storedObject(file) {
+		log.Debug().Str("path", f.Path).Msg("Skipping file stored as an existing object")
+	} else if first != "" {
+		err = linkCopy(f, first)
+	} else {
+		err = copyFile(f)
+	}
+	if err != nil {
+		return err
+	}
+	emit(Event{Kind: FileCopied, Path: f.Path, Bytes: file.Bytes})
+	return manifest.Record(file)
+}
+
+func jsonToFile[T any](path string, v T) (err error) {
+	var data []byte
+	if data, err = json.Marshal(&v); err != nil {
+		return fmt.Errorf("could not serialize JSON:\n%w", err)
+	}
+	if err = os.WriteFile(path, data, regularFile); err != nil {
+		return fmt.Errorf("could not write metadata file %s:\n%w", path, err)
+	}
+	return finalizeFile(path)
+}
+
+// Serializes a slice as a JSON array one element at a time, so that only a
+// single element is ever held in its marshaled form
+func jsonArrayToFile[T any](path string, vs []T) (err error) {
+	var (
+		file *os.File
+		data []byte
+	)
+	if file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile); err != nil {
+		return fmt.Errorf("could not create metadata file %s:\n%w", path, err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	w.WriteByte('[')
+	for i := range vs {
+		if i != 0 {
+			w.WriteByte(',')
+		}
+		if data, err = json.Marshal(&vs[i]); err != nil {
+			return fmt.Errorf("could not serialize JSON:\n%w", err)
+		}
+		w.Write(data)
+	}
+	w.WriteByte(']')
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("could not write metadata file %s:\n%w", path, err)
+	}
+	return finalizeFile(path)
+}
+
+// Create a shallow copy of a directory up to depth 2, meaning recursive
+// directory listings are cleared but the directories in the current directory
+// are maintained without stating their children files/directories
+func shallow(dir Directory) Directory {
+	cpy := dir
+	cpy.Directories = make([]Directory, len(dir.Directories))
+	copy(cpy.Directories, dir.Directories)
+	for i := 0; i < len(cpy.Directories); i++ {
+		cpy.Directories[i].Directories = nil
+		cpy.Directories[i].Files = nil
+	}
+	return cpy
+}
+
+// Writes the fuzzy.json file in the given (root) directory
+func writeFuzzy(dir *Directory, fz []FuzzyFile) (err error) {
+	if len(fz) == 0 {
+		return nil
+	}
+	fuzzyPath := path.Join(dir.DstPath, fuzzyFileName)
+	if relativeLinks {
+		relative := make([]FuzzyFile, len(fz))
+		for i, f := range fz {
+			f.URL = relativeURL(".", f.URL, false)
+			relative[i] = f
+		}
+		fz = relative
+	}
+	if lowMemory {
+		return jsonArrayToFile(fuzzyPath, fz)
+	}
+	return jsonToFile(fuzzyPath, fz)
+}
+
+// WriteJSON writes the statik.json metadata file of the given directory into
+// its destination
+func WriteJSON(dir *Directory) (err error) {
+	shallowCopy := shallow(*dir)
+	shallowCopy.version, shallowCopy.build = metadataVersion, buildID
+	p := path.Join(dir.DstPath, metadataFileName)
+	if relativeLinks {
+		from := pageDir(p)
+		shallowCopy.relativize(from)
+		shallowCopy.URL = relativeURL(from, shallowCopy.URL, true)
+	}
+	return jsonToFile(p, &shallowCopy)
+}
+
+// WriteHTML renders the index.html listing page of the given directory into
+// its destination
+func WriteHTML(dir *Directory) (err error) {
+	return writePage(path.Join(dir.DstPath, "index.html"), newPayload(dir))
+}
+
+func newPayload(dir *Directory) HTMLPayload {
+	var relUrl string
+	payload := HTMLPayload{
+		Root:         *dir,
+		Stylesheet:   template.CSS(style),
+		Today:        dir.GenTime,
+		Checksums:    checksumAlgorithm != "" && remoteSource == "",
+		Licenses:     dir.hasLicenses(),
+		Descriptions: dir.hasDescriptions(),
+		LinkRel:      linkRel,
+		Feed:         feedURL(),
+		Search:       searchURL(),
+		Freshness:    freshnessURL(),
+		Build:        buildID,
+		Density:      density,
+		Scripts:      !noScripts,
+	}
+
+	// Always append the last segment of the baseURL as a link back to the home
+	payload.Parts = append(payload.Parts, Directory{
+		Name: path.Base(baseURL.Path),
+		URL:  baseURL,
+	})
+	if dir.Path != "." {
+		parts := strings.Split(dir.Path, string(os.PathSeparator))
+		for _, part := range parts {
+			relUrl = path.Join(relUrl, part)
+			payload.Parts = append(payload.Parts, Directory{Name: part, URL: withBaseURL(publishedDir(relUrl))})
+		}
+
+		back := path.Join(dir.Path, "..")
+		payload.Root.Directories = append([]Directory{{
+			Name: "..",
+			Path: back,
+			URL:  withBaseURL(publishedDir(back)),
+		}}, payload.Root.Directories...)
+	}
+	if len(dir.Archived) != 0 {
+		payload.ArchiveURL = withBaseURL(publishedPath(path.Join(dir.Path, archiveFileName)))
+	}
+	return payload
+}
+
+// Renders the page template with the given payload into the index file
+func writePage(index string, payload HTMLPayload) (err error) {
+	payload.relativize(pageDir(index))
+	var outputHtml *os.File
+	if outputHtml, err = os.OpenFile(index, os.O_RDWR|os.O_CREATE|os.O_TRUNC, regularFile); err != nil {
+		return fmt.Errorf("could not create output file %s:\n%w", index, err)
+	}
+	defer outputHtml.Close()
+
+	// In low memory mode the template is rendered straight into the minifier,
+	// which streams its output to the file instead of buffering the page
+	if lowMemory {
+		if err = renderMinified(outputHtml, viewTemplate(payload), payload); err != nil {
+			return fmt.Errorf("could not write output file %s:\n%w", index, err)
+		}
+		log.Printf("Generated %s", index)
+		emit(Event{Kind: PageRendered, Path: outputPath(index)})
+		return finalizeFile(index)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := viewTemplate(payload).Execute(buf, payload); err != nil {
+		return fmt.Errorf("could not generate listing template:\n%w", err)
+	}
+
+	if err = minifier.Minify("text/html", outputHtml, buf); err != nil {
+		return fmt.Errorf("could not minify page output:\n%w", err)
+	}
+	log.Printf("Generated %s", index)
+	emit(Event{Kind: PageRendered, Path: outputPath(index)})
+	return finalizeFile(index)
+}
+
+// The path of an output relative to the destination
+func outputPath(p string) string {
+	if rel, err := filepath.Rel(dstDir, p); err == nil {
+		return rel
+	}
+	return p
+}
+
+// The path of a source entry relative to the source directory
+func sourcePath(p string) string {
+	for _, m := range mounts {
+		if rel, err := filepath.Rel(m.Dir, p); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path.Join(m.At, filepath.ToSlash(rel))
+		}
+	}
+	if rel, err := filepath.Rel(srcDir, p); err == nil {
+		return rel
+	}
+	return p
+}
+
+// Returns the names of the outputs generated next to the entries of a directory
+func generatedNames(dir *Directory) (names []string) {
+	names = append(targetOutputs(dir), previewNames(dir)...)
+	if bundled(dir) {
+		names = append(names, bundleName(dir))
+	}
+	if dir.Restricted != "" {
+		names = append(names, htaccessFileName)
+	}
+	return
+}
+
+// Records the source entries of a directory which are overwritten by the
+// outputs generated for it. The build carries on, reporting them at the end
+func checkCollisions(dir *Directory) {
+	for _, name := range generatedNames(dir) {
+		for _, f := range dir.Files {
+			if f.FuzzyFile.Name == name {
+				err := fmt.Errorf("%w: %s", ErrCollision, f.FuzzyFile.Path)
+				log.Warn().Err(err).Msg("A source file is going to be overwritten")
+				warn(err, f.FuzzyFile.Path)
+				collisions = append(collisions, f.FuzzyFile.Path)
+				dir.addBroken(f.FuzzyFile.Path, CollidingEntry, err)
+			}
+		}
+	}
+}
+
+// Produces all the outputs for a single directory, as soon as the walk has
+// completed its subtree
+func generate(dir *Directory) (err error) {
+	// Directories are created writable and only receive their final mode once
+	// all of their contents have been written
+	if err = os.MkdirAll(dir.DstPath, os.ModeDir|os.ModePerm); err != nil {
+		return fmt.Errorf("could not create output directory %s:\n%w", dir.DstPath, err)
+	}
+	checkCollisions(dir)
+	if err = writeCopies(dir); err != nil {
+		return fmt.Errorf("error while copying included files to the destination:\n%w", err)
+	}
+	if generateThumbnails {
+		if err = writeThumbnails(dir); err != nil {
+			return fmt.Errorf("error while generating thumbnails:\n%w", err)
+		}
+	}
+	if len(latestRules) != 0 {
+		if err = writeAliases(dir); err != nil {
+			return err
+		}
+	}
+	if generatePreviews {
+		linkPreviews(dir)
+	}
+	linkBundle(dir)
+	// Listings rendered from the same contents by a previous run are kept
+	digest := listingDigest(dir)
+	dir.unchanged = manifest.Listed(dir, digest)
+	if generatePreviews && !dir.unchanged {
+		if err = writePreviews(dir); err != nil {
+			return fmt.Errorf("error while generating previews:\n%w", err)
+		}
+	}
+	if latestStub {
+		if err = writeLatestStub(dir); err != nil {
+			return err
+		}
+	}
+	if dir.Restricted != "" {
+		if err = writeHtaccess(dir); err != nil {
+			return err
+		}
+	}
+
+	// Outputs are written in the background while the walk carries on. The job
+	// gets its own copy of the directory as the walker keeps on mutating it
+	cpy := *dir
+	renderer.Go(func() (err error) {
+		if err = writeTargets(&cpy); err != nil {
+			return err
+		}
+		if bundleFormat != "" {
+			if err = writeBundle(&cpy); err != nil {
+				return err
+			}
+		}
+		if err = finalizeDir(cpy.DstPath, cpy.Mode); err != nil {
+			return err
+		}
+		if err = preserveAttrs(cpy.DstPath, cpy.Path, true); err != nil {
+			return err
+		}
+		if cpy.unchanged {
+			return nil
+		}
+		return manifest.RecordListing(&cpy, digest)
+	})
+	return nil
+}
+
+// Walks the source directory and generates all outputs, filling in the report
+func build(report *Report) (err error) {
+	// Tokens are recorded even when the build fails, as a resumed build
+	// carries on writing under them
+	defer func() { err = joinErrors(err, writeUnlistedManifest()) }()
+	if remoteDestination != "" {
+		return buildAndUpload(report)
+	}
+	// When resuming or syncing, the existing output is kept and reconciled
+	// against the build manifest instead
+	if resumeBuild {
+		return buildOutput(report)
+	}
+	if err = stageOutput(); err != nil {
+		return
+	}
+	return commitOutput(buildOutput(report))
+}
+
+// Walks the source directory, or the listing of the remote source or of the
+// metadata the build is from, or the root index of the aggregated builds
+func walkSource(visit func(*Directory) error) (Directory, []FuzzyFile, error) {
+	if remoteSource == "" {
+		return walk(".", visit)
+	}
+	var (
+		entries []*remoteEntry
+		err     error
+	)
+	if len(aggregatedBuilds) != 0 {
+		return walkAggregate(visit)
+	} else if metadataSource != "" {
+		entries, err = readMetadata(metadataSource, ".", nil)
+	} else if IsHTTPSource(remoteSource) {
+		entries, err = scrapeRemote(remoteURL)
+	} else {
+		entries, err = listRemote(remoteSource)
+	}
+	if err != nil {
+		return Directory{}, nil, err
+	}
+	return walkRemote(entries, visit)
+}
+
+// Generates all outputs into dstDir
+func buildOutput(report *Report) (err error) {
+	var (
+		dir Directory
+		fz  []FuzzyFile
+	)
+	countedInodes = map[inodeKey]bool{}
+	linkedInodes = map[inodeKey]string{}
+	restricted = map[string]*Directory{}
+	problems = nil
+	unreadable, collisions = nil, nil
+	pendingDirs, budgetProgress = nil, false
+	expiredFiles, expiringFiles = nil, nil
+	if manifest, err = openManifest(dstDir, resumeBuild); err != nil {
+		return
+	}
+	defer manifest.Close()
+
+	if err = startTargets(); err != nil {
+		return
+	}
+
+	// Each directory is generated as soon as its subtree has been walked
+	renderJobs := runtime.NumCPU()
+	if lowMemory {
+		renderJobs = 1
+	}
+	renderer = newWorkerPool(renderJobs)
+	dir, fz, err = walkSource(generate)
+	// Pages already scheduled are still rendered, reporting all failures
+	if err = joinErrors(err, renderer.Wait()); err != nil {
+		return
+	}
+	// Pages scheduled after a cancellation are skipped without failing
+	if err = buildCtx.Err(); err != nil {
+		return
+	}
+	// Outputs spanning the whole tree are left in place until a run within
+	// the time budget has walked all of it
+	if len(pendingDirs) != 0 {
+		report.Files, report.Bytes = len(fz), dir.TotalBytes
+		report.Unreadable, report.Collisions, report.Pending = unreadable, collisions, pendingDirs
+		report.Expired, report.Expiring = expiredFiles, expiringReport()
+		return manifest.Compact()
+	}
+
+	if err = finishTargets(&dir, fz); err != nil {
+		return
+	}
+	if err = writeNginxAuth(); err != nil {
+		return
+	}
+	if err = writeServerConfigs(fz); err != nil {
+		return
+	}
+	registerTypes(fz)
+
+	// Outputs left behind by a previous run are only ever found when resuming
+	// or syncing, as the destination is wiped otherwise, and are kept forever
+	// in append-only destinations
+	if resumeBuild && !appendOnly {
+		if err = removeOrphans(); err != nil {
+			return
+		}
+		if err = removeUnusedObjects(); err != nil {
+			return
+		}
+		if err = purgeTrash(); err != nil {
+			return
+		}
+	}
+	if err = manifest.Compact(); err != nil {
+		return
+	}
+	// The root receives outputs until the very end of the build
+	if err = preserveAttrs(dstDir, ".", true); err != nil {
+		return
+	}
+
+	if keepTree {
+		builtTree, builtFuzzy = &dir, fz
+	}
+	report.Files = len(fz)
+	report.Bytes = dir.TotalBytes
+	report.Unreadable, report.Collisions = unreadable, collisions
+	report.Expired, report.Expiring = expiredFiles, expiringReport()
+	return nil
+}
+
+func sanitizeDirectories() (err error) {
+	if osSource {
+		if err = sanitizeSource(); err != nil {
+			return
+		}
+	}
+
+	// Check if outputDir is writable
+	dir, err := os.OpenFile(dstDir, os.O_WRONLY, os.ModeDir|os.ModePerm)
+	if err != nil && os.IsPermission(err) {
+		return fmt.Errorf("cannot open output directory for writing: %s\n%w", dstDir, err)
+	}
+	defer dir.Close()
+	return nil
+}
+
+func sanitizeSource() (err error) {
+	dirs := []string{srcDir}
+	if len(mounts) != 0 {
+		dirs = nil
+		for _, m := range mounts {
+			dirs = append(dirs, m.Dir)
+		}
+	}
+	for _, dir := range dirs {
+		if strings.HasPrefix(dir, dstDir) {
+			return ErrOverlap
+		}
+
+		if _, err = os.OpenFile(dir, os.O_RDONLY, os.ModeDir|os.ModePerm); err != nil && os.IsPermission(err) {
+			return fmt.Errorf("cannot open source directory for reading: %s\n%w", dir, err)
+		}
+
+		if err = requireDir(dir); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+func clearDestination() (err error) {
+	if err = os.RemoveAll(dstDir); err != nil {
+		return fmt.Errorf("cannot clear output directory: %s\n%w", dstDir, err)
+	}
+	return nil
+}
+
+// Parses the templates of the views and reads the stylesheet, either the
+// embedded ones or the user-provided files
+func loadAssets() (err error) {
+	var sources map[string]string
+	if sources, err = loadViews(); err != nil {
+		return
+	}
+	css := defaultStyle
+	if err = readIfNotEmpty(styleTemplatePath, &css); err != nil {
+		return fmt.Errorf("could not read stylesheet file:\n%w", err)
+	}
+	style = css
+	assetsDigest = digestAssets(sources, css)
+	if generatePreviews {
+		return loadPreviewStyle()
+	}
+	return nil
+}
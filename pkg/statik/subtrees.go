@@ -0,0 +1,121 @@
+package statik
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Globs is a flag.Value collecting the globs of a repeatable flag, such as
+// the subtrees of -prioritize
+type Globs []string
+
+// A rule capping the files inspected and copied at once within the subtrees
+// matching a glob, e.g. mnt/nfs/*=1
+type slowRule struct {
+	glob string
+	jobs int
+}
+
+// SlowRules is a flag.Value collecting -slow rules
+type SlowRules []slowRule
+
+var (
+	prioritizedDirs Globs
+	slowDirs        SlowRules
+)
+
+func (r *Globs) String() string { return strings.Join(*r, ",") }
+
+func (r *Globs) Set(s string) error {
+	if _, err := path.Match(s, ""); s == "" || err != nil {
+		return fmt.Errorf("invalid glob %q", s)
+	}
+	*r = append(*r, strings.Trim(s, "/"))
+	return nil
+}
+
+func (r *SlowRules) String() string {
+	var rules []string
+	for _, rule := range *r {
+		rules = append(rules, rule.glob+"="+strconv.Itoa(rule.jobs))
+	}
+	return strings.Join(rules, ",")
+}
+
+func (r *SlowRules) Set(s string) (err error) {
+	glob, raw, _ := strings.Cut(s, "=")
+	if glob == "" {
+		return fmt.Errorf("expected a rule in the glob[=jobs] form, got %q", s)
+	}
+	if _, err = path.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid glob %q:\n%w", glob, err)
+	}
+	rule := slowRule{strings.Trim(glob, "/"), 1}
+	if raw != "" {
+		if rule.jobs, err = strconv.Atoi(raw); err != nil || rule.jobs < 1 {
+			return fmt.Errorf("expected a positive number of jobs, got %q", raw)
+		}
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+// Reports whether a directory, given by its relative path, is prioritized,
+// lives inside a prioritized subtree or leads to one
+func isPrioritized(rel string) bool {
+	dir := strings.Split(rel, "/")
+	for _, glob := range prioritizedDirs {
+		segments := strings.Split(glob, "/")
+		matched := true
+		for i := 0; i < len(dir) && i < len(segments) && matched; i++ {
+			matched, _ = path.Match(segments[i], dir[i])
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the order in which n entries of a directory are walked: the
+// prioritized subdirectories first, then the others in their listing order,
+// or the stalest first when the build has a time budget
+func walkOrder(n int, rel func(i int) string) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	if !budgetDeadline.IsZero() && manifest != nil {
+		stalestFirst(order, rel)
+	}
+	if len(prioritizedDirs) == 0 {
+		return order
+	}
+	first := make([]bool, n)
+	for i := range first {
+		first[i] = isPrioritized(rel(i))
+	}
+	sort.SliceStable(order, func(i, j int) bool { return first[order[i]] && !first[order[j]] })
+	return order
+}
+
+// Returns the number of files of a directory to inspect and copy at once,
+// lowered by the -slow rule matching it or any of its parents
+func jobsIn(rel string) int {
+	for dir := rel; ; dir = path.Dir(dir) {
+		for _, rule := range slowDirs {
+			if ok, _ := path.Match(rule.glob, dir); ok {
+				if rule.jobs < jobs {
+					return rule.jobs
+				}
+				return jobs
+			}
+		}
+		if dir == "." || dir == "/" {
+			return jobs
+		}
+	}
+}
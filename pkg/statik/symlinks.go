@@ -0,0 +1,175 @@
+package statik
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rs/zerolog/log"
+)
+
+// How symbolic links in the source are handled. By default links to files
+// are copied as their targets, while links to directories are left out
+const (
+	// Walk into linked directories, skipping the links which loop back into
+	// one of their parents
+	FollowSymlinks = "follow"
+	// Recreate the links pointing within the source as links in the
+	// destination, copying the other ones as by default
+	PreserveSymlinks = "preserve"
+)
+
+var (
+	symlinkMode string
+	// The directories being walked, from the root down to the current one,
+	// to detect links looping back into them
+	walkingDirs []fs.FileInfo
+)
+
+// A link to a directory recreated as a link in the destination
+type linkedDirEntry struct {
+	fs.DirEntry
+	target string
+}
+
+// Reports whether two directories are the same, by device and inode where
+// available
+func sameDir(a, b fs.FileInfo) bool {
+	devA, inoA, okA := fileID(a)
+	devB, inoB, okB := fileID(b)
+	if okA && okB {
+		return devA == devB && inoA == inoB
+	}
+	return os.SameFile(a, b)
+}
+
+// Resolves the links to directories among the entries of a directory, as
+// told by the symlink mode. Links to files and dangling links are left to
+// newFile
+func resolveSymlinks(rel string, entries []fs.DirEntry) (resolved []fs.DirEntry, broken []BrokenEntry) {
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, entry)
+			continue
+		}
+		p := path.Join(rel, entry.Name())
+		info, err := fs.Stat(srcFS, p)
+		if err != nil || !info.IsDir() {
+			resolved = append(resolved, entry)
+			continue
+		}
+		switch symlinkMode {
+		case FollowSymlinks:
+			if looping(info) {
+				err = fmt.Errorf("%w: %s links back to one of its parents", ErrSymlinkLoop, p)
+				log.Warn().Err(err).Msg("Skipping symbolic link")
+				broken = append(broken, *newBrokenEntry(p, SymlinkLoop, err))
+				continue
+			}
+			resolved = append(resolved, fs.FileInfoToDirEntry(info))
+			continue
+		case PreserveSymlinks:
+			if target, ok := symlinkTarget(p); ok {
+				resolved = append(resolved, linkedDirEntry{fs.FileInfoToDirEntry(info), target})
+				continue
+			}
+		}
+		log.Debug().Str("path", p).Msg("Skipping symbolic link to a directory")
+	}
+	return
+}
+
+func looping(info fs.FileInfo) bool {
+	for _, dir := range walkingDirs {
+		if sameDir(dir, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the target of a link to recreate in the destination, relative to
+// the directory of the link, when the link is to be preserved and points
+// within the source
+func symlinkTarget(rel string) (string, bool) {
+	if symlinkMode != PreserveSymlinks || !osSource {
+		return "", false
+	}
+	abs := filepath.Join(srcDir, filepath.FromSlash(rel))
+	target, err := os.Readlink(abs)
+	if err != nil {
+		return "", false
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(abs), target)
+	}
+	if inside, err := filepath.Rel(srcDir, target); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		log.Debug().Str("path", rel).Msg("Copying symbolic link pointing outside of the source")
+		return "", false
+	}
+	if target, err = filepath.Rel(filepath.Dir(abs), target); err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(target), true
+}
+
+// Lists a preserved link to a directory, which is never walked as its
+// target is listed on its own
+func linkedDir(rel string, entry linkedDirEntry) Directory {
+	dir := Directory{
+		Name:    entry.Name(),
+		SrcPath: srcPathOf(rel),
+		DstPath: path.Join(dstDir, publishedPath(rel)),
+		URL:     withBaseURL(publishedPath(rel)),
+		Path:    rel,
+		GenTime: time.Now(),
+		symlink: entry.target,
+	}
+	if info, err := entry.Info(); err == nil {
+		dir.Size = humanize.Bytes(uint64(info.Size()))
+		dir.Bytes = info.Size()
+		dir.ModTime = info.ModTime()
+		dir.Mode = info.Mode()
+	}
+	return dir
+}
+
+// Writes a link into the destination, replacing whatever is in its place
+func writeSymlink(dst, target string) error {
+	if err := os.RemoveAll(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not replace %s:\n%w", dst, err)
+	}
+	if err := os.Symlink(filepath.FromSlash(target), dst); err != nil {
+		return fmt.Errorf("could not link %s to %s:\n%w", dst, target, err)
+	}
+	log.Printf("Linked %s to %s", dst, target)
+	return applyOwner(dst)
+}
+
+// Reports whether a directory reached through followed links is the output
+// or lies within it, which isOutputPath cannot tell from its path alone
+func followsIntoOutput(base string) bool {
+	if symlinkMode != FollowSymlinks {
+		return false
+	}
+	real, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return false
+	}
+	root := outputRoot
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(root)); err == nil {
+		root = filepath.Join(parent, filepath.Base(root))
+	}
+	for _, out := range []string{root, siblingDir(root, stagingSuffix)} {
+		if real == out || strings.HasPrefix(real, out+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
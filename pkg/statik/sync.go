@@ -0,0 +1,50 @@
+package statik
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// Update an existing output in place, only writing what has changed
+	syncOutput bool
+	// Digest of the listing assets and of the options shaping the listings,
+	// which all listings have to be rendered again when they change
+	assetsDigest string
+)
+
+// Digests the templates of the views, the stylesheet and the options which
+// affect the contents of every listing
+func digestAssets(sources map[string]string, css string) string {
+	var names []string
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%s\x00", name, sources[name])
+	}
+	fmt.Fprintf(h, "%s\x00%s %s %s", css, targetNames(), checksumAlgorithm, baseURL)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Digests everything the listings of a directory are rendered from, except
+// for the time they are generated at, so that unchanged listings can be told
+// apart without rendering them
+func listingDigest(dir *Directory) string {
+	cpy := shallow(*dir)
+	cpy.GenTime = time.Time{}
+	// Subdirectories are walked again on every run, which is no change
+	for i := range cpy.Directories {
+		cpy.Directories[i].GenTime = time.Time{}
+	}
+	h := sha256.New()
+	h.Write([]byte(assetsDigest))
+	json.NewEncoder(h).Encode(&cpy)
+	return hex.EncodeToString(h.Sum(nil))
+}
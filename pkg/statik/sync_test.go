@@ -0,0 +1,52 @@
+package statik_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+// Syncing copies the files which changed, removes the ones which are gone and
+// only rewrites the listings affected
+func TestSync(t *testing.T) {
+	fsys := statiktest.Fixture()
+	c := statik.DefaultConfig()
+	c.FS = fsys
+	c.Destination = filepath.Join(t.TempDir(), "out")
+	c.Sync = true
+	statiktest.Build(t, c)
+
+	var copied, rendered []string
+	c.Events = func(e statik.Event) {
+		switch e.Kind {
+		case statik.FileCopied:
+			copied = append(copied, e.Path)
+		case statik.PageRendered:
+			rendered = append(rendered, e.Path)
+		}
+	}
+	fsys["docs/guide.txt"] = &fstest.MapFile{Data: []byte("Read the new manual\n"), Mode: 0o644, ModTime: statiktest.FixtureEpoch.Add(time.Hour)}
+	delete(fsys, "data/sample.json")
+	out := statiktest.Build(t, c)
+
+	if len(copied) != 1 || copied[0] != "docs/guide.txt" {
+		t.Errorf("copied %v, want docs/guide.txt only", copied)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "docs", "guide.txt")); err != nil || string(data) != "Read the new manual\n" {
+		t.Errorf("docs/guide.txt holds %q, %v, want the new contents", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "data", "sample.json")); err == nil {
+		t.Error("data/sample.json is still in the output")
+	}
+	sort.Strings(rendered)
+	if want := []string{"docs/index.html", "index.html"}; strings.Join(rendered, ",") != strings.Join(want, ",") {
+		t.Errorf("rewrote %v, want %v", rendered, want)
+	}
+}
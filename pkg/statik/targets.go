@@ -0,0 +1,227 @@
+package statik
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Target is an output format produced from the walked source. Targets are
+// registered by name and enabled through Config.Targets, running in the
+// order they are listed in. Formats written in one go from the whole
+// listing are simpler to add as a Writer, through WriterTarget.
+type Target interface {
+	Name() string
+	// Names of the files the target writes into the destination of a
+	// directory, used to detect collisions with the source
+	Outputs(dir *Directory) []string
+	// Called before a build walks the source, to drop any previous state
+	Start() error
+	// Writes the outputs of a single directory once its subtree has been
+	// walked. Calls may come from several goroutines at once
+	Directory(dir *Directory) error
+	// Writes the outputs covering the whole listing, once it has been walked.
+	// The root only retains its own children in low memory mode
+	Finish(root *Directory, fz []FuzzyFile) error
+}
+
+// A Writer produces an output format from the whole listing at once, once it
+// has been walked. Files are written into the destination of the root
+type Writer interface {
+	Write(root *Directory, fz []FuzzyFile) error
+}
+
+// WriterTarget turns a Writer into a Target with the given name, to be
+// registered with RegisterTarget. Outputs are the names of the files it
+// writes at the root of the destination
+func WriterTarget(name string, w Writer, outputs ...string) Target {
+	return writerTarget{name, w, outputs}
+}
+
+type writerTarget struct {
+	name    string
+	writer  Writer
+	outputs []string
+}
+
+func (t writerTarget) Name() string                                 { return t.name }
+func (writerTarget) Start() error                                   { return nil }
+func (writerTarget) Directory(*Directory) error                     { return nil }
+func (t writerTarget) Finish(root *Directory, fz []FuzzyFile) error { return t.writer.Write(root, fz) }
+
+func (t writerTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return t.outputs
+	}
+	return nil
+}
+
+// Targets keeping track of directories are told about the ones which have
+// disappeared when watching the source
+type forgetter interface {
+	Forget(dir string)
+}
+
+var (
+	targetsMu sync.Mutex
+	targets   = map[string]Target{}
+	// The targets of the current build, in order
+	enabledTargets []Target
+)
+
+func init() {
+	for _, t := range []Target{htmlTarget{}, jsonTarget{}, checksumsTarget{}, &sitemapTarget{}, &feedTarget{}, searchTarget{}, &inventoryTarget{}, &ndjsonTarget{}, &sbomTarget{}, &problemsTarget{}, &treeTarget{}, freshnessTarget{}} {
+		RegisterTarget(t)
+	}
+}
+
+// RegisterTarget makes a target available to be enabled by its name,
+// replacing any target previously registered with the same name
+func RegisterTarget(t Target) {
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	targets[t.Name()] = t
+}
+
+// Targets returns the names of all registered targets, sorted
+func Targets() (names []string) {
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// ParseTargets parses a comma separated list of target names
+func ParseTargets(list string) (names []string, err error) {
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, err = lookupTargets([]string{name}); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return
+}
+
+func lookupTargets(names []string) (ts []Target, err error) {
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	for _, name := range names {
+		t, ok := targets[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown target %q", ErrInvalidConfig, name)
+		}
+		ts = append(ts, t)
+	}
+	return
+}
+
+// Writes the outputs of all targets for a single directory
+func writeTargets(dir *Directory) (err error) {
+	for _, t := range enabledTargets {
+		if err = t.Directory(dir); err != nil {
+			return fmt.Errorf("error while generating the %s output of %s:\n%w", t.Name(), dir.Path, err)
+		}
+	}
+	return nil
+}
+
+// Writes the outputs of all targets covering the whole listing
+func finishTargets(root *Directory, fz []FuzzyFile) (err error) {
+	for _, t := range enabledTargets {
+		if err = t.Finish(root, fz); err != nil {
+			return fmt.Errorf("error while generating the %s output:\n%w", t.Name(), err)
+		}
+	}
+	return nil
+}
+
+func startTargets() (err error) {
+	for _, t := range enabledTargets {
+		if err = t.Start(); err != nil {
+			return fmt.Errorf("could not start the %s output:\n%w", t.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Tells the targets about a directory gone from the source
+func forgetTargets(dir string) {
+	for _, t := range enabledTargets {
+		if f, ok := t.(forgetter); ok {
+			f.Forget(dir)
+		}
+	}
+}
+
+// The index.html listing pages, along with the archive pages
+type htmlTarget struct{}
+
+func (htmlTarget) Name() string { return "html" }
+func (htmlTarget) Start() error { return nil }
+
+func (htmlTarget) Outputs(dir *Directory) []string {
+	if len(dir.Archived) != 0 {
+		return []string{"index.html", archiveFileName}
+	}
+	return []string{"index.html"}
+}
+
+func (htmlTarget) Directory(dir *Directory) (err error) {
+	if dir.unchanged {
+		return nil
+	}
+	if err = WriteHTML(dir); err != nil {
+		return
+	}
+	return writeArchiveHTML(dir)
+}
+
+func (htmlTarget) Finish(*Directory, []FuzzyFile) error { return nil }
+
+// The statik.json metadata of each directory and the fuzzy.json search index
+type jsonTarget struct{}
+
+func (jsonTarget) Name() string { return "json" }
+func (jsonTarget) Start() error { return nil }
+
+func (jsonTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{metadataFileName, fuzzyFileName}
+	}
+	return []string{metadataFileName}
+}
+
+func (jsonTarget) Directory(dir *Directory) error {
+	if dir.unchanged {
+		return nil
+	}
+	return WriteJSON(dir)
+}
+
+func (jsonTarget) Finish(root *Directory, fz []FuzzyFile) error {
+	return writeFuzzy(root, withoutRestricted(fz))
+}
+
+// Joins the output names of all targets for a directory
+func targetOutputs(dir *Directory) (names []string) {
+	for _, t := range enabledTargets {
+		names = append(names, t.Outputs(dir)...)
+	}
+	return
+}
+
+// The names of the enabled targets, in order
+func targetNames() string {
+	var names []string
+	for _, t := range enabledTargets {
+		names = append(names, t.Name())
+	}
+	return strings.Join(names, ",")
+}
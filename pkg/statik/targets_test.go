@@ -0,0 +1,47 @@
+package statik_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+// Lists the paths of all files, one per line
+type pathsWriter struct{}
+
+func (pathsWriter) Write(root *statik.Directory, fz []statik.FuzzyFile) error {
+	var paths []string
+	for _, f := range fz {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	return os.WriteFile(filepath.Join(root.DstPath, "paths.txt"), []byte(strings.Join(paths, "\n")+"\n"), 0o644)
+}
+
+func TestWriterTarget(t *testing.T) {
+	statik.RegisterTarget(statik.WriterTarget("paths", pathsWriter{}, "paths.txt"))
+	if _, err := statik.ParseTargets("html,paths"); err != nil {
+		t.Fatal(err)
+	}
+	c := statik.DefaultConfig()
+	c.FS = statiktest.Fixture()
+	c.Targets = []string{"html", "paths"}
+	out := statiktest.Build(t, c)
+	data, err := os.ReadFile(filepath.Join(out, "paths.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"README.txt", "docs/guide.txt", "releases/app-1.10.0.tar.gz"} {
+		if !strings.Contains(string(data), want+"\n") {
+			t.Errorf("paths.txt lacks %s:\n%s", want, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "docs", "paths.txt")); err == nil {
+		t.Error("paths.txt has been written into a subdirectory")
+	}
+}
@@ -0,0 +1,70 @@
+package statik
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Amount of data read at once while throttled, so that the rate stays even
+const throttleChunkSize = 1 << 20
+
+var (
+	// Bytes per second read from the source for hashing and copying, shared
+	// by all jobs, zero meaning no limit
+	ioLimit int64
+	ioMu    sync.Mutex
+	// When the data already granted has been read at the limited rate
+	ioNext time.Time
+)
+
+// Waits until n more bytes can be read without exceeding the I/O limit.
+// Unused time is not saved up, so that idle periods cause no bursts
+func throttleIO(ctx context.Context, n int64) error {
+	if ioLimit <= 0 || n <= 0 {
+		return nil
+	}
+	ioMu.Lock()
+	now := time.Now()
+	if ioNext.Before(now) {
+		ioNext = now
+	}
+	wait := ioNext.Sub(now)
+	ioNext = ioNext.Add(time.Duration(n * int64(time.Second) / ioLimit))
+	ioMu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// A reader which reads no faster than the I/O limit
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func throttled(ctx context.Context, r io.Reader) io.Reader {
+	if ioLimit <= 0 {
+		return r
+	}
+	return throttledReader{ctx, r}
+}
+
+func (t throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunkSize {
+		p = p[:throttleChunkSize]
+	}
+	if err := throttleIO(t.ctx, int64(len(p))); err != nil {
+		return 0, err
+	}
+	return t.r.Read(p)
+}
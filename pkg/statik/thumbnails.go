@@ -0,0 +1,168 @@
+package statik
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Where statik keeps the assets it generates, and the thumbnails among
+	// them, relative to the root of the destination
+	assetsDirName     = ".statik"
+	thumbnailsDirName = assetsDirName + "/thumbs"
+	// The longest side of a thumbnail, in pixels
+	thumbnailSize = 256
+	// Larger images are not decoded, to keep the memory footprint in check
+	maxThumbnailPixels = 64 << 20
+)
+
+var (
+	generateThumbnails bool
+	// The MIME types of the images thumbnails can be generated for
+	thumbnailTypes = []string{"image/jpeg", "image/png", "image/gif"}
+)
+
+func thumbnailable(f *File) bool {
+	if !generateThumbnails || f.MIME == nil {
+		return false
+	}
+	for _, t := range thumbnailTypes {
+		if f.MIME.Is(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// The path of a file's thumbnail relative to the destination
+func thumbnailPath(rel string) string {
+	return path.Join(thumbnailsDirName, publishedPath(rel)+".webp")
+}
+
+// Generates the thumbnails of the images in a directory, pointing each file
+// to its own. Images which cannot be decoded are listed without one
+func writeThumbnails(dir *Directory) error {
+	pool := newWorkerPool(jobsIn(dir.Path))
+	for i := range dir.Files {
+		f := &dir.Files[i]
+		if !thumbnailable(f) {
+			continue
+		}
+		pool.Go(func() error {
+			rel := thumbnailPath(f.FuzzyFile.Path)
+			if err := writeThumbnail(f, path.Join(dstDir, rel)); err != nil {
+				log.Warn().Err(err).Str("path", f.FuzzyFile.Path).Msg("Could not generate thumbnail")
+				warn(err, f.FuzzyFile.Path)
+				dir.addBroken(f.FuzzyFile.Path, "thumbnail", err)
+				return nil
+			}
+			f.Thumbnail = withBaseURL(rel)
+			return nil
+		})
+	}
+	if err := pool.Wait(); err != nil {
+		return err
+	}
+	return buildCtx.Err()
+}
+
+func writeThumbnail(f *File, dst string) (err error) {
+	// A thumbnail written after the image was last modified is up to date
+	if info, err := os.Stat(dst); err == nil && !info.ModTime().Before(f.ModTime) {
+		return nil
+	}
+	var (
+		raw []byte
+		cfg image.Config
+		img image.Image
+	)
+	if raw, err = fs.ReadFile(srcFS, f.FuzzyFile.Path); err != nil {
+		return fmt.Errorf("could not read image %s:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	if cfg, _, err = image.DecodeConfig(bytes.NewReader(raw)); err != nil {
+		return fmt.Errorf("could not decode image %s:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return fmt.Errorf("image %s is too large to generate a thumbnail for", f.FuzzyFile.SrcPath)
+	}
+	if img, _, err = image.Decode(bytes.NewReader(raw)); err != nil {
+		return fmt.Errorf("could not decode image %s:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+
+	var buf bytes.Buffer
+	if err = encodeWebP(&buf, shrink(img, thumbnailSize)); err != nil {
+		return fmt.Errorf("could not encode thumbnail of %s:\n%w", f.FuzzyFile.SrcPath, err)
+	}
+	if err = os.MkdirAll(path.Dir(dst), os.ModeDir|os.ModePerm); err != nil {
+		return fmt.Errorf("could not create thumbnail directory %s:\n%w", path.Dir(dst), err)
+	}
+	if err = os.WriteFile(dst, buf.Bytes(), regularFile); err != nil {
+		return fmt.Errorf("could not write thumbnail %s:\n%w", dst, err)
+	}
+	return finalizeFile(dst)
+}
+
+// Scales an image down to fit a size by size square, averaging the pixels
+// each thumbnail pixel covers. Smaller images are kept as they are
+func shrink(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, sh*size/sw
+		} else {
+			dw, dh = sw*size/sh, size
+		}
+		if dw < 1 {
+			dw = 1
+		}
+		if dh < 1 {
+			dh = 1
+		}
+	}
+	// Colors are averaged premultiplied, so that transparent pixels do not
+	// bleed into their neighbours
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[src.PixOffset(x0, sy):src.PixOffset(x1, sy)]
+				for i := 0; i < len(row); i += 4 {
+					r, g, bl, a = r+uint64(row[i]), g+uint64(row[i+1]), bl+uint64(row[i+2]), a+uint64(row[i+3])
+				}
+				n += uint64(x1 - x0)
+			}
+			p := dst.Pix[dst.PixOffset(x, y):]
+			if a == 0 {
+				continue
+			}
+			p[0], p[1], p[2], p[3] = byte(r*255/a), byte(g*255/a), byte(bl*255/a), byte(a/n)
+		}
+	}
+	return dst
+}
+
+// Removes the thumbnails of a file or directory gone from the source
+func removeThumbnails(rel string) {
+	for _, p := range []string{thumbnailPath(rel), path.Join(thumbnailsDirName, publishedDir(rel))} {
+		if err := os.RemoveAll(path.Join(dstDir, p)); err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Could not remove thumbnail")
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package statik
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const torrentSuffix = ".torrent"
+
+var errBencode = errors.New("malformed bencoded data")
+
+// Returns the offset right past the bencoded value starting at i
+func skipBencode(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, errBencode
+	}
+	switch c := data[i]; {
+	case c == 'i':
+		end := strings.IndexByte(string(data[i:]), 'e')
+		if end < 0 {
+			return 0, errBencode
+		}
+		return i + end + 1, nil
+	case c == 'l' || c == 'd':
+		i++
+		for i < len(data) && data[i] != 'e' {
+			var err error
+			if i, err = skipBencode(data, i); err != nil {
+				return 0, err
+			}
+		}
+		if i >= len(data) {
+			return 0, errBencode
+		}
+		return i + 1, nil
+	case c >= '0' && c <= '9':
+		colon := strings.IndexByte(string(data[i:]), ':')
+		if colon < 0 {
+			return 0, errBencode
+		}
+		n, err := strconv.Atoi(string(data[i : i+colon]))
+		if err != nil || i+colon+1+n > len(data) {
+			return 0, errBencode
+		}
+		return i + colon + 1 + n, nil
+	}
+	return 0, errBencode
+}
+
+// Computes the info hash of a torrent file, the SHA-1 of its bencoded info
+// dictionary, to be used in magnet links
+func infoHash(data []byte) (string, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return "", errBencode
+	}
+	for i := 1; i < len(data) && data[i] != 'e'; {
+		keyEnd, err := skipBencode(data, i)
+		if err != nil {
+			return "", err
+		}
+		key := string(data[i:keyEnd])
+		valueEnd, err := skipBencode(data, keyEnd)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasSuffix(key, ":info") {
+			sum := sha1.Sum(data[keyEnd:valueEnd])
+			return hex.EncodeToString(sum[:]), nil
+		}
+		i = valueEnd
+	}
+	return "", errors.New("torrent has no info dictionary")
+}
+
+func magnetLink(torrent FuzzyFile, name string) (string, error) {
+	data, err := readSourceFile(torrent)
+	if err != nil {
+		return "", fmt.Errorf("could not read torrent file %s:\n%w", torrent.SrcPath, err)
+	}
+	hash, err := infoHash(data)
+	if err != nil {
+		return "", fmt.Errorf("could not parse torrent file %s:\n%w", torrent.SrcPath, err)
+	}
+	return "magnet:?xt=urn:btih:" + hash + "&dn=" + url.QueryEscape(name), nil
+}
+
+// Attaches each .torrent file to the file it shares the name with, removing it
+// from the listed files. Returns the paths of the attached torrents
+func associateTorrents(dir *Directory) (attached map[string]bool) {
+	byName := map[string]int{}
+	for i, f := range dir.Files {
+		byName[f.FuzzyFile.Name] = i
+	}
+	for _, f := range dir.Files {
+		if !strings.HasSuffix(f.FuzzyFile.Name, torrentSuffix) {
+			continue
+		}
+		target, ok := byName[strings.TrimSuffix(f.FuzzyFile.Name, torrentSuffix)]
+		if !ok {
+			continue
+		}
+		torrent := f.FuzzyFile
+		owner := &dir.Files[target]
+		owner.Torrent = &torrent
+		if magnet, err := magnetLink(torrent, owner.FuzzyFile.Name); err != nil {
+			log.Warn().Err(err).Msg("Could not generate magnet link")
+			warn(err, torrent.Path)
+		} else {
+			// Marked as safe, as the template would otherwise filter the scheme
+			owner.Magnet = template.URL(magnet)
+		}
+		if attached == nil {
+			attached = map[string]bool{}
+		}
+		attached[torrent.Path] = true
+	}
+	if attached == nil {
+		return
+	}
+
+	files := make([]File, 0, len(dir.Files)-len(attached))
+	for _, f := range dir.Files {
+		if !attached[f.FuzzyFile.Path] {
+			files = append(files, f)
+		}
+	}
+	dir.Files = files
+	return
+}
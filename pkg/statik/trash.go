@@ -0,0 +1,91 @@
+package statik
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	trashSuffix        = ".statik-trash"
+	trashTimeFormat    = "20060102T150405"
+	defaultTrashWindow = 7 * 24 * time.Hour
+)
+
+var (
+	// When enabled, orphaned outputs are moved into a timestamped folder in
+	// the trash directory next to the destination and only deleted once the
+	// retention window expires
+	useTrash       bool
+	trashRetention time.Duration
+	trashDir       string
+)
+
+// Removes the outputs of files and directories which have disappeared from
+// the source since the build recorded in the manifest. Directories are
+// removed along with all of their contents, which come after them
+func removeOrphans() (err error) {
+	stamp := time.Now().Format(trashTimeFormat)
+	for _, entry := range manifest.Orphans() {
+		dst := entry.output()
+		if entry.Listing != "" {
+			// The directory may have been replaced by a file with the same name
+			if info, err := os.Lstat(dst); err != nil || !info.IsDir() {
+				manifest.Forget(entry.Path)
+				continue
+			}
+		}
+		if useTrash {
+			err = moveToTrash(dst, path.Join(trashDir, stamp, entry.Path))
+		} else if entry.Listing != "" {
+			err = os.RemoveAll(dst)
+		} else {
+			err = os.Remove(dst)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove orphaned output %s:\n%w", dst, err)
+		}
+		log.Printf("Removed orphaned output %s", dst)
+		if generateThumbnails {
+			removeThumbnails(entry.Path)
+		}
+		if generatePreviews && entry.Listing == "" {
+			removePreview(entry.Path)
+		}
+		manifest.Forget(entry.Path)
+	}
+	return nil
+}
+
+func moveToTrash(src, dst string) (err error) {
+	if err = os.MkdirAll(path.Dir(dst), os.ModeDir|os.ModePerm); err != nil {
+		return
+	}
+	return os.Rename(src, dst)
+}
+
+// Deletes the trash folders which are older than the retention window
+func purgeTrash() (err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(trashDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("could not read trash directory %s:\n%w", trashDir, err)
+	}
+	for _, entry := range entries {
+		stamp, err := time.ParseInLocation(trashTimeFormat, entry.Name(), time.Local)
+		if err != nil || time.Since(stamp) < trashRetention {
+			continue
+		}
+		p := path.Join(trashDir, entry.Name())
+		if err = os.RemoveAll(p); err != nil {
+			return fmt.Errorf("could not purge trash folder %s:\n%w", p, err)
+		}
+		log.Printf("Purged trash folder %s", p)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package statik_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/lucat1/statik/pkg/statiktest"
+)
+
+func TestTrashRetention(t *testing.T) {
+	fsys := statiktest.Fixture()
+	c := statik.DefaultConfig()
+	c.FS = fsys
+	c.Destination = filepath.Join(t.TempDir(), "out")
+	statiktest.Build(t, c)
+
+	trash := filepath.Join(filepath.Dir(c.Destination), ".out.statik-trash")
+	expired := filepath.Join(trash, "20000101T000000")
+	if err := os.MkdirAll(expired, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	delete(fsys, "docs/guide.txt")
+	c.Resume, c.Trash, c.TrashRetention = true, true, time.Hour
+	statiktest.Build(t, c)
+
+	if _, err := os.Stat(filepath.Join(c.Destination, "docs", "guide.txt")); err == nil {
+		t.Error("the orphaned output is still in the destination")
+	}
+	trashed, _ := filepath.Glob(filepath.Join(trash, "*", "docs", "guide.txt"))
+	if len(trashed) != 1 {
+		t.Errorf("the orphaned output has not been moved to the trash, found %v", trashed)
+	}
+	if _, err := os.Stat(expired); err == nil {
+		t.Error("the trash folder beyond the retention window has not been purged")
+	}
+}
@@ -0,0 +1,125 @@
+package statik
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+const treeFileName = "tree.txt"
+
+// Draws every public directory and file, with their sizes and URLs, as a
+// plain-text tree.txt at the root for pasting into emails and issues. Only
+// ASCII is used, as tree --charset=ascii does, so that mail clients keep it
+// intact
+type treeTarget struct {
+	fileCollector
+}
+
+func (*treeTarget) Name() string { return "tree" }
+
+func (*treeTarget) Outputs(dir *Directory) []string {
+	if dir.Path == "." {
+		return []string{treeFileName}
+	}
+	return nil
+}
+
+// An entry of the tree, either a directory or a file
+type treeNode struct {
+	name     string
+	file     *File
+	children map[string]*treeNode
+}
+
+func (n *treeNode) child(name string) *treeNode {
+	c, ok := n.children[name]
+	if !ok {
+		c = &treeNode{name: name, children: map[string]*treeNode{}}
+		n.children[name] = c
+	}
+	return c
+}
+
+// Directories come first, then files, each sorted by name as in the listings
+func (n *treeNode) sorted() (nodes []*treeNode) {
+	for _, c := range n.children {
+		nodes = append(nodes, c)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if (nodes[i].file == nil) != (nodes[j].file == nil) {
+			return nodes[i].file == nil
+		}
+		return lessName(nodes[i].name, nodes[j].name)
+	})
+	return
+}
+
+func (t *treeTarget) Finish(root *Directory, _ []FuzzyFile) (err error) {
+	tree := &treeNode{children: map[string]*treeNode{}}
+	lookup := func(dir string) *treeNode {
+		n := tree
+		if dir == "." {
+			return n
+		}
+		for _, name := range strings.Split(dir, "/") {
+			n = n.child(name)
+		}
+		return n
+	}
+	t.mu.Lock()
+	for dir := range t.dirs {
+		lookup(dir)
+	}
+	t.mu.Unlock()
+	var (
+		dirs, files int
+		size        int64
+	)
+	for _, f := range t.files() {
+		f := f
+		lookup(path.Dir(f.FuzzyFile.Path)).child(f.FuzzyFile.Name).file = &f
+		files++
+		size += f.Bytes
+	}
+
+	p := path.Join(root.DstPath, treeFileName)
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, regularFile)
+	if err != nil {
+		return fmt.Errorf("could not create tree %s:\n%w", p, err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	fmt.Fprintln(w, baseURL)
+	var draw func(n *treeNode, indent string)
+	draw = func(n *treeNode, indent string) {
+		nodes := n.sorted()
+		for i, c := range nodes {
+			branch, next := "|-- ", "|   "
+			if i == len(nodes)-1 {
+				branch, next = "`-- ", "    "
+			}
+			if c.file == nil {
+				dirs++
+				fmt.Fprintf(w, "%s%s%s/\n", indent, branch, c.name)
+				draw(c, indent+next)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s%s (%s) %s\n", indent, branch, c.name, c.file.Size, c.file.URL)
+		}
+	}
+	draw(tree, "")
+	fmt.Fprintf(w, "\n%d directories, %d files, %s\n", dirs, files, humanize.Bytes(uint64(size)))
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("could not write tree %s:\n%w", p, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("could not write tree %s:\n%w", p, err)
+	}
+	return finalizeFile(p)
+}
@@ -0,0 +1,180 @@
+package statik
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// The suffix of the private manifest kept next to the destination by default
+const unlistedManifestSuffix = ".unlisted.json"
+
+var (
+	unlistedDirs Globs
+	// Where the tokens of unlisted directories are kept, outside of the output
+	unlistedManifest string
+	// The token each unlisted directory is published under, by relative path.
+	// Tokens are kept across builds so that shared links keep working
+	unlistedMu     sync.Mutex
+	unlistedTokens map[string]string
+	unlistedDirty  bool
+)
+
+// Reports whether a directory, given by its relative path, matches one of the
+// -unlisted globs
+func isUnlistedDir(rel string) bool {
+	for _, glob := range unlistedDirs {
+		if ok, _ := path.Match(glob, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether a directory, given by its relative path, is unlisted or
+// lives inside an unlisted directory
+func isUnlisted(dir string) bool {
+	if len(unlistedDirs) == 0 {
+		return false
+	}
+	for ; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if isUnlistedDir(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// The path a directory is published under, relative to the output, with the
+// name of each unlisted directory along the way replaced by its token
+func publishedDir(rel string) string {
+	if len(unlistedDirs) == 0 || rel == "." {
+		return rel
+	}
+	segments := strings.Split(rel, "/")
+	published := make([]string, len(segments))
+	for i := range segments {
+		if dir := strings.Join(segments[:i+1], "/"); isUnlistedDir(dir) {
+			published[i] = unlistedToken(dir)
+		} else {
+			published[i] = segments[i]
+		}
+	}
+	return strings.Join(published, "/")
+}
+
+// The path a file is published under, relative to the output
+func publishedPath(rel string) string {
+	if len(unlistedDirs) == 0 {
+		return rel
+	}
+	return path.Join(publishedDir(path.Dir(rel)), path.Base(rel))
+}
+
+// The token an unlisted directory is published under, generating a new one
+// the first time the directory is seen
+func unlistedToken(dir string) string {
+	unlistedMu.Lock()
+	defer unlistedMu.Unlock()
+	if token, ok := unlistedTokens[dir]; ok {
+		return token
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Errorf("could not generate a token for %s:\n%w", dir, err))
+	}
+	token := hex.EncodeToString(buf)
+	unlistedTokens[dir] = token
+	unlistedDirty = true
+	return token
+}
+
+// Loads the tokens handed out by previous builds
+func readUnlistedManifest() error {
+	unlistedTokens, unlistedDirty = map[string]string{}, false
+	if len(unlistedDirs) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(unlistedManifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not read unlisted manifest %s:\n%w", unlistedManifest, err)
+	}
+	if err = json.Unmarshal(data, &unlistedTokens); err != nil {
+		return fmt.Errorf("could not parse unlisted manifest %s:\n%w", unlistedManifest, err)
+	}
+	return nil
+}
+
+// Records the tokens handed out in this build, readable by the owner only as
+// anyone holding it can reach every unlisted directory
+func writeUnlistedManifest() error {
+	unlistedMu.Lock()
+	defer unlistedMu.Unlock()
+	if !unlistedDirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(unlistedTokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode unlisted manifest:\n%w", err)
+	}
+	tmp := unlistedManifest + ".tmp"
+	if err = os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("could not write unlisted manifest %s:\n%w", tmp, err)
+	}
+	if err = os.Rename(tmp, unlistedManifest); err != nil {
+		return fmt.Errorf("could not replace unlisted manifest %s:\n%w", unlistedManifest, err)
+	}
+	unlistedDirty = false
+	return nil
+}
+
+// Where the output recorded by a manifest entry lives
+func (e ManifestEntry) output() string {
+	if e.Listing != "" {
+		return path.Join(dstDir, publishedDir(e.Path))
+	}
+	return path.Join(dstDir, publishedPath(e.Path))
+}
+
+// The entry as journaled, under the published path of its output so that the
+// manifest, which lives in the output, does not give unlisted names away
+func (e ManifestEntry) published() ManifestEntry {
+	if e.Listing != "" {
+		e.Path = publishedDir(e.Path)
+	} else {
+		e.Path = publishedPath(e.Path)
+	}
+	return e
+}
+
+// Maps a path journaled in the manifest back to its source, replacing the
+// deepest token along the way with the directory it was handed out for
+func unpublishedPath(p string, dirs map[string]string) string {
+	segments := strings.Split(p, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if dir, ok := dirs[segments[i]]; ok {
+			return path.Join(append([]string{dir}, segments[i+1:]...)...)
+		}
+	}
+	return p
+}
+
+// The unlisted directories by their tokens
+func unlistedDirsByToken() map[string]string {
+	unlistedMu.Lock()
+	defer unlistedMu.Unlock()
+	dirs := map[string]string{}
+	for dir, token := range unlistedTokens {
+		dirs[token] = dir
+	}
+	return dirs
+}
@@ -0,0 +1,38 @@
+package statik
+
+import "testing"
+
+func TestPublishedPaths(t *testing.T) {
+	unlistedDirs = Globs{"private", "shared/*"}
+	unlistedTokens = map[string]string{"private": "t1", "shared/a": "t2"}
+	defer func() { unlistedDirs, unlistedTokens = nil, nil }()
+	tests := []struct {
+		src, published string
+		dir            bool
+	}{
+		{".", ".", true},
+		{"public", "public", true},
+		{"public/file.txt", "public/file.txt", false},
+		{"private", "t1", true},
+		{"private/file.txt", "t1/file.txt", false},
+		{"private/b/c/file.txt", "t1/b/c/file.txt", false},
+		{"shared", "shared", true},
+		{"shared/a", "shared/t2", true},
+		{"shared/a/deep/file.txt", "shared/t2/deep/file.txt", false},
+	}
+	dirs := unlistedDirsByToken()
+	for _, tt := range tests {
+		var got string
+		if tt.dir {
+			got = publishedDir(tt.src)
+		} else {
+			got = publishedPath(tt.src)
+		}
+		if got != tt.published {
+			t.Errorf("published path of %s = %s, want %s", tt.src, got, tt.published)
+		}
+		if back := unpublishedPath(got, dirs); back != tt.src {
+			t.Errorf("source path of %s = %s, want %s", got, back, tt.src)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package statik
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The schemes of the destinations the output is uploaded to through rclone
+var remoteDestinationSchemes = map[string]bool{"s3": true, "sftp": true, "webdav": true, "webdavs": true}
+
+var (
+	// The rclone remote the output is uploaded to, instead of being written
+	// into dstDir
+	remoteDestination string
+	// The source files to upload along with the outputs, relative to srcDir
+	uploads   []string
+	uploadsMu sync.Mutex
+)
+
+// IsRemoteDestination reports whether a destination is the URL of a remote
+// storage, s3://bucket/prefix, sftp://host/path or webdav(s)://host/path,
+// which the output is uploaded to instead of written into
+func IsRemoteDestination(dst string) bool {
+	u, err := url.Parse(dst)
+	return err == nil && remoteDestinationSchemes[u.Scheme] && u.Host != ""
+}
+
+// Translates the URL of a remote destination into an rclone remote configured
+// on the fly. Credentials are left to rclone, e.g. the RCLONE_S3_* variables
+// or the SSH agent
+func rcloneDestination(dst string) (string, error) {
+	u, err := url.Parse(dst)
+	if err != nil {
+		return "", fmt.Errorf("could not parse destination %s:\n%w", dst, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		return ":s3,env_auth=true:" + path.Join(u.Host, u.Path), nil
+	case "sftp":
+		remote := ":sftp,host=" + rcloneQuote(u.Hostname())
+		if u.Port() != "" {
+			remote += ",port=" + rcloneQuote(u.Port())
+		}
+		if u.User != nil {
+			remote += ",user=" + rcloneQuote(u.User.Username())
+		}
+		return remote + ":" + u.Path, nil
+	case "webdav", "webdavs":
+		endpoint := url.URL{Scheme: "http", Host: u.Host, Path: u.Path}
+		if u.Scheme == "webdavs" {
+			endpoint.Scheme = "https"
+		}
+		return ":webdav,url=" + rcloneQuote(endpoint.String()) + ":", nil
+	}
+	return "", fmt.Errorf("%w: unsupported destination %s", ErrInvalidConfig, dst)
+}
+
+func rcloneQuote(v string) string { return `"` + strings.ReplaceAll(v, `"`, `""`) + `"` }
+
+// Files are read straight from a source directory when uploading, and only
+// copied into the scratch directory from other filesystems and unlisted
+// directories, which are published under their tokens
+func uploadingSources() bool { return remoteDestination != "" && osSource && remoteSource == "" }
+
+// Queues the files of a directory for uploading, delisted ones included
+func queueUploads(dir *Directory) {
+	uploadsMu.Lock()
+	defer uploadsMu.Unlock()
+	for _, f := range append(dir.Files[:len(dir.Files):len(dir.Files)], dir.expired...) {
+		if f.MIME == linkMIME {
+			continue
+		}
+		uploads = append(uploads, f.FuzzyFile.Path)
+		if f.Torrent != nil {
+			uploads = append(uploads, f.Torrent.Path)
+		}
+	}
+}
+
+// Generates the outputs into a scratch directory, uploads them along with the
+// source files and removes whatever else the remote destination holds
+func buildAndUpload(report *Report) (err error) {
+	scratch, err := os.MkdirTemp("", "statik-upload-")
+	if err != nil {
+		return fmt.Errorf("could not create scratch directory:\n%w", err)
+	}
+	defer os.RemoveAll(scratch)
+	defer os.Remove(manifestPath(scratch))
+	dstDir, outputRoot = scratch, scratch
+	uploads = nil
+	if err = buildOutput(report); err != nil {
+		return
+	}
+	return upload(scratch)
+}
+
+func upload(scratch string) (err error) {
+	lists, err := os.MkdirTemp("", "statik-lists-")
+	if err != nil {
+		return fmt.Errorf("could not create scratch directory:\n%w", err)
+	}
+	defer os.RemoveAll(lists)
+
+	// rclone skips the files which are already up to date in the remote
+	if len(uploads) != 0 {
+		list := filepath.Join(lists, "files")
+		if err = os.WriteFile(list, []byte(strings.Join(uploads, "\n")+"\n"), regularFile); err != nil {
+			return fmt.Errorf("could not write upload list %s:\n%w", list, err)
+		}
+		if err = rclone("copy", "--files-from-raw", list, srcDir, remoteDestination); err != nil {
+			return
+		}
+	}
+	if err = rclone("copy", scratch, remoteDestination); err != nil {
+		return
+	}
+
+	// Everything but what has just been uploaded is left over from previous
+	// builds, as a local destination would have been cleared
+	var rules strings.Builder
+	keep := func(p string) { fmt.Fprintf(&rules, "- /%s\n", escapeFilter(p)) }
+	for _, p := range uploads {
+		keep(p)
+	}
+	err = filepath.WalkDir(scratch, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(scratch, p)
+		if err == nil {
+			keep(filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("could not list outputs in %s:\n%w", scratch, err)
+	}
+	rules.WriteString("+ **\n")
+	filter := filepath.Join(lists, "filter")
+	if err = os.WriteFile(filter, []byte(rules.String()), regularFile); err != nil {
+		return fmt.Errorf("could not write upload filter %s:\n%w", filter, err)
+	}
+	if err = rclone("delete", "--rmdirs", "--filter-from", filter, remoteDestination); err != nil {
+		return
+	}
+	log.Info().Str("remote", remoteDestination).Int("files", len(uploads)).Msg("Uploaded the output")
+	return nil
+}
+
+// Escapes the characters rclone filters give a meaning to
+func escapeFilter(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if strings.ContainsRune(`*?[]{}\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func rclone(args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(buildCtx, rcloneBinary, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("could not upload to %s with rclone %s:\n%w\n%s", remoteDestination, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
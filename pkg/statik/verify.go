@@ -0,0 +1,218 @@
+package statik
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"sync"
+)
+
+// A Discrepancy is a difference between the destination and what a build of
+// the source would leave in it
+type Discrepancy struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Verify checks the destination of the given configuration against its
+// source without writing anything, returning the copies which are missing or
+// outdated, the outputs which are missing, the ones left over from source
+// files which are gone and the statik.json listings which are out of date.
+// Copies are compared by size, and by checksum when one is configured
+func Verify(ctx context.Context, cfg Config) (found []Discrepancy, err error) {
+	generating.Lock()
+	defer generating.Unlock()
+	if err = apply(ctx, cfg); err != nil {
+		return
+	}
+	if remoteDestination != "" {
+		return nil, fmt.Errorf("%w: remote destinations cannot be verified", ErrInvalidConfig)
+	}
+	dstDir = finalOutputDir()
+	countedInodes = map[inodeKey]bool{}
+	linkedInodes = map[inodeKey]string{}
+	restricted = map[string]*Directory{}
+	problems = nil
+	unreadable, collisions = nil, nil
+
+	entries := map[string]ManifestEntry{}
+	p := manifestPath(dstDir)
+	if file, err := os.Open(p); err == nil {
+		err = readManifest(file, entries)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not parse build manifest %s:\n%w", p, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not open build manifest %s:\n%w", p, err)
+	}
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	report := func(p, reason string) {
+		mu.Lock()
+		defer mu.Unlock()
+		found = append(found, Discrepancy{Path: p, Reason: reason})
+	}
+	_, _, err = walkSource(func(dir *Directory) error {
+		for _, name := range generatedNames(dir) {
+			if _, err := os.Stat(path.Join(dir.DstPath, name)); err != nil {
+				report(path.Join(dir.Path, name), "missing output")
+			} else if name == metadataFileName {
+				if err = verifyListing(dir, report); err != nil {
+					return err
+				}
+			}
+		}
+		mu.Lock()
+		seen[dir.Path] = true
+		for _, f := range dir.Files {
+			seen[f.FuzzyFile.Path] = true
+		}
+		mu.Unlock()
+		// Objects of remote sources are not copied, nor are listing-only
+		// builds
+		if remoteSource != "" || noCopy {
+			return buildCtx.Err()
+		}
+		for i := range dir.Files {
+			f := &dir.Files[i]
+			if f.MIME == linkMIME {
+				continue
+			}
+			if reason, err := verifyCopy(f, entries[f.FuzzyFile.Path]); err != nil {
+				return err
+			} else if reason != "" {
+				report(f.FuzzyFile.Path, reason)
+			}
+		}
+		return buildCtx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range entries {
+		if !seen[p] {
+			report(p, "left over from a source file which is gone")
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	return found, nil
+}
+
+// The fields of a statik.json compared with the source
+type listedDirectory struct {
+	Directories []struct {
+		Path string `json:"path"`
+	} `json:"directories"`
+	Files []struct {
+		Path     string `json:"path"`
+		Bytes    int64  `json:"size_bytes"`
+		Checksum string `json:"checksum"`
+	} `json:"files"`
+}
+
+// Reports the entries a statik.json lists which are gone from the source, the
+// ones it misses and the files it lists with a different size or checksum
+func verifyListing(dir *Directory, report func(p, reason string)) error {
+	p := path.Join(dir.DstPath, metadataFileName)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return fmt.Errorf("could not read listing %s:\n%w", p, err)
+	}
+	var listed listedDirectory
+	if err = json.Unmarshal(data, &listed); err != nil {
+		report(path.Join(dir.Path, metadataFileName), "listing cannot be parsed")
+		return nil
+	}
+
+	entries := map[string]bool{}
+	for _, d := range listed.Directories {
+		entries[d.Path] = true
+	}
+	for _, d := range dir.Directories {
+		if !entries[d.Path] {
+			report(d.Path, "missing from the listing")
+		}
+		delete(entries, d.Path)
+	}
+	files := map[string]*File{}
+	for i := range dir.Files {
+		files[dir.Files[i].FuzzyFile.Path] = &dir.Files[i]
+	}
+	for _, l := range listed.Files {
+		f, ok := files[l.Path]
+		if !ok {
+			entries[l.Path] = true
+			continue
+		}
+		delete(files, l.Path)
+		if f.MIME == linkMIME {
+			continue
+		}
+		if l.Bytes != f.Bytes {
+			report(l.Path, "listed with a different size")
+		} else if f.Checksum != "" && l.Checksum != f.Checksum {
+			report(l.Path, "listed with a different checksum")
+		}
+	}
+	for p := range files {
+		report(p, "missing from the listing")
+	}
+	for p := range entries {
+		report(p, "listed but gone from the source")
+	}
+	return nil
+}
+
+// Describes how the copy of a file differs from its source, if it does
+func verifyCopy(f *File, entry ManifestEntry) (reason string, err error) {
+	info, err := os.Stat(f.FuzzyFile.DstPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "missing copy", nil
+	} else if err != nil {
+		return "", fmt.Errorf("could not stat %s:\n%w", f.FuzzyFile.DstPath, err)
+	}
+	if info.Size() != f.Bytes {
+		return "copy differs in size", nil
+	}
+	if f.Checksum == "" {
+		// Without a checksum, a source modified since it was copied can only
+		// be told by the time recorded in the manifest
+		if entry.Path != "" && !entry.ModTime.Equal(f.ModTime) {
+			return "source modified since it was copied", nil
+		}
+		return "", nil
+	}
+	var sum string
+	if sum, err = checksumOutput(f.FuzzyFile.DstPath); err != nil {
+		return
+	}
+	if sum != f.Checksum {
+		return "copy differs in contents", nil
+	}
+	return "", nil
+}
+
+// Hashes an output with the configured algorithm, as checksumFile does with
+// sources
+func checksumOutput(p string) (string, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s for hashing:\n%w", p, err)
+	}
+	defer file.Close()
+
+	h := checksumAlgorithms[checksumAlgorithm]()
+	if _, err = io.Copy(h, throttled(buildCtx, file)); err != nil {
+		return "", fmt.Errorf("could not hash %s:\n%w", p, err)
+	}
+	return checksumAlgorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,139 @@
+package statik
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// The tabular listing, and the grid of media with a lightbox
+	ListView    = "list"
+	GalleryView = "gallery"
+	// A marker file naming the view of the directory it lives in
+	viewFileName = ".statik-view"
+	// Rows with the essentials only, or with extra metadata under each
+	CompactDensity  = "compact"
+	DetailedDensity = "detailed"
+)
+
+var (
+	// The parsed templates of the views, by name
+	views map[string]*template.Template
+	// The view of directories without a marker file
+	defaultView string
+	// Paths of the templates of custom views, or replacing built-in ones
+	viewTemplatePaths ViewTemplates
+	// The density of listings, until visitors pick their own
+	density string
+)
+
+// ViewTemplates maps the names of views to the paths of their templates, and
+// can be filled from the command line as name=path
+type ViewTemplates map[string]string
+
+func (v *ViewTemplates) String() string {
+	var pairs []string
+	for name, p := range *v {
+		pairs = append(pairs, name+"="+p)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v *ViewTemplates) Set(s string) error {
+	name, p, _ := strings.Cut(s, "=")
+	if name == "" || p == "" {
+		return fmt.Errorf("expected a view in the name=path form, got %q", s)
+	}
+	if *v == nil {
+		*v = ViewTemplates{}
+	}
+	(*v)[name] = p
+	return nil
+}
+
+// Parses the templates of all views, returning their sources by name
+func loadViews() (sources map[string]string, err error) {
+	list := defaultPageTemplate
+	if err = readIfNotEmpty(pageTemplatePath, &list); err != nil {
+		return nil, fmt.Errorf("could not read listing page template:\n%w", err)
+	}
+	sources = map[string]string{ListView: list, GalleryView: defaultGalleryTemplate}
+	for name, p := range viewTemplatePaths {
+		var source string
+		if err = readIfNotEmpty(p, &source); err != nil {
+			return nil, fmt.Errorf("could not read template of the %s view:\n%w", name, err)
+		}
+		sources[name] = source
+	}
+	views = map[string]*template.Template{}
+	for name, source := range sources {
+		if views[name], err = template.New(name).Parse(source); err != nil {
+			return nil, fmt.Errorf("could not parse template of the %s view:\n%w", name, err)
+		}
+	}
+	return sources, nil
+}
+
+// The view of a directory, as named by its marker file if it has one
+func viewOf(rel string, entries []fs.DirEntry) string {
+	for _, entry := range entries {
+		if entry.Name() != viewFileName || entry.IsDir() {
+			continue
+		}
+		p := path.Join(rel, viewFileName)
+		raw, err := fs.ReadFile(srcFS, p)
+		if err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Could not read view marker")
+			warn(err, p)
+			break
+		}
+		name := strings.TrimSpace(string(raw))
+		if _, ok := views[name]; !ok {
+			err = errors.New("unknown view " + name)
+			log.Warn().Err(err).Str("path", p).Msg("Ignoring view marker")
+			warn(err, p)
+			break
+		}
+		return name
+	}
+	return defaultView
+}
+
+// The template to render a page with. Archive pages are always tabular
+func viewTemplate(payload HTMLPayload) *template.Template {
+	if t, ok := views[payload.Root.View]; ok && !payload.Archive {
+		return t
+	}
+	return views[ListView]
+}
+
+func (f File) IsImage() bool { return f.MIME != nil && strings.HasPrefix(f.MIME.String(), "image/") }
+func (f File) IsVideo() bool { return f.MIME != nil && strings.HasPrefix(f.MIME.String(), "video/") }
+
+// The images and videos of the directory, shown in the grid of the gallery
+func (d Directory) Media() (files []File) {
+	for _, f := range d.Files {
+		if f.IsImage() || f.IsVideo() {
+			files = append(files, f)
+		}
+	}
+	return
+}
+
+// The files of the directory which are neither images nor videos
+func (d Directory) Documents() (files []File) {
+	for _, f := range d.Files {
+		if !f.IsImage() && !f.IsVideo() {
+			files = append(files, f)
+		}
+	}
+	return
+}
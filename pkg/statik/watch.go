@@ -0,0 +1,358 @@
+package statik
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// Changes are batched for this long before the affected directories are
+// regenerated, so that a burst of events only triggers a single update
+const watchDebounce = 250 * time.Millisecond
+
+var (
+	keepTree bool
+	// The tree and search index of the last build, kept around in watch mode so
+	// that only the parts affected by a change need to be regenerated
+	builtTree  *Directory
+	builtFuzzy []FuzzyFile
+)
+
+// Watches all the included directories of the tree rooted at base
+func addWatches(watcher *fsnotify.Watcher, base string) {
+	err := filepath.WalkDir(base, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if isOutputPath(p) || (p != base && (!isRecursive || !includeDir(entry))) {
+			return filepath.SkipDir
+		}
+		if err = watcher.Add(p); err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("Could not watch directory")
+			warn(err, sourcePath(p))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("path", base).Msg("Could not watch directory tree")
+		warn(err, sourcePath(base))
+	}
+}
+
+// Returns the relative path of the directory whose listing is affected by a
+// change to the given path, and whether the change is of interest at all
+func affectedDir(name string) (string, bool) {
+	if isOutputPath(name) {
+		return "", false
+	}
+	// Changes to the ignore files, view markers and settings files affect the
+	// listing they are in
+	base := filepath.Base(name)
+	if name != srcDir && excludeRegEx.MatchString(base) && !isIgnoreFile(base) && base != viewFileName && base != dirSettingsFileName {
+		return "", false
+	}
+	rel, err := filepath.Rel(srcDir, filepath.Dir(name))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ".", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Reduces a set of directories to the ones which have no ancestor in the set,
+// as regenerating a subtree already covers all of its descendants
+func topmostDirs(dirs map[string]bool) (top []string) {
+	for dir := range dirs {
+		covered := false
+		for p := dir; p != "."; {
+			p = path.Dir(p)
+			if dirs[p] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			top = append(top, dir)
+		}
+	}
+	sort.Strings(top)
+	return
+}
+
+// Returns the chain of directories from the root of the tree down to the
+// deepest one on the given path which is still present in the source
+func lookupChain(root *Directory, rel string) (chain []*Directory) {
+	chain = []*Directory{root}
+	if rel != "." {
+	outer:
+		for _, part := range strings.Split(rel, "/") {
+			dir := chain[len(chain)-1]
+			for i := range dir.Directories {
+				if dir.Directories[i].Name == part {
+					chain = append(chain, &dir.Directories[i])
+					continue outer
+				}
+			}
+			break
+		}
+	}
+	for len(chain) > 1 {
+		if _, err := fs.Stat(srcFS, chain[len(chain)-1].Path); err == nil {
+			break
+		}
+		chain = chain[:len(chain)-1]
+	}
+	return
+}
+
+// Collects the relative paths of all directories in a subtree
+func subtreeDirs(dir *Directory, dirs map[string]bool) {
+	dirs[dir.Path] = true
+	for i := range dir.Directories {
+		if dir.Directories[i].Name != ".." {
+			subtreeDirs(&dir.Directories[i], dirs)
+		}
+	}
+}
+
+// Walks the subtree at the end of the chain again, splicing the result into
+// the tree and regenerating the listings of all of its ancestors
+func updateChain(chain []*Directory) (err error) {
+	var (
+		sub   Directory
+		subfz []FuzzyFile
+	)
+	old := chain[len(chain)-1]
+	if sub, subfz, err = walk(old.Path, generate); err != nil {
+		return
+	}
+	if err = renderer.Wait(); err != nil {
+		return
+	}
+	if err = buildCtx.Err(); err != nil {
+		return
+	}
+
+	// Outputs of files and directories which are gone from the source
+	oldDirs, newDirs := map[string]bool{}, map[string]bool{}
+	subtreeDirs(old, oldDirs)
+	if !sub.isEmpty() || includeEmpty {
+		subtreeDirs(&sub, newDirs)
+	}
+	for p := range oldDirs {
+		if !newDirs[p] {
+			if err = os.RemoveAll(path.Join(dstDir, publishedDir(p))); err != nil {
+				return
+			}
+			if generateThumbnails {
+				removeThumbnails(p)
+			}
+			forgetTargets(p)
+		}
+	}
+	for _, orphan := range manifest.Orphans() {
+		if strings.HasPrefix(orphan.Path, old.Path+"/") {
+			if err = os.Remove(orphan.output()); err != nil && !os.IsNotExist(err) {
+				return
+			}
+			if generateThumbnails {
+				removeThumbnails(orphan.Path)
+			}
+			if generatePreviews && orphan.Listing == "" {
+				removePreview(orphan.Path)
+			}
+			manifest.Forget(orphan.Path)
+		}
+	}
+
+	kept := builtFuzzy[:0]
+	for _, f := range builtFuzzy {
+		if !strings.HasPrefix(f.Path, old.Path+"/") {
+			kept = append(kept, f)
+		}
+	}
+	builtFuzzy = append(kept, subfz...)
+
+	delta := sub.TotalBytes - old.TotalBytes
+	parent := chain[len(chain)-2]
+	if sub.isEmpty() && !includeEmpty {
+		for i := range parent.Directories {
+			if &parent.Directories[i] == old {
+				parent.Directories = append(parent.Directories[:i], parent.Directories[i+1:]...)
+				break
+			}
+		}
+	} else {
+		*old = sub
+	}
+	for i := range parent.Directories {
+		parent.Directories[i].Latest = false
+	}
+	markLatest(parent)
+
+	for i := len(chain) - 2; i >= 0; i-- {
+		dir := chain[i]
+		dir.TotalBytes += delta
+		dir.unchanged = false
+		if err = writeTargets(dir); err != nil {
+			return
+		}
+		if err = manifest.RecordListing(dir, listingDigest(dir)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Regenerates the given directories, falling back to a full build when the
+// root itself is affected or no previous build is available
+func update(report *Report, dirs []string) (err error) {
+	var chains [][]*Directory
+	if builtTree == nil {
+		return build(report)
+	}
+	for _, dir := range dirs {
+		chain := lookupChain(builtTree, dir)
+		if len(chain) == 1 {
+			return build(report)
+		}
+		chains = append(chains, chain)
+	}
+
+	countedInodes = map[inodeKey]bool{}
+	linkedInodes = map[inodeKey]string{}
+	unreadable, collisions = nil, nil
+	expiredFiles, expiringFiles = nil, nil
+	if manifest, err = openManifest(dstDir, true); err != nil {
+		return
+	}
+	defer manifest.Close()
+	renderer = newWorkerPool(runtime.NumCPU())
+	for _, chain := range chains {
+		log.Debug().Str("path", chain[len(chain)-1].Path).Msg("Regenerating subtree")
+		if err = updateChain(chain); err != nil {
+			return
+		}
+	}
+
+	if err = finishTargets(builtTree, builtFuzzy); err != nil {
+		return
+	}
+	if err = writeNginxAuth(); err != nil {
+		return
+	}
+	if err = writeUnlistedManifest(); err != nil {
+		return
+	}
+	if err = writeServerConfigs(builtFuzzy); err != nil {
+		return
+	}
+	registerTypes(builtFuzzy)
+	if err = manifest.Compact(); err != nil {
+		return
+	}
+	report.Files = len(builtFuzzy)
+	report.Bytes = builtTree.TotalBytes
+	report.Unreadable, report.Collisions = unreadable, collisions
+	report.Expired, report.Expiring = expiredFiles, expiringReport()
+	return nil
+}
+
+// Applies the configuration again and regenerates the given directories
+func runUpdate(ctx context.Context, cfg Config, dirs []string) (report Report) {
+	generating.Lock()
+	defer generating.Unlock()
+	ctx, cancel := withTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	report = newReport(cfg)
+	problems = nil
+	err := apply(ctx, cfg)
+	report.Build = buildID
+	if err == nil {
+		emit(Event{Kind: BuildStarted})
+		err = update(&report, dirs)
+	}
+	err = joinErrors(append([]error{err}, problems...)...)
+	report.finish(err)
+	if err != nil {
+		// Start over from a clean build on the next change
+		builtTree, builtFuzzy = nil, nil
+	}
+	return
+}
+
+// ValidateWatch checks a configuration like Validate, also rejecting the
+// options which cannot be combined with watching the source
+func (c *Config) ValidateWatch() error {
+	if c.Remote != "" || c.FromJSON != "" || len(c.Aggregate) != 0 || len(c.Mounts) != 0 || c.Objects || c.FS != nil || c.LowMemory || c.TimeBudget > 0 || c.Keep > 0 || c.AppendOnly || c.Deploy != "" || IsRemoteDestination(c.Destination) {
+		return fmt.Errorf("%w: watching cannot be combined with a remote, metadata or mounted source, objects, a remote destination, a custom filesystem, low memory mode, time budgets, kept generations, append-only destinations or deploys", ErrInvalidConfig)
+	}
+	return c.Validate()
+}
+
+// Watch builds the site and keeps it up to date with the source directory,
+// regenerating only the subtrees affected by each batch of changes until the
+// context is cancelled. The outcome of the initial build and of each update is
+// handed to the updated callback, along with the changed directories
+func Watch(ctx context.Context, cfg Config, updated func(report *Report, dirs []string)) error {
+	if err := cfg.ValidateWatch(); err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not start watching the source directory:\n%w", err)
+	}
+	defer watcher.Close()
+
+	// A failed initial build is only reported, the next change retries it
+	cfg.keepTree = true
+	report, _ := Generate(ctx, cfg)
+	updated(&report, nil)
+	addWatches(watcher, srcDir)
+	log.Info().Str("source", srcDir).Msg("Watching for changes")
+
+	var debounce <-chan time.Time
+	pending := map[string]bool{}
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			rel, ok := affectedDir(event.Name)
+			if !ok {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					addWatches(watcher, event.Name)
+				}
+			}
+			pending[rel] = true
+			debounce = time.After(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn().Err(err).Msg("Error while watching the source directory")
+			warn(err, "")
+		case <-debounce:
+			dirs := topmostDirs(pending)
+			report := runUpdate(ctx, cfg, dirs)
+			updated(&report, dirs)
+			pending = map[string]bool{}
+			debounce = nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
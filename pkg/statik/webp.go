@@ -0,0 +1,512 @@
+package statik
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+// A minimal encoder of lossless WebP (VP8L) images, enough for thumbnails.
+// Pixels go through the subtract green and predictor transforms, and are
+// coded as literals and runs of the previous pixel, each channel with its own
+// prefix code.
+
+const (
+	vp8lMaxCodeLength = 15
+	// Literals, then the 24 prefix codes of backward reference lengths
+	vp8lGreenSymbols = 256 + 24
+	vp8lDistSymbols  = 40
+	vp8lMaxRun       = 4096
+	// The distance code of the pixel to the left, in VP8L's 2D distance map
+	vp8lLeftPixel = 2
+	// Predictor modes are chosen for blocks of 16 by 16 pixels
+	vp8lPredictorBits = 4
+)
+
+// The predictor modes tried on each block: left, top, their average, select,
+// and the two clamped gradients
+var vp8lPredictors = []int{1, 2, 7, 11, 12, 13}
+
+// The order in which the lengths of the code length code are written
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Writes bits least significant first, as VP8L reads them
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.acc |= uint64(v) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
+
+// A prefix code, as the length of each symbol's code and the codes
+// themselves, bit reversed to be written least significant first
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+// A symbol of the image stream: a literal pixel or a run of the previous one
+type vp8lToken struct {
+	argb uint32
+	run  int
+}
+
+// Encodes an image as a lossless WebP into w
+func encodeWebP(w io.Writer, img *image.NRGBA) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	pixels := make([]uint32, 0, width*height)
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < width; x++ {
+			r, g, b, a := uint32(row[4*x]), uint32(row[4*x+1]), uint32(row[4*x+2]), uint32(row[4*x+3])
+			opaque = opaque && a == 0xff
+			// The subtract green transform is applied up front
+			pixels = append(pixels, a<<24|((r-g)&0xff)<<16|g<<8|(b-g)&0xff)
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if opaque {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3)
+	// The subtract green transform, followed by the predictor one
+	bw.write(1, 1)
+	bw.write(2, 2)
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(vp8lPredictorBits-2, 3)
+	modes, residuals := predict(pixels, width, height)
+	writeEntropyImage(bw, modes, false)
+	bw.write(0, 1)
+	writeEntropyImage(bw, residuals, true)
+	data := bw.bytes()
+
+	// The RIFF container, padding the chunk to an even size
+	chunk := len(data) + len(data)&1
+	header := make([]byte, 20, 20+chunk)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+chunk))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	header = append(header, data...)
+	if len(data)&1 != 0 {
+		header = append(header, 0)
+	}
+	_, err := w.Write(header)
+	return err
+}
+
+// Picks the predictor of each block, the one leaving the smallest residuals,
+// and returns the image of the modes along with the residuals
+func predict(pixels []uint32, width, height int) (modes, residuals []uint32) {
+	block := 1 << vp8lPredictorBits
+	bw, bh := (width+block-1)/block, (height+block-1)/block
+	modes = make([]uint32, bw*bh)
+	residuals = make([]uint32, len(pixels))
+	for by := 0; by < bh; by++ {
+		for bx := 0; bx < bw; bx++ {
+			best, bestCost := vp8lPredictors[0], -1
+			for _, mode := range vp8lPredictors {
+				cost := 0
+				for y := by * block; y < (by+1)*block && y < height; y++ {
+					for x := bx * block; x < (bx+1)*block && x < width; x++ {
+						cost += residualCost(sub(pixels[y*width+x], predictPixel(pixels, width, x, y, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			// The mode is stored in the green channel
+			modes[by*bw+bx] = 0xff000000 | uint32(best)<<8
+			for y := by * block; y < (by+1)*block && y < height; y++ {
+				for x := bx * block; x < (bx+1)*block && x < width; x++ {
+					residuals[y*width+x] = sub(pixels[y*width+x], predictPixel(pixels, width, x, y, best))
+				}
+			}
+		}
+	}
+	return
+}
+
+// The prediction of a pixel from its already decoded neighbours
+func predictPixel(pixels []uint32, width, x, y, mode int) uint32 {
+	i := y*width + x
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return pixels[i-1]
+	case x == 0:
+		return pixels[i-width]
+	}
+	l, t, tl := pixels[i-1], pixels[i-width], pixels[i-width-1]
+	switch mode {
+	case 1:
+		return l
+	case 2:
+		return t
+	case 7:
+		return average(l, t)
+	case 11:
+		// The neighbour closest to the gradient estimate
+		pl, pt := 0, 0
+		for shift := 0; shift < 32; shift += 8 {
+			cl, ct, ctl := int(l>>shift&0xff), int(t>>shift&0xff), int(tl>>shift&0xff)
+			pl += abs(ct - ctl)
+			pt += abs(cl - ctl)
+		}
+		if pl < pt {
+			return l
+		}
+		return t
+	case 12:
+		return channels(func(cl, ct, ctl int) int { return cl + ct - ctl }, l, t, tl)
+	default:
+		a := average(l, t)
+		return channels(func(ca, _, ctl int) int { return ca + (ca-ctl)/2 }, a, a, tl)
+	}
+}
+
+// Applies f to each channel of the given pixels, clamping the results
+func channels(f func(a, b, c int) int, a, b, c uint32) (p uint32) {
+	for shift := 0; shift < 32; shift += 8 {
+		v := f(int(a>>shift&0xff), int(b>>shift&0xff), int(c>>shift&0xff))
+		if v < 0 {
+			v = 0
+		} else if v > 0xff {
+			v = 0xff
+		}
+		p |= uint32(v) << shift
+	}
+	return
+}
+
+func average(a, b uint32) uint32 {
+	return (a>>1&0x7f7f7f7f + b>>1&0x7f7f7f7f) + (a & b & 0x01010101)
+}
+
+// Subtracts each channel of b from a, modulo 256
+func sub(a, b uint32) uint32 {
+	return ((a|0x00ff00ff)-(b&0xff00ff00))&0xff00ff00 | ((a|0xff00ff00)-(b&0x00ff00ff))&0x00ff00ff
+}
+
+// How far the residual of a pixel is from zero, channel by channel
+func residualCost(p uint32) (cost int) {
+	for shift := 0; shift < 32; shift += 8 {
+		cost += abs(int(int8(p >> shift)))
+	}
+	return
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Writes an entropy coded image: literal pixels and runs of the previous
+// one, each channel with its own prefix code and no color cache. The main
+// image also tells there are no meta prefix codes
+func writeEntropyImage(bw *bitWriter, pixels []uint32, main bool) {
+	// Runs of the same pixel refer back to the one on their left
+	var tokens []vp8lToken
+	for i := 0; i < len(pixels); {
+		run := 0
+		for i > 0 && i+run < len(pixels) && run < vp8lMaxRun && pixels[i+run] == pixels[i-1] {
+			run++
+		}
+		if run >= 3 {
+			tokens = append(tokens, vp8lToken{run: run})
+			i += run
+			continue
+		}
+		tokens = append(tokens, vp8lToken{argb: pixels[i]})
+		i++
+	}
+
+	green, red, blue, alpha := make([]int, vp8lGreenSymbols), make([]int, 256), make([]int, 256), make([]int, 256)
+	dist := make([]int, vp8lDistSymbols)
+	for _, t := range tokens {
+		if t.run != 0 {
+			code, _, _ := vp8lPrefix(t.run)
+			green[256+code]++
+			code, _, _ = vp8lPrefix(vp8lLeftPixel)
+			dist[code]++
+			continue
+		}
+		green[t.argb>>8&0xff]++
+		red[t.argb>>16&0xff]++
+		blue[t.argb&0xff]++
+		alpha[t.argb>>24]++
+	}
+
+	bw.write(0, 1)
+	if main {
+		bw.write(0, 1)
+	}
+	codes := make([]prefixCode, 5)
+	for i, freqs := range [][]int{green, red, blue, alpha, dist} {
+		codes[i] = writePrefixCode(bw, freqs)
+	}
+	emitSymbol := func(c prefixCode, s int) { bw.write(c.codes[s], uint(c.lengths[s])) }
+	for _, t := range tokens {
+		if t.run != 0 {
+			code, extra, n := vp8lPrefix(t.run)
+			emitSymbol(codes[0], 256+code)
+			bw.write(extra, n)
+			code, extra, n = vp8lPrefix(vp8lLeftPixel)
+			emitSymbol(codes[4], code)
+			bw.write(extra, n)
+			continue
+		}
+		emitSymbol(codes[0], int(t.argb>>8&0xff))
+		emitSymbol(codes[1], int(t.argb>>16&0xff))
+		emitSymbol(codes[2], int(t.argb&0xff))
+		emitSymbol(codes[3], int(t.argb>>24))
+	}
+}
+
+// Splits a length or distance into its prefix code and extra bits
+func vp8lPrefix(v int) (code int, extra uint32, n uint) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	h := uint(0)
+	for d>>(h+1) != 0 {
+		h++
+	}
+	second := d >> (h - 1) & 1
+	n = h - 1
+	return int(2*h) + second, uint32(d & (1<<n - 1)), n
+}
+
+// Builds the prefix code for the given symbol frequencies and writes it
+func writePrefixCode(bw *bitWriter, freqs []int) prefixCode {
+	var used []int
+	for s, f := range freqs {
+		if f != 0 {
+			used = append(used, s)
+		}
+	}
+	// One or two small symbols fit the simple code, where a lone symbol
+	// takes no bits at all
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		c := prefixCode{lengths: make([]uint8, len(freqs)), codes: make([]uint32, len(freqs))}
+		bw.write(1, 1)
+		bw.write(uint32(len(used)-1), 1)
+		bw.write(1, 1)
+		bw.write(uint32(used[0]), 8)
+		if len(used) == 2 {
+			bw.write(uint32(used[1]), 8)
+			c.lengths[used[0]], c.lengths[used[1]] = 1, 1
+			c.codes[used[1]] = 1
+		}
+		return c
+	}
+
+	c := newPrefixCode(freqs, vp8lMaxCodeLength)
+	// The code lengths are themselves written with a prefix code, using
+	// the 17 and 18 symbols for runs of zeros
+	type token struct {
+		sym   int
+		extra uint32
+		n     uint
+	}
+	var tokens []token
+	for i := 0; i < len(c.lengths); {
+		if c.lengths[i] != 0 {
+			tokens = append(tokens, token{sym: int(c.lengths[i])})
+			i++
+			continue
+		}
+		zeros := 0
+		for i+zeros < len(c.lengths) && c.lengths[i+zeros] == 0 && zeros < 138 {
+			zeros++
+		}
+		switch {
+		case zeros >= 11:
+			tokens = append(tokens, token{18, uint32(zeros - 11), 7})
+		case zeros >= 3:
+			tokens = append(tokens, token{17, uint32(zeros - 3), 3})
+		default:
+			for j := 0; j < zeros; j++ {
+				tokens = append(tokens, token{sym: 0})
+			}
+		}
+		i += zeros
+	}
+	lengthFreqs := make([]int, 19)
+	for _, t := range tokens {
+		lengthFreqs[t.sym]++
+	}
+	lc := newPrefixCode(lengthFreqs, 7)
+	count := 19
+	for count > 4 && lc.lengths[vp8lCodeLengthOrder[count-1]] == 0 {
+		count--
+	}
+	bw.write(0, 1)
+	bw.write(uint32(count-4), 4)
+	for _, s := range vp8lCodeLengthOrder[:count] {
+		bw.write(uint32(lc.lengths[s]), 3)
+	}
+	// All symbols are coded, there is no early end
+	bw.write(0, 1)
+	for _, t := range tokens {
+		bw.write(lc.codes[t.sym], uint(lc.lengths[t.sym]))
+		bw.write(t.extra, t.n)
+	}
+	return c
+}
+
+// Builds a canonical prefix code with lengths of at most limit bits. At least
+// two symbols are always given a code, so that the code is complete
+func newPrefixCode(freqs []int, limit int) prefixCode {
+	f := make([]int, len(freqs))
+	copy(f, freqs)
+	var used int
+	for _, v := range f {
+		if v != 0 {
+			used++
+		}
+	}
+	for s := 0; used < 2; s++ {
+		if f[s] == 0 {
+			f[s], used = 1, used+1
+		}
+	}
+	lengths := huffmanLengths(f)
+	// Flattening the frequencies until the longest code fits the limit
+	for longest(lengths) > limit {
+		for i, v := range f {
+			if v != 0 {
+				f[i] = v/2 + 1
+			}
+		}
+		lengths = huffmanLengths(f)
+	}
+
+	c := prefixCode{lengths: lengths, codes: make([]uint32, len(f))}
+	var counts [vp8lMaxCodeLength + 1]uint32
+	for _, l := range lengths {
+		counts[l]++
+	}
+	counts[0] = 0
+	var next [vp8lMaxCodeLength + 1]uint32
+	code := uint32(0)
+	for l := 1; l <= vp8lMaxCodeLength; l++ {
+		code = (code + counts[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c.codes[s] = reverseBits(next[l], uint(l))
+		next[l]++
+	}
+	return c
+}
+
+func longest(lengths []uint8) (max int) {
+	for _, l := range lengths {
+		if int(l) > max {
+			max = int(l)
+		}
+	}
+	return
+}
+
+func reverseBits(v uint32, n uint) (r uint32) {
+	for i := uint(0); i < n; i++ {
+		r = r<<1 | v>>i&1
+	}
+	return
+}
+
+// A node of the Huffman tree, either a symbol or the join of two nodes
+type huffmanNode struct {
+	freq        int
+	sym         int
+	left, right *huffmanNode
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].sym < h[j].sym
+}
+func (h huffmanHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x any)   { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// The depths of the symbols in a Huffman tree built from their frequencies
+func huffmanLengths(freqs []int) []uint8 {
+	var h huffmanHeap
+	for s, f := range freqs {
+		if f != 0 {
+			h = append(h, &huffmanNode{freq: f, sym: s})
+		}
+	}
+	heap.Init(&h)
+	for h.Len() > 1 {
+		a, b := heap.Pop(&h).(*huffmanNode), heap.Pop(&h).(*huffmanNode)
+		sym := a.sym
+		if b.sym < sym {
+			sym = b.sym
+		}
+		heap.Push(&h, &huffmanNode{freq: a.freq + b.freq, sym: sym, left: a, right: b})
+	}
+	lengths := make([]uint8, len(freqs))
+	var visit func(n *huffmanNode, depth uint8)
+	visit = func(n *huffmanNode, depth uint8) {
+		if n.left == nil {
+			lengths[n.sym] = depth
+			return
+		}
+		visit(n.left, depth+1)
+		visit(n.right, depth+1)
+	}
+	visit(heap.Pop(&h).(*huffmanNode), 0)
+	return lengths
+}
@@ -0,0 +1,68 @@
+package statik
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name          string
+		width, height int
+		pixel         func(x, y int) color.NRGBA
+	}{
+		{"single pixel", 1, 1, func(x, y int) color.NRGBA { return color.NRGBA{12, 34, 56, 255} }},
+		{"solid", 100, 100, func(x, y int) color.NRGBA { return color.NRGBA{200, 100, 50, 255} }},
+		{"gradient", 37, 21, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 7), uint8(y * 12), uint8(x*y) ^ 0x5a, 255}
+		}},
+		{"stripes", 64, 48, func(x, y int) color.NRGBA {
+			if (x/3+y/5)%2 == 0 {
+				return color.NRGBA{0, 0, 0, 255}
+			}
+			return color.NRGBA{255, 255, 255, 255}
+		}},
+		{"translucent", 19, 23, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 13), uint8(y * 11), 90, uint8(x*y*3 + 1)}
+		}},
+		{"noise", 83, 61, func(x, y int) color.NRGBA {
+			v := rng.Uint32()
+			return color.NRGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), uint8(v >> 24)}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, tt.width, tt.height))
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					img.SetNRGBA(x, y, tt.pixel(x, y))
+				}
+			}
+			var buf bytes.Buffer
+			if err := encodeWebP(&buf, img); err != nil {
+				t.Fatalf("could not encode: %v", err)
+			}
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("could not decode: %v", err)
+			}
+			if decoded.Bounds() != img.Bounds() {
+				t.Fatalf("decoded bounds %v, want %v", decoded.Bounds(), img.Bounds())
+			}
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if want := img.NRGBAAt(x, y); got != want {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
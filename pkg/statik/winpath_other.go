@@ -0,0 +1,10 @@
+//go:build !windows
+
+package statik
+
+// Paths need no special treatment outside of Windows
+func verbatimPath(p string) string { return p }
+
+func warnReserved(string) {}
+
+func creatableDir(*Directory, string) bool { return true }
@@ -0,0 +1,68 @@
+package statik
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Windows limits paths to MAX_PATH characters and refuses device names such
+// as CON or nul.txt, along with names ending in a dot or a space, unless the
+// path is given verbatim with the \\?\ prefix, which skips all normalization
+const maxPath = 260
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func reservedName(name string) bool {
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return true
+	}
+	base, _, _ := strings.Cut(name, ".")
+	return reservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// Turns an absolute path into a verbatim one when Windows would not accept
+// it as it is
+func verbatimPath(p string) string {
+	if strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	p = filepath.Clean(p)
+	if len(p) < maxPath && !reservedName(filepath.Base(p)) {
+		return p
+	}
+	if strings.HasPrefix(p, `\\`) {
+		return `\\?\UNC\` + p[2:]
+	}
+	return `\\?\` + p
+}
+
+// Warns about files with reserved names, which are copied verbatim but
+// cannot be opened by most Windows programs
+func warnReserved(rel string) {
+	if reservedName(path.Base(rel)) {
+		err := fmt.Errorf("%w: %s", ErrReservedName, rel)
+		log.Warn().Err(err).Msg("Copying a file most Windows programs cannot open")
+		warn(err, rel)
+	}
+}
+
+// Directories with reserved names are left out with a warning, as all the
+// outputs written into them would need verbatim paths as well
+func creatableDir(parent *Directory, rel string) bool {
+	if !reservedName(path.Base(rel)) {
+		return true
+	}
+	err := fmt.Errorf("%w: %s", ErrReservedName, rel)
+	log.Warn().Err(err).Msg("Skipping a directory which cannot be created on Windows")
+	warn(err, rel)
+	parent.addBroken(rel, SkippedEntry, err)
+	return false
+}
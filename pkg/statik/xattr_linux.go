@@ -0,0 +1,51 @@
+//go:build linux
+
+package statik
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+const selinuxXattr = "security.selinux"
+
+// Extended attributes carrying the SELinux context and the POSIX ACL of a file
+var preservedXattrs = []string{selinuxXattr, "system.posix_acl_access"}
+
+func unsupportedXattr(err error) bool {
+	return errors.Is(err, unix.ENODATA) || errors.Is(err, unix.ENOTSUP)
+}
+
+// Copies the SELinux context and POSIX ACL of src onto dst. Attributes which
+// are missing on the source or unsupported by the destination are skipped
+func copyXattrs(src, dst string) error {
+	for _, name := range preservedXattrs {
+		size, err := unix.Lgetxattr(src, name, nil)
+		if err != nil {
+			if unsupportedXattr(err) {
+				continue
+			}
+			return fmt.Errorf("could not read attribute %s of %s:\n%w", name, src, err)
+		}
+		value := make([]byte, size)
+		if size, err = unix.Lgetxattr(src, name, value); err != nil {
+			return fmt.Errorf("could not read attribute %s of %s:\n%w", name, src, err)
+		}
+		if err = unix.Lsetxattr(dst, name, value[:size], 0); err != nil {
+			if unsupportedXattr(err) {
+				continue
+			}
+			return fmt.Errorf("could not set attribute %s on %s:\n%w", name, dst, err)
+		}
+	}
+	return nil
+}
+
+func setSELinuxContext(path, context string) error {
+	if err := unix.Lsetxattr(path, selinuxXattr, []byte(context), 0); err != nil {
+		return fmt.Errorf("could not set SELinux context on %s:\n%w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package statik
+
+import "errors"
+
+var errXattrUnsupported = errors.New("SELinux contexts and ACLs are only supported on linux")
+
+func copyXattrs(src, dst string) error { return errXattrUnsupported }
+
+func setSELinuxContext(path, context string) error { return errXattrUnsupported }
@@ -0,0 +1,268 @@
+// Package statiktest provides a deterministic source tree and golden file
+// comparisons for checking the output of statik, e.g. when working on the
+// generator itself or on a custom page template:
+//
+//	func TestTheme(t *testing.T) {
+//		c := statik.DefaultConfig()
+//		c.FS = statiktest.Fixture()
+//		c.PageTemplate = "theme.gohtml"
+//		statiktest.CompareGolden(t, statiktest.Build(t, c), "testdata/golden")
+//	}
+//
+// Golden files are rewritten from the current output when running the tests
+// with -update-golden.
+package statiktest
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lucat1/statik/pkg/statik"
+)
+
+var update = flag.Bool("update-golden", false, "Rewrite the golden files of statiktest from the current output")
+
+// The modification time of the oldest fixture file, each following one being
+// a day newer in path order
+var FixtureEpoch = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// A Scrubber replaces the parts of the output which change on every build
+type Scrubber struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// The scrubbers applied to outputs before comparing them, in order. The
+// defaults blank out the generation time and the size of directories, which
+// depends on the filesystem, in the metadata and the built-in listing page
+var Scrubbers = []Scrubber{
+	{regexp.MustCompile(`"generated_at":"[^"]*"`), `"generated_at":"GENERATED"`},
+	{regexp.MustCompile(`"generated_at_unix":\d+`), `"generated_at_unix":0`},
+	{regexp.MustCompile(`"build":"[0-9A-Z]{26}"`), `"build":"BUILD"`},
+	{regexp.MustCompile(`(name=statik-build content="?)[0-9A-Z]{26}`), `${1}BUILD`},
+	{regexp.MustCompile(`"size_bytes":\d+,"total_bytes"`), `"size_bytes":0,"total_bytes"`},
+	{regexp.MustCompile(`("generated_at":"GENERATED","name":"[^"]*","path":"[^"]*","size":)"[^"]*"`), `${1}"SIZE"`},
+	{regexp.MustCompile(`(statik</a> on )[^<]*`), `${1}GENERATED`},
+	{regexp.MustCompile(`(class=d(?: title="[^"]*")?>(?:[^<]|<sup[^>]*>|</sup>)*</a><p>[^<]*<p>)[^<]*`), `${1}SIZE`},
+}
+
+// Fixture returns the source tree used by the golden tests: a few nested
+// directories, an empty one, versioned releases, a link file and release
+// notes. File contents are pseudo random but the same on every call
+func Fixture() fstest.MapFS {
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+	files := map[string][]byte{
+		"README.txt":                 []byte("A deterministic tree for testing statik\n"),
+		"data/sample.json":           []byte(`{"ok":true}` + "\n"),
+		"docs/guide.txt":             []byte("Read the manual\n"),
+		"docs/nested/deep/page.txt":  []byte("Deep down\n"),
+		"links/homepage.link":        []byte("https://example.com/\n"),
+		"releases/CHANGELOG.md":      []byte("# Changelog\n\n- Initial release\n"),
+		"releases/app-1.0.0.tar.gz":  random(1 << 10),
+		"releases/app-1.2.0.tar.gz":  random(3 << 10),
+		"releases/app-1.10.0.tar.gz": random(5 << 10),
+	}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fsys := fstest.MapFS{"empty": {Mode: fs.ModeDir | 0o755, ModTime: FixtureEpoch}}
+	for i, name := range names {
+		mtime := FixtureEpoch.Add(time.Duration(i) * 24 * time.Hour)
+		fsys[name] = &fstest.MapFile{Data: files[name], Mode: 0o644, ModTime: mtime}
+		// Directories carry the time of their newest file
+		dir := name
+		for dir != "." {
+			dir = path.Dir(dir)
+			fsys[dir] = &fstest.MapFile{Mode: fs.ModeDir | 0o755, ModTime: mtime}
+		}
+	}
+	return fsys
+}
+
+// WriteFixture copies a fixture onto disk at dir, keeping its modification
+// times, for running the command line or anything else requiring real files.
+// The name of the root listing is the base name of dir, which should thus be
+// the same across runs
+func WriteFixture(dir string, fsys fs.FS) error {
+	var dirs []string
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if entry.IsDir() {
+			dirs = append(dirs, name)
+			return os.MkdirAll(dst, 0o755)
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(dst, data, 0o644); err != nil {
+			return err
+		}
+		return touch(fsys, name, dst)
+	})
+	if err != nil {
+		return fmt.Errorf("could not write fixture into %s:\n%w", dir, err)
+	}
+	// Directory times are set once nothing is going to be written into them
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err = touch(fsys, dirs[i], filepath.Join(dir, filepath.FromSlash(dirs[i]))); err != nil {
+			return fmt.Errorf("could not write fixture into %s:\n%w", dir, err)
+		}
+	}
+	return nil
+}
+
+func touch(fsys fs.FS, name, dst string) error {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// Build generates the output of a configuration, into a temporary directory
+// unless a destination is set, failing the test on errors. Returns the path
+// of the output
+func Build(t testing.TB, c statik.Config) string {
+	t.Helper()
+	if c.Destination == "" || c.Destination == statik.DefaultConfig().Destination {
+		c.Destination = filepath.Join(t.TempDir(), "out")
+	}
+	if _, err := statik.Generate(context.Background(), c); err != nil {
+		t.Fatalf("could not generate the output:\n%v", err)
+	}
+	return c.Destination
+}
+
+// Scrub blanks out the parts of an output which differ between builds
+func Scrub(data []byte) []byte {
+	for _, s := range Scrubbers {
+		data = s.Pattern.ReplaceAll(data, []byte(s.Replacement))
+	}
+	return data
+}
+
+// CompareGolden compares every file of an output with the file at the same
+// path under golden, once scrubbed, and reports missing or extra outputs. When
+// running with -update-golden the golden files are rewritten instead
+func CompareGolden(t testing.TB, output, golden string) {
+	t.Helper()
+	got, err := readTree(output)
+	if err != nil {
+		t.Fatalf("could not read the output:\n%v", err)
+	}
+	if *update {
+		if err = os.RemoveAll(golden); err != nil {
+			t.Fatalf("could not clear the golden files:\n%v", err)
+		}
+		for name, data := range got {
+			p := filepath.Join(golden, filepath.FromSlash(name))
+			if err = os.MkdirAll(filepath.Dir(p), 0o755); err == nil {
+				err = os.WriteFile(p, data, 0o644)
+			}
+			if err != nil {
+				t.Fatalf("could not write golden file %s:\n%v", p, err)
+			}
+		}
+		return
+	}
+
+	want, err := readTree(golden)
+	if err != nil {
+		t.Fatalf("could not read the golden files, run with -update-golden to create them:\n%v", err)
+	}
+	for _, name := range sortedKeys(want) {
+		data, ok := got[name]
+		if !ok {
+			t.Errorf("missing output %s", name)
+		} else if !bytes.Equal(data, want[name]) {
+			t.Errorf("output %s differs from the golden file:\n%s", name, firstDifference(data, want[name]))
+		}
+	}
+	for _, name := range sortedKeys(got) {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected output %s", name)
+		}
+	}
+}
+
+// Reads all the files in a tree, scrubbed and keyed by their slash separated
+// path
+func readTree(root string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		var data []byte
+		if entry.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			data = []byte("symlink to " + target)
+		} else if data, err = os.ReadFile(p); err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = Scrub(data)
+		return nil
+	})
+	return files, err
+}
+
+func sortedKeys(m map[string][]byte) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// Describes the first line at which two outputs differ
+func firstDifference(got, want []byte) string {
+	gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return fmt.Sprintf("line %d:\n  got:  %q\n  want: %q", i+1, g, w)
+		}
+	}
+	return "identical lines, differing line endings"
+}
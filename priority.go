@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The I/O scheduling classes the build can be lowered to
+const (
+	ioClassBestEffort = "best-effort"
+	ioClassIdle       = "idle"
+
+	// The lowest CPU priority, used by -background
+	maxNiceness = 19
+)
+
+var (
+	niceness int
+	ioClass  string
+	// Lower both priorities as far as possible, for scheduled rebuilds
+	background bool
+)
+
+// Checks the requested priorities, filling in the ones implied by -background
+func parsePriority() error {
+	if background {
+		if niceness == 0 {
+			niceness = maxNiceness
+		}
+		if ioClass == "" {
+			ioClass = ioClassIdle
+		}
+	}
+	if niceness < 0 || niceness > maxNiceness {
+		return fmt.Errorf("the niceness must be between 0 and %d", maxNiceness)
+	}
+	if ioClass != "" && ioClass != ioClassBestEffort && ioClass != ioClassIdle {
+		return fmt.Errorf("unknown I/O class %q, expected best-effort or idle", ioClass)
+	}
+	return nil
+}
+
+// Lowers the CPU and I/O priority of the whole process. Builds still run when
+// the platform does not allow it, as they would just be less polite
+func lowerPriority() {
+	if niceness != 0 {
+		if err := setNiceness(niceness); err != nil {
+			log.Warn().Err(err).Int("nice", niceness).Msg("Could not lower the CPU priority")
+		}
+	}
+	if ioClass != "" {
+		if err := setIOClass(ioClass); err != nil {
+			log.Warn().Err(err).Str("class", ioClass).Msg("Could not lower the I/O priority")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// From linux/ioprio.h
+const (
+	ioprioWhoProcess  = 1
+	ioprioClassShift  = 13
+	ioprioClassBE     = 2
+	ioprioClassIdle   = 3
+	ioprioLowestLevel = 7
+)
+
+// Linux keeps priorities per thread, so all threads of the process are
+// changed. Threads started later inherit them from the one starting them
+func eachThread(fn func(tid int) error) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// Threads may exit in the meantime
+		if err = fn(tid); err != nil && !errors.Is(err, unix.ESRCH) {
+			return err
+		}
+	}
+	return nil
+}
+
+func setNiceness(n int) error {
+	return eachThread(func(tid int) error {
+		return unix.Setpriority(unix.PRIO_PROCESS, tid, n)
+	})
+}
+
+func setIOClass(class string) error {
+	prio := ioprioClassIdle << ioprioClassShift
+	if class == ioClassBestEffort {
+		prio = ioprioClassBE<<ioprioClassShift | ioprioLowestLevel
+	}
+	return eachThread(func(tid int) error {
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
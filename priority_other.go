@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+var errPriority = errors.New("priorities are not supported on this platform")
+
+func setNiceness(int) error { return errPriority }
+
+func setIOClass(string) error { return errPriority }
@@ -0,0 +1,17 @@
+//go:build unix && !linux
+
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+func setNiceness(n int) error {
+	return unix.Setpriority(unix.PRIO_PROCESS, 0, n)
+}
+
+func setIOClass(string) error {
+	return errors.New("I/O priorities are not supported on this platform")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lucat1/statik/pkg/statik"
+)
+
+// How often the progress line is redrawn at most
+const progressInterval = 100 * time.Millisecond
+
+var showProgress bool
+
+// Tallies the events of a build into a single status line on stderr
+type progress struct {
+	walked, copied, rendered, warnings int
+	copiedBytes                        int64
+	drawn                              time.Time
+}
+
+func (p *progress) event(e statik.Event) {
+	switch e.Kind {
+	case statik.FileWalked:
+		p.walked++
+	case statik.FileCopied:
+		p.copied++
+		p.copiedBytes += e.Bytes
+	case statik.PageRendered:
+		p.rendered++
+	case statik.Warning:
+		p.warnings++
+	}
+	if time.Since(p.drawn) >= progressInterval {
+		p.draw()
+	}
+}
+
+func (p *progress) draw() {
+	p.drawn = time.Now()
+	fmt.Fprintf(os.Stderr, "\r\033[K%d files found, %d copied (%s), %d pages rendered, %d warnings",
+		p.walked, p.copied, humanize.Bytes(uint64(p.copiedBytes)), p.rendered, p.warnings)
+}
+
+// Draws the final figures and moves past the status line
+func (p *progress) done() {
+	p.draw()
+	fmt.Fprintln(os.Stderr)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A schedule determines when the daemon should rebuild next
+type schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Rebuilds at a fixed interval
+type interval time.Duration
+
+func (i interval) Next(after time.Time) time.Time { return after.Add(time.Duration(i)) }
+
+// Rebuilds following a standard five-field cron expression, where each field
+// is stored as a bitset of the accepted values
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// Whether the day of month and day of week fields have been restricted, as
+	// cron matches either of the two when both are
+	domRestricted, dowRestricted bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = [5]cronField{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// Parses either a duration such as 15m or a cron expression
+func parseSchedule(s string) (schedule, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("the rebuild interval must be positive")
+		}
+		return interval(d), nil
+	}
+	return parseCron(s)
+}
+
+func parseCron(s string) (c *cronSchedule, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected a duration or a cron expression with 5 fields", s)
+	}
+	var sets [5]uint64
+	for i, field := range fields {
+		if sets[i], err = parseCronField(field, cronFields[i]); err != nil {
+			return nil, fmt.Errorf("invalid cron field %q:\n%s", field, err)
+		}
+	}
+	// Sunday can be written both as 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSchedule{
+		minute:        sets[0],
+		hour:          sets[1],
+		dom:           sets[2],
+		month:         sets[3],
+		dow:           sets[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+// Parses a comma separated list of values, ranges (a-b) and steps (*/n, a-b/n)
+func parseCronField(s string, f cronField) (set uint64, err error) {
+	max := f.max
+	// Allow 7 as an alias for sunday in the day of week field
+	if f.max == 6 {
+		max = 7
+	}
+	for _, part := range strings.Split(s, ",") {
+		var (
+			lo, hi = f.min, f.max
+			step   = 1
+		)
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", loStr)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q", hiStr)
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range [%d, %d]", f.min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (c *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Give up after a few years, which only happens for impossible dates
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			// Truncating would step by absolute hours, which are off by half an
+			// hour in some zones
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    schedule
+		wantErr bool
+	}{
+		{in: ""},
+		{in: "15m", want: interval(15 * time.Minute)},
+		{in: "0s", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "* * * *", wantErr: true},
+		{in: "* * * * * *", wantErr: true},
+		{in: "60 * * * *", wantErr: true},
+		{in: "* 24 * * *", wantErr: true},
+		{in: "* * 0 * *", wantErr: true},
+		{in: "* * * 13 *", wantErr: true},
+		{in: "* * * * 8", wantErr: true},
+		{in: "5-1 * * * *", wantErr: true},
+		{in: "*/0 * * * *", wantErr: true},
+		{in: "a * * * *", wantErr: true},
+		{in: "0 3 * * *", want: &cronSchedule{
+			minute: 1, hour: 1 << 3, dom: 0xfffffffe, month: 0x1ffe, dow: 0x7f,
+		}},
+		{in: "*/15 1-5/2 1,15 * 7", want: &cronSchedule{
+			minute: 1 | 1<<15 | 1<<30 | 1<<45, hour: 1<<1 | 1<<3 | 1<<5,
+			dom: 1<<1 | 1<<15, month: 0x1ffe, dow: 1 | 1<<7,
+			domRestricted: true, dowRestricted: true,
+		}},
+		{in: "10/20 * * * *", want: &cronSchedule{
+			minute: 1<<10 | 1<<30 | 1<<50, hour: 0xffffff, dom: 0xfffffffe, month: 0x1ffe, dow: 0x7f,
+		}},
+	}
+	for _, tt := range tests {
+		got, err := parseSchedule(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSchedule(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		switch want := tt.want.(type) {
+		case *cronSchedule:
+			if c, ok := got.(*cronSchedule); !ok || *c != *want {
+				t.Errorf("parseSchedule(%q) = %+v, want %+v", tt.in, got, want)
+			}
+		default:
+			if got != tt.want {
+				t.Errorf("parseSchedule(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	zone := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loc
+	}
+	utc, kolkata, kathmandu, berlin := time.UTC, zone("Asia/Kolkata"), zone("Asia/Kathmandu"), zone("Europe/Berlin")
+	tests := []struct {
+		expr        string
+		after, want time.Time
+	}{
+		{"0 3 * * *", time.Date(2026, 1, 1, 10, 0, 0, 0, utc), time.Date(2026, 1, 2, 3, 0, 0, 0, utc)},
+		{"0 3 * * *", time.Date(2026, 1, 1, 10, 0, 0, 0, kolkata), time.Date(2026, 1, 2, 3, 0, 0, 0, kolkata)},
+		{"0 3 * * *", time.Date(2026, 1, 1, 2, 59, 30, 0, kolkata), time.Date(2026, 1, 1, 3, 0, 0, 0, kolkata)},
+		{"30 */6 * * *", time.Date(2026, 1, 1, 7, 0, 0, 0, kathmandu), time.Date(2026, 1, 1, 12, 30, 0, 0, kathmandu)},
+		{"*/15 * * * *", time.Date(2026, 1, 1, 10, 7, 0, 0, kolkata), time.Date(2026, 1, 1, 10, 15, 0, 0, kolkata)},
+		{"0 3 * * *", time.Date(2026, 1, 1, 3, 0, 0, 0, utc), time.Date(2026, 1, 2, 3, 0, 0, 0, utc)},
+		// 02:30 does not exist on the day clocks are moved forward
+		{"30 2 * * *", time.Date(2026, 3, 28, 12, 0, 0, 0, berlin), time.Date(2026, 3, 30, 2, 30, 0, 0, berlin)},
+		{"0 4 * * *", time.Date(2026, 3, 28, 12, 0, 0, 0, berlin), time.Date(2026, 3, 29, 4, 0, 0, 0, berlin)},
+		{"0 4 * * *", time.Date(2026, 10, 24, 12, 0, 0, 0, berlin), time.Date(2026, 10, 25, 4, 0, 0, 0, berlin)},
+		// Either the day of month or the day of week matches when both are set
+		{"0 0 13 * 5", time.Date(2026, 1, 1, 0, 0, 0, 0, utc), time.Date(2026, 1, 2, 0, 0, 0, 0, utc)},
+		{"0 0 1 * *", time.Date(2026, 12, 15, 0, 0, 0, 0, kolkata), time.Date(2027, 1, 1, 0, 0, 0, 0, kolkata)},
+		{"0 0 29 2 *", time.Date(2026, 3, 1, 0, 0, 0, 0, utc), time.Date(2028, 2, 29, 0, 0, 0, 0, utc)},
+		{"0 0 30 2 *", time.Date(2026, 1, 1, 0, 0, 0, 0, utc), time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.after, got, tt.want)
+		}
+	}
+}
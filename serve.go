@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/dustin/go-humanize"
+	"github.com/lucat1/statik/pkg/statik"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	liveReloadPath   = "/.statik/livereload"
+	defaultServeAddr = ":8080"
+)
+
+// Appended to the pages served in watch mode, reloading them once the
+// listing has been updated
+var liveReloadScript = []byte(`<script>new EventSource("` + liveReloadPath + `").onmessage = function () { location.reload() }</script>`)
+
+var (
+	serveAddr string
+	// Whether to build into memory, serve and watch the source all at once
+	buildAndServe bool
+	livereload    = reloader{clients: map[chan struct{}]bool{}}
+)
+
+// Creates a scratch output directory, in memory when a tmpfs is available
+func scratchDir() (string, error) {
+	base := ""
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		base = "/dev/shm"
+	}
+	return os.MkdirTemp(base, "statik-")
+}
+
+// Keeps track of the pages waiting for the listing to be updated
+type reloader struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func (r *reloader) broadcast() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for client := range r.clients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Streams an event to the page each time the listing is updated
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	client := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.clients[client] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, client)
+		r.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+	for {
+		select {
+		case <-client:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// Derives the URL the preview server is reachable at from its address
+func previewURL(addr string) (*url.URL, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q:\n%s", addr, err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(host, port)}, nil
+}
+
+type previewHandler struct {
+	files http.Handler
+}
+
+// Serves the output directory, injecting the live reload script into the
+// listing pages when watching for changes. statik's own files are hidden
+func (p previewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if statik.IsPrivate(r.URL.Path) {
+		http.NotFound(w, r)
+		return
+	}
+	name := r.URL.Path
+	if strings.HasSuffix(name, "/") {
+		name += "index.html"
+	}
+	if !watchMode || path.Ext(name) != ".html" {
+		p.files.ServeHTTP(w, r)
+		return
+	}
+	page, err := os.ReadFile(path.Join(statik.OutputDir(config), path.Clean("/"+name)))
+	if err != nil {
+		p.files.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(append(bytes.TrimRight(page, "\n"), liveReloadScript...))
+}
+
+// Serves the output directory over HTTP in the background, along with the
+// health endpoints
+func servePreview(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle(liveReloadPath, &livereload)
+	registerHealth(mux)
+	prefix := strings.TrimSuffix(config.BaseURL.Path, "/")
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, previewHandler{http.FileServer(http.Dir(statik.OutputDir(config)))}))
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Fatal().Err(err).Str("addr", addr).Msg("Preview server stopped")
+		}
+	}()
+	log.Info().Str("url", config.BaseURL.String()).Msg("Serving the generated listing")
+}
+
+// Builds the site once and keeps serving it until the process is stopped
+func runServe() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	if report := runBuild(ctx); report.Failed() {
+		os.Exit(1)
+	}
+	<-ctx.Done()
+	log.Info().Msg("Shutting down")
+}
+
+// Builds the site and keeps it up to date with the source, reloading the
+// pages open in the preview server after each update
+func runWatch() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	cfg := config
+	cfg.Events = func(e statik.Event) {
+		if e.Kind == statik.BuildStarted {
+			health.started()
+		}
+	}
+	err := statik.Watch(ctx, cfg, func(report *statik.Report, dirs []string) {
+		health.finished(report)
+		switch {
+		case report.Failed():
+			log.Error().Str("error", report.Error).Msg("Update failed")
+		case dirs == nil:
+			log.Info().Int("files", report.Files).Str("size", humanize.Bytes(uint64(report.Bytes))).Msg("Generated listing")
+		default:
+			log.Info().Strs("dirs", dirs).Int("files", report.Files).Str("size", humanize.Bytes(uint64(report.Bytes))).Msg("Updated listing")
+			livereload.broadcast()
+		}
+		if len(report.Unreadable) > 0 {
+			log.Warn().Strs("dirs", report.Unreadable).Msg("Some directories could not be read and are listed without their contents")
+		}
+		if len(report.Collisions) > 0 {
+			log.Warn().Strs("paths", report.Collisions).Msg("Some source entries collide with generated outputs")
+		}
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Could not watch the source directory")
+	}
+	log.Info().Msg("Shutting down")
+}